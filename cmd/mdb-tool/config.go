@@ -7,12 +7,15 @@ import (
 	"github.com/segmentio/encoding/json"
 
 	"github.com/minlau/mdb-tool/internal/utils/closer"
+	"github.com/minlau/mdb-tool/scheduler"
 	"github.com/minlau/mdb-tool/store"
 )
 
 type Config struct {
 	DataSources     []store.DataSource
 	DatabaseConfigs []store.DatabaseConfig
+	Store           store.Config
+	Scheduler       scheduler.Config
 }
 
 func LoadConfig(path string) (*Config, error) {
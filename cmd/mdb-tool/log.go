@@ -3,13 +3,21 @@ package main
 import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"io"
 	"os"
 )
 
-func initLogger() {
+// initLogger writes logs to out. Server logs to stdout, CLI commands log to stderr to keep stdout for results.
+func initLogger(out io.Writer) {
 	zerolog.TimeFieldFormat = "2006-01-02 15:04:05.000000"
 	log.Logger = log.Output(zerolog.ConsoleWriter{
-		Out:        os.Stdout,
+		Out:        out,
 		TimeFormat: "2006-01-02 15:04:05.000000",
 	})
 }
+
+// initCommandLogger logs warnings and errors of CLI commands to stderr.
+func initCommandLogger() {
+	initLogger(os.Stderr)
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+}
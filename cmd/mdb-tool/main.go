@@ -3,18 +3,36 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/minlau/mdb-tool/scheduler"
 	"github.com/minlau/mdb-tool/store"
 	"github.com/minlau/mdb-tool/web"
 	"github.com/rs/zerolog/log"
 	"net/http"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "query":
+			os.Exit(runQuery(os.Args[2:]))
+		case "databases":
+			os.Exit(runDatabases(os.Args[2:]))
+		case "shell":
+			os.Exit(runShell(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		}
+	}
+	serve()
+}
+
+func serve() {
 	port := flag.Int("port", 8080, "server port")
 	configFilePath := flag.String("config", "config.json", "databases config file path")
 	flag.Parse()
 
-	initLogger()
+	initLogger(os.Stdout)
 
 	log.Info().Msg("starting app")
 
@@ -24,21 +42,34 @@ func main() {
 		return
 	}
 
-	log.Info().Msg("starting databases initialization")
-
-	databaseStore := store.NewDatabaseStore()
-	databaseStore.AddDatabases(cfg.DatabaseConfigs)
-	databaseConfigs, errs := store.GetDatabaseConfigsFromDataSources(cfg.DataSources)
-	for _, errItem := range errs {
-		log.Warn().Err(errItem).Msg("failed to get database configs from db")
+	databaseStore, err := newDatabaseStore(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create database store. closing app")
+		return
 	}
-	databaseStore.AddDatabases(databaseConfigs)
 
-	log.Info().Msg("finished databases initialization")
+	// interface stays nil if no queries are scheduled
+	var queryScheduler scheduler.SchedulerI
+	if len(cfg.Scheduler.Queries) > 0 {
+		s, err := scheduler.New(cfg.Scheduler, databaseStore)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create scheduler. closing app")
+			return
+		}
+		s.Start()
+		defer func() {
+			err := s.Stop()
+			if err != nil {
+				log.Warn().Err(err).Msg("failed to stop scheduler")
+			}
+		}()
+		queryScheduler = s
+		log.Info().Int("queries", len(cfg.Scheduler.Queries)).Msg("started scheduler")
+	}
 
 	log.Info().Msg("starting handlers initialization")
 
-	r := web.New(databaseStore)
+	r := web.New(databaseStore, queryScheduler)
 
 	log.Info().Msg("finished handlers initialization")
 
@@ -52,3 +83,21 @@ func main() {
 		return
 	}
 }
+
+func newDatabaseStore(cfg *Config) (*store.DatabaseStore, error) {
+	log.Info().Msg("starting databases initialization")
+
+	databaseStore, err := store.NewDatabaseStore(cfg.Store)
+	if err != nil {
+		return nil, err
+	}
+	databaseStore.AddDatabases(cfg.DatabaseConfigs)
+	databaseConfigs, errs := store.GetDatabaseConfigsFromDataSources(cfg.DataSources)
+	for _, errItem := range errs {
+		log.Warn().Err(errItem).Msg("failed to get database configs from db")
+	}
+	databaseStore.AddDatabases(databaseConfigs)
+
+	log.Info().Msg("finished databases initialization")
+	return databaseStore, nil
+}
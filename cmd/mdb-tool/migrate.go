@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/minlau/mdb-tool/internal/utils/list"
+	"github.com/minlau/mdb-tool/store"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: mdb-tool migrate [flags] status|up|down
+
+Applies(up) or reverts(down) migrations of group type databases or prints their status. Migration files are read
+from store.migrations.directory/<groupType> of config.
+
+Flags:
+`
+
+// runMigrate runs migrate command and returns exit code. Exit code is 1 if migration of any database failed.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	configFilePath := flags.String("config", "config.json", "databases config file path")
+	groupType := flags.String("group-type", "", "group type of migrated databases(required)")
+	groupNames := flags.String("group-names", "", "comma separated group names. All databases of group type if not set")
+	steps := flags.Int("steps", 0, "count of applied or reverted migrations. Default: all pending for up, 1 for down")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	command := flags.Arg(0)
+	if *groupType == "" || flags.NArg() != 1 ||
+		(command != "status" && command != store.MigrationDirectionUp && command != store.MigrationDirectionDown) {
+		flags.Usage()
+		return 2
+	}
+
+	initCommandLogger()
+
+	cfg, err := LoadConfig(*configFilePath)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load config")
+		return 1
+	}
+	databaseStore, err := newDatabaseStore(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create database store")
+		return 1
+	}
+
+	ctx := context.Background()
+	names := list.Split(*groupNames)
+	if command == "status" {
+		status, err := databaseStore.GetMigrationStatus(ctx, *groupType, names)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get migration status")
+			return 1
+		}
+		return printMigrationStatus(os.Stdout, status)
+	}
+
+	result, err := databaseStore.Migrate(ctx, *groupType, command, store.MigrationOptions{
+		GroupNames: names,
+		Steps:      *steps,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to migrate")
+		return 1
+	}
+	return printMigrationResult(os.Stdout, result)
+}
+
+func printMigrationStatus(w io.Writer, status store.MigrationStatus) int {
+	exitCode := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "GROUP\tVERSION\tPENDING\tUNKNOWN\tERROR")
+	for _, database := range status.Databases {
+		version := "-"
+		if database.CurrentVersion != nil {
+			version = strconv.FormatInt(*database.CurrentVersion, 10)
+		}
+		errorText := ""
+		if database.Error != nil {
+			errorText = database.Error.Message
+			exitCode = 1
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", database.GroupName, version, formatVersions(database.Pending),
+			formatVersions(database.Unknown), errorText)
+	}
+	_ = tw.Flush()
+	return exitCode
+}
+
+func printMigrationResult(w io.Writer, result store.MigrationResult) int {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "GROUP\tSTATUS\tVERSIONS\tERROR")
+	for _, database := range result.Databases {
+		errorText := ""
+		if database.Error != nil {
+			errorText = database.Error.Message
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", database.GroupName, database.Status,
+			formatVersions(database.Versions), errorText)
+	}
+	_ = tw.Flush()
+	if result.Failed() {
+		return 1
+	}
+	return 0
+}
+
+func formatVersions(versions []int64) string {
+	if len(versions) == 0 {
+		return "-"
+	}
+	items := make([]string, len(versions))
+	for i, version := range versions {
+		items[i] = strconv.FormatInt(version, 10)
+	}
+	return strings.Join(items, ",")
+}
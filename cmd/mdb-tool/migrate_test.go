@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/minlau/mdb-tool/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_printMigrationResult(t *testing.T) {
+	var out bytes.Buffer
+	exitCode := printMigrationResult(&out, store.MigrationResult{Databases: []store.DatabaseMigrationResult{
+		{GroupName: "a", Status: store.MigrationStatusApplied, Versions: []int64{1, 2}},
+		{GroupName: "bb", Status: store.MigrationStatusFailed, Error: store.NewQueryError(errors.New("broken"))},
+		{GroupName: "c", Status: store.MigrationStatusSkipped},
+	}})
+	assert.Equal(t, 1, exitCode)
+	assert.Equal(t, `GROUP  STATUS   VERSIONS  ERROR
+a      applied  1,2       
+bb     failed   -         broken
+c      skipped  -         
+`, out.String())
+
+	out.Reset()
+	exitCode = printMigrationResult(&out, store.MigrationResult{Databases: []store.DatabaseMigrationResult{
+		{GroupName: "a", Status: store.MigrationStatusUpToDate},
+	}})
+	assert.Equal(t, 0, exitCode)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/minlau/mdb-tool/export"
+	"github.com/minlau/mdb-tool/store"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	outputFormatTable = "table"
+	outputFormatCsv   = "csv"
+	outputFormatJson  = "json"
+)
+
+const queryUsage = `usage: mdb-tool query [flags]
+
+Executes query in databases of group type(or a single database) and prints results to stdout. Query is read from
+stdin if -e is not set. Exit code is 1 if query failed in any database.
+
+Flags:
+`
+
+const databasesUsage = `usage: mdb-tool databases [flags]
+
+Prints configured databases to stdout.
+
+Flags:
+`
+
+func isValidOutputFormat(format string) bool {
+	return format == outputFormatTable || format == outputFormatCsv || format == outputFormatJson
+}
+
+// runQuery runs query command and returns exit code.
+func runQuery(args []string) int {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(flags.Output(), queryUsage)
+		flags.PrintDefaults()
+	}
+	configFilePath := flags.String("config", "config.json", "databases config file path")
+	groupType := flags.String("group-type", "", "group type of queried databases(required)")
+	groupName := flags.String("group-name", "", "group name of a single queried database")
+	query := flags.String("e", "", "query to execute")
+	schema := flags.String("schema", "", "default schema of query execution")
+	format := flags.String("format", outputFormatTable, "output format: table, csv or json")
+	timeout := flags.Duration("timeout", 0, "query timeout(i.e. 30s). No timeout if not set")
+	role := flags.String("role", "", "caller role of masking rules")
+	confirm := flags.Bool("confirm", false, "confirm destructive statements of policies, which require confirmation")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if *groupType == "" || flags.NArg() != 0 || !isValidOutputFormat(*format) {
+		flags.Usage()
+		return 2
+	}
+
+	initCommandLogger()
+
+	if *query == "" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to read query from stdin")
+			return 1
+		}
+		*query = strings.TrimSpace(string(b))
+	}
+	if *query == "" {
+		log.Error().Msg("query is empty")
+		return 2
+	}
+
+	cfg, err := LoadConfig(*configFilePath)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load config")
+		return 1
+	}
+	databaseStore, err := newDatabaseStore(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create database store")
+		return 1
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	options := store.QueryOptions{Schema: *schema, Role: *role, Confirm: *confirm}
+	var results []store.GroupQueryResult
+	if *groupName != "" {
+		results = []store.GroupQueryResult{databaseStore.QueryDatabase(ctx, *groupName, *groupType, *query, options)}
+	} else {
+		results = databaseStore.QueryMultipleDatabases(ctx, *groupType, *query, options)
+		if len(results) == 0 {
+			log.Error().Str("groupType", *groupType).Msg("no databases registered with group type")
+			return 1
+		}
+	}
+
+	err = printQueryResults(os.Stdout, *format, results)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to print results")
+		return 1
+	}
+	return printGroupErrors(os.Stderr, results)
+}
+
+// printQueryResults prints results of all groups as a single table(see store.MergeGroupQueryResults). Rows affected
+// count of every group is printed if query does not return rows.
+func printQueryResults(w io.Writer, format string, results []store.GroupQueryResult) error {
+	if format == outputFormatJson {
+		for _, result := range results {
+			for _, data := range result.Results {
+				store.EncodeQueryData(data, store.EncodingOptions{})
+			}
+		}
+		return json.NewEncoder(w).Encode(results)
+	}
+
+	merged := store.MergeGroupQueryResults(results)
+	data := merged.Data
+	if len(data.Columns) == 1 {
+		data = rowsAffectedData(results)
+	}
+	return writeQueryData(w, format, data)
+}
+
+// rowsAffectedData returns rows affected count of every successful group as a table.
+func rowsAffectedData(results []store.GroupQueryResult) *store.QueryData {
+	data := &store.QueryData{
+		Columns: []store.Column{
+			{Name: "groupName", FieldName: "groupName"},
+			{Name: "rowsAffected", FieldName: "rowsAffected"},
+		},
+		Rows: []map[string]any{},
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		var rowsAffected any
+		if result.RowsAffected != nil {
+			rowsAffected = *result.RowsAffected
+		}
+		data.Rows = append(data.Rows, map[string]any{"groupName": result.GroupName, "rowsAffected": rowsAffected})
+	}
+	return data
+}
+
+func writeQueryData(w io.Writer, format string, data *store.QueryData) error {
+	switch format {
+	case outputFormatTable:
+		return export.WriteTable(w, data)
+	case outputFormatCsv:
+		return export.Write(w, export.FormatCsv, []export.Sheet{{Name: "results", Data: data}})
+	default:
+		return errors.Errorf("unknown output format: %s", format)
+	}
+}
+
+// printGroupErrors prints errors of failed groups and returns exit code 1 if any group failed.
+func printGroupErrors(w io.Writer, results []store.GroupQueryResult) int {
+	exitCode := 0
+	for _, result := range results {
+		if result.Error != nil {
+			_, _ = fmt.Fprintf(w, "%s: %s\n", result.GroupName, result.Error.Message)
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// runDatabases runs databases command and returns exit code.
+func runDatabases(args []string) int {
+	flags := flag.NewFlagSet("databases", flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(flags.Output(), databasesUsage)
+		flags.PrintDefaults()
+	}
+	configFilePath := flags.String("config", "config.json", "databases config file path")
+	groupType := flags.String("group-type", "", "prints only databases of group type")
+	format := flags.String("format", outputFormatTable, "output format: table, csv or json")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 || !isValidOutputFormat(*format) {
+		flags.Usage()
+		return 2
+	}
+
+	initCommandLogger()
+
+	cfg, err := LoadConfig(*configFilePath)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load config")
+		return 1
+	}
+	databaseStore, err := newDatabaseStore(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create database store")
+		return 1
+	}
+
+	items := []store.DatabaseItem{}
+	for _, item := range databaseStore.GetDatabaseItems() {
+		if *groupType == "" || item.GroupType == *groupType {
+			items = append(items, item)
+		}
+	}
+
+	err = printDatabaseItems(os.Stdout, *format, items)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to print databases")
+		return 1
+	}
+	return 0
+}
+
+func printDatabaseItems(w io.Writer, format string, items []store.DatabaseItem) error {
+	if format == outputFormatJson {
+		return json.NewEncoder(w).Encode(items)
+	}
+	data := &store.QueryData{
+		Columns: []store.Column{
+			{Name: "groupType", FieldName: "groupType"},
+			{Name: "groupName", FieldName: "groupName"},
+			{Name: "type", FieldName: "type"},
+			{Name: "label", FieldName: "label"},
+		},
+		Rows: make([]map[string]any, 0, len(items)),
+	}
+	for _, item := range items {
+		data.Rows = append(data.Rows, map[string]any{
+			"groupType": item.GroupType,
+			"groupName": item.GroupName,
+			"type":      item.Type,
+			"label":     item.Label,
+		})
+	}
+	return writeQueryData(w, format, data)
+}
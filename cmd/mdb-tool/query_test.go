@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/minlau/mdb-tool/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_printQueryResults(t *testing.T) {
+	two := int64(2)
+	tests := []struct {
+		name    string
+		format  string
+		results []store.GroupQueryResult
+		want    string
+	}{
+		{
+			name:   "table",
+			format: outputFormatTable,
+			results: []store.GroupQueryResult{
+				{GroupName: "a", Data: &store.QueryData{
+					Columns: []store.Column{{Name: "id", FieldName: "id"}},
+					Rows:    []map[string]any{{"id": int64(1)}, {"id": int64(2)}},
+				}},
+				{GroupName: "b", Error: store.NewQueryError(errors.New("failed"))},
+			},
+			want: "groupName | id\n----------+---\na         | 1\na         | 2\n(2 rows)\n",
+		},
+		{
+			name:   "csv rows affected",
+			format: outputFormatCsv,
+			results: []store.GroupQueryResult{
+				{GroupName: "a", Data: &store.QueryData{Columns: []store.Column{}}, RowsAffected: &two},
+				{GroupName: "b", Error: store.NewQueryError(errors.New("failed"))},
+			},
+			want: "groupName,rowsAffected\na,2\n",
+		},
+		{
+			name:    "json",
+			format:  outputFormatJson,
+			results: []store.GroupQueryResult{{GroupName: "a", RowsAffected: &two}},
+			want: `[{"groupName":"a","data":null,"results":null,"error":null,"statementKind":"","rowsAffected":2,` +
+				`"waitTimeInMilliseconds":0,"executionTimeInMilliseconds":0}]` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, printQueryResults(&out, tt.format, tt.results))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func Test_printGroupErrors(t *testing.T) {
+	var out bytes.Buffer
+	assert.Equal(t, 0, printGroupErrors(&out, []store.GroupQueryResult{{GroupName: "a"}}))
+	assert.Equal(t, 1, printGroupErrors(&out, []store.GroupQueryResult{
+		{GroupName: "a"},
+		{GroupName: "b", Error: store.NewQueryError(errors.New("connection refused"))},
+	}))
+	assert.Equal(t, "b: connection refused\n", out.String())
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/chzyer/readline"
+	"github.com/minlau/mdb-tool/internal/utils/list"
+	"github.com/minlau/mdb-tool/store"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const shellUsage = `usage: mdb-tool shell [flags]
+
+Starts interactive shell, which executes statements in selected databases of group type. Statements end with ';'.
+Type \help for a list of meta-commands.
+
+Flags:
+`
+
+const shellHelp = `\use GROUP_TYPE [GROUP_NAMES]  select group type and optionally comma separated group names
+\groups [GROUP_NAMES|all]     list databases of group type or select group names
+\tables [PATTERN]             list tables of first selected database
+\format [table|csv|json]      show or set output format
+\timing [on|off]              toggle or set execution time output
+\confirm                      confirm destructive statements of next query
+\help                         show this help
+\q                            quit
+`
+
+// shellMetaCommands are completed at the start of a line.
+var shellMetaCommands = []string{`\use`, `\groups`, `\tables`, `\format`, `\timing`, `\confirm`, `\help`, `\q`}
+
+// shellKeywords are completed together with table and column names.
+var shellKeywords = []string{
+	"SELECT", "FROM", "WHERE", "AND", "OR", "NOT", "NULL", "IS", "IN", "LIKE", "BETWEEN", "EXISTS", "AS", "DISTINCT",
+	"JOIN", "LEFT", "RIGHT", "INNER", "OUTER", "ON", "GROUP", "BY", "ORDER", "HAVING", "LIMIT", "OFFSET", "UNION",
+	"ALL", "INSERT", "INTO", "VALUES", "UPDATE", "SET", "DELETE", "WITH", "CASE", "WHEN", "THEN", "ELSE", "END",
+	"COUNT", "SUM", "MIN", "MAX", "AVG", "ASC", "DESC",
+}
+
+// shellMetadataTimeout limits loading of tables metadata used by autocompletion.
+const shellMetadataTimeout = 10 * time.Second
+
+// shell is a state of interactive shell. It is separated from readline, so that commands and autocompletion can be
+// used without terminal.
+type shell struct {
+	databaseStore store.DatabaseStoreI
+	out           io.Writer
+	errOut        io.Writer
+
+	groupType string
+	// groupNames are selected databases of group type. All databases are selected if empty
+	groupNames []string
+	format     string
+	timing     bool
+	// role is a caller role of masking rules
+	role string
+	// confirm confirms destructive statements of next query
+	confirm bool
+
+	// metadata is tables metadata of first selected database used by autocompletion. It is loaded on first use
+	metadata *store.TablesMetadata
+}
+
+func newShell(databaseStore store.DatabaseStoreI, out io.Writer, errOut io.Writer) *shell {
+	return &shell{databaseStore: databaseStore, out: out, errOut: errOut, format: outputFormatTable}
+}
+
+// runShell runs shell command and returns exit code.
+func runShell(args []string) int {
+	flags := flag.NewFlagSet("shell", flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(flags.Output(), shellUsage)
+		flags.PrintDefaults()
+	}
+	configFilePath := flags.String("config", "config.json", "databases config file path")
+	groupType := flags.String("group-type", "", "initially selected group type")
+	historyFilePath := flags.String("history", defaultHistoryFilePath(), "history file path. History is not saved if empty")
+	role := flags.String("role", "", "caller role of masking rules")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	initCommandLogger()
+
+	cfg, err := LoadConfig(*configFilePath)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load config")
+		return 1
+	}
+
+	databaseStore, err := newDatabaseStore(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create database store")
+		return 1
+	}
+
+	sh := newShell(databaseStore, os.Stdout, os.Stderr)
+	sh.role = *role
+	if *groupType != "" {
+		sh.executeMetaCommand(`\use ` + *groupType)
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:                 sh.prompt(),
+		HistoryFile:            *historyFilePath,
+		DisableAutoSaveHistory: true,
+		AutoComplete:           sh,
+		InterruptPrompt:        "^C",
+		EOFPrompt:              `\q`,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start shell")
+		return 1
+	}
+	defer rl.Close() //nolint:errcheck
+
+	var buffer []string
+	for {
+		if len(buffer) == 0 {
+			rl.SetPrompt(sh.prompt())
+		} else {
+			rl.SetPrompt(sh.continuationPrompt())
+		}
+		line, err := rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			buffer = buffer[:0]
+			continue
+		}
+		if err != nil {
+			return 0
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if len(buffer) == 0 && strings.HasPrefix(trimmed, `\`) {
+			_ = rl.SaveHistory(trimmed)
+			if sh.executeMetaCommand(trimmed) {
+				return 0
+			}
+			continue
+		}
+		if trimmed == "" && len(buffer) == 0 {
+			continue
+		}
+		buffer = append(buffer, line)
+		statement := strings.Join(buffer, "\n")
+		if !store.IsCompleteQuery(statement, sh.sqlType()) {
+			continue
+		}
+		buffer = buffer[:0]
+		_ = rl.SaveHistory(statement)
+		sh.executeStatement(context.Background(), statement)
+	}
+}
+
+func defaultHistoryFilePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mdb_tool_history")
+}
+
+func (s *shell) prompt() string {
+	if s.groupType == "" {
+		return "mdb=> "
+	}
+	if len(s.groupNames) > 0 {
+		return s.groupType + "[" + strings.Join(s.groupNames, ",") + "]=> "
+	}
+	return s.groupType + "=> "
+}
+
+func (s *shell) continuationPrompt() string {
+	prompt := s.prompt()
+	return strings.Repeat(" ", len(prompt)-3) + "-> "
+}
+
+// executeStatement executes statement in selected databases and prints results.
+func (s *shell) executeStatement(ctx context.Context, statement string) {
+	if s.groupType == "" {
+		_, _ = fmt.Fprintln(s.errOut, `group type is not selected. Use \use GROUP_TYPE`)
+		return
+	}
+
+	start := time.Now()
+	results := s.databaseStore.QueryMultipleDatabases(ctx, s.groupType, statement,
+		store.QueryOptions{GroupNames: s.groupNames, Role: s.role, Confirm: s.confirm})
+	s.confirm = false
+	elapsed := time.Since(start)
+
+	err := printQueryResults(s.out, s.format, results)
+	if err != nil {
+		_, _ = fmt.Fprintf(s.errOut, "failed to print results: %v\n", err)
+	}
+	printGroupErrors(s.errOut, results)
+	if s.timing {
+		_, _ = fmt.Fprintf(s.out, "Time: %.3f ms\n", float64(elapsed.Microseconds())/1000)
+	}
+	// tables might have changed, so metadata is reloaded on next use
+	for _, result := range results {
+		for _, data := range result.Results {
+			if data.StatementKind == store.StatementKindDdl {
+				s.metadata = nil
+			}
+		}
+	}
+}
+
+// executeMetaCommand executes meta-command and returns true if shell must be closed.
+func (s *shell) executeMetaCommand(line string) bool {
+	fields := strings.Fields(line)
+	command, args := fields[0], fields[1:]
+	switch command {
+	case `\q`, `\quit`:
+		return true
+	case `\help`, `\?`:
+		_, _ = fmt.Fprint(s.out, shellHelp)
+	case `\use`:
+		s.use(args)
+	case `\groups`:
+		s.groups(args)
+	case `\tables`:
+		s.tables(args)
+	case `\format`:
+		if len(args) == 0 {
+			_, _ = fmt.Fprintf(s.out, "Output format is %s.\n", s.format)
+		} else if isValidOutputFormat(args[0]) {
+			s.format = args[0]
+			_, _ = fmt.Fprintf(s.out, "Output format is %s.\n", s.format)
+		} else {
+			_, _ = fmt.Fprintln(s.errOut, "format must be one of: table, csv, json")
+		}
+	case `\timing`:
+		if len(args) == 0 {
+			s.timing = !s.timing
+		} else {
+			s.timing = args[0] == "on"
+		}
+		if s.timing {
+			_, _ = fmt.Fprintln(s.out, "Timing is on.")
+		} else {
+			_, _ = fmt.Fprintln(s.out, "Timing is off.")
+		}
+	case `\confirm`:
+		s.confirm = true
+		_, _ = fmt.Fprintln(s.out, "Destructive statements of next query are confirmed.")
+	default:
+		_, _ = fmt.Fprintf(s.errOut, "unknown command: %s. Type \\help for a list of commands\n", command)
+	}
+	return false
+}
+
+func (s *shell) use(args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		_, _ = fmt.Fprintln(s.errOut, `usage: \use GROUP_TYPE [GROUP_NAMES]`)
+		return
+	}
+	if len(s.databaseItems(args[0])) == 0 {
+		_, _ = fmt.Fprintf(s.errOut, "no databases registered with group type: %s\n", args[0])
+		return
+	}
+	var groupNames []string
+	if len(args) == 2 {
+		groupNames = list.Split(args[1])
+		if !s.validGroupNames(args[0], groupNames) {
+			return
+		}
+	}
+	s.groupType = args[0]
+	s.groupNames = groupNames
+	s.metadata = nil
+}
+
+func (s *shell) groups(args []string) {
+	if s.groupType == "" {
+		_, _ = fmt.Fprintln(s.errOut, `group type is not selected. Use \use GROUP_TYPE`)
+		return
+	}
+	if len(args) == 0 {
+		selected := make(map[string]bool, len(s.groupNames))
+		for _, groupName := range s.groupNames {
+			selected[groupName] = true
+		}
+		for _, item := range s.databaseItems(s.groupType) {
+			marker := " "
+			if len(s.groupNames) == 0 || selected[item.GroupName] {
+				marker = "*"
+			}
+			_, _ = fmt.Fprintf(s.out, "%s %s\n", marker, item.GroupName)
+		}
+		return
+	}
+	if args[0] == "all" {
+		s.groupNames = nil
+		s.metadata = nil
+		return
+	}
+	groupNames := list.Split(args[0])
+	if s.validGroupNames(s.groupType, groupNames) {
+		s.groupNames = groupNames
+		s.metadata = nil
+	}
+}
+
+func (s *shell) validGroupNames(groupType string, groupNames []string) bool {
+	known := make(map[string]bool)
+	for _, item := range s.databaseItems(groupType) {
+		known[item.GroupName] = true
+	}
+	for _, groupName := range groupNames {
+		if !known[groupName] {
+			_, _ = fmt.Fprintf(s.errOut, "no database registered with groupName: %s, groupType: %s\n", groupName,
+				groupType)
+			return false
+		}
+	}
+	return true
+}
+
+func (s *shell) tables(args []string) {
+	metadata, err := s.loadMetadata()
+	if err != nil {
+		_, _ = fmt.Fprintf(s.errOut, "failed to get tables metadata: %v\n", err)
+		return
+	}
+	pattern := ""
+	if len(args) > 0 {
+		pattern = strings.ToLower(args[0])
+	}
+	data := &store.QueryData{
+		Columns: []store.Column{
+			{Name: "schema", FieldName: "schema"},
+			{Name: "name", FieldName: "name"},
+			{Name: "type", FieldName: "type"},
+		},
+		Rows: []map[string]any{},
+	}
+	for _, schema := range metadata.Schemas {
+		for _, table := range schema.Tables {
+			if strings.Contains(strings.ToLower(table.Name), pattern) {
+				data.Rows = append(data.Rows, map[string]any{"schema": table.Schema, "name": table.Name,
+					"type": table.Type})
+			}
+		}
+	}
+	err = writeQueryData(s.out, outputFormatTable, data)
+	if err != nil {
+		_, _ = fmt.Fprintf(s.errOut, "failed to print tables: %v\n", err)
+	}
+}
+
+// sqlType returns database type of first selected database. Empty type is returned if group type is not selected.
+func (s *shell) sqlType() string {
+	for _, item := range s.databaseItems(s.groupType) {
+		if len(s.groupNames) == 0 || item.GroupName == s.groupNames[0] {
+			return item.Type
+		}
+	}
+	return ""
+}
+
+// databaseItems returns databases of group type.
+func (s *shell) databaseItems(groupType string) []store.DatabaseItem {
+	var items []store.DatabaseItem
+	for _, item := range s.databaseStore.GetDatabaseItems() {
+		if item.GroupType == groupType {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadMetadata returns tables metadata of first selected database.
+func (s *shell) loadMetadata() (*store.TablesMetadata, error) {
+	if s.metadata != nil {
+		return s.metadata, nil
+	}
+	if s.groupType == "" {
+		return nil, errors.New("group type is not selected")
+	}
+	groupName := ""
+	if len(s.groupNames) > 0 {
+		groupName = s.groupNames[0]
+	} else if items := s.databaseItems(s.groupType); len(items) > 0 {
+		groupName = items[0].GroupName
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shellMetadataTimeout)
+	defer cancel()
+	metadata, err := s.databaseStore.GetTablesMetadata(ctx, groupName, s.groupType, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.metadata = metadata
+	return metadata, nil
+}
+
+// Do implements readline.AutoCompleter. Meta-commands, their arguments, SQL keywords, table and column names are
+// completed.
+func (s *shell) Do(line []rune, pos int) ([][]rune, int) {
+	text := string(line[:pos])
+	wordStart := strings.LastIndexFunc(text, func(r rune) bool {
+		return !isIdentifierRune(r) && r != '\\'
+	}) + 1
+	prefix := text[wordStart:]
+	before := strings.Fields(text[:wordStart])
+
+	var candidates []string
+	switch {
+	case len(before) == 0 && strings.HasPrefix(prefix, `\`):
+		candidates = shellMetaCommands
+	case len(before) == 1 && before[0] == `\use`:
+		candidates = s.groupTypes()
+	case len(before) == 1 && before[0] == `\groups`:
+		candidates = append([]string{"all"}, s.groupNamesOf(s.groupType)...)
+	case len(before) == 2 && before[0] == `\use`:
+		candidates = s.groupNamesOf(before[1])
+	case len(before) == 1 && before[0] == `\format`:
+		candidates = []string{outputFormatTable, outputFormatCsv, outputFormatJson}
+	case len(before) == 1 && before[0] == `\timing`:
+		candidates = []string{"on", "off"}
+	case len(before) > 0 && strings.HasPrefix(before[0], `\`):
+		candidates = nil
+	default:
+		candidates = s.sqlCandidates(prefix)
+		if i := strings.LastIndex(prefix, "."); i != -1 {
+			prefix = prefix[i+1:]
+		}
+	}
+	return completeWord(prefix, candidates)
+}
+
+// sqlCandidates returns columns of table for "table." prefix, tables of schema for "schema." prefix or keywords,
+// tables and columns otherwise.
+func (s *shell) sqlCandidates(prefix string) []string {
+	metadata, err := s.loadMetadata()
+	if err != nil {
+		metadata = &store.TablesMetadata{}
+	}
+
+	if i := strings.LastIndex(prefix, "."); i != -1 {
+		qualifier := prefix[:i]
+		var candidates []string
+		for _, schema := range metadata.Schemas {
+			for _, table := range schema.Tables {
+				if strings.EqualFold(table.Name, qualifier) {
+					for _, column := range table.Columns {
+						candidates = append(candidates, column.Name)
+					}
+				}
+				if strings.EqualFold(schema.Name, qualifier) {
+					candidates = append(candidates, table.Name)
+				}
+			}
+		}
+		return candidates
+	}
+
+	var candidates []string
+	for _, keyword := range shellKeywords {
+		if prefix != "" && unicode.IsLower([]rune(prefix)[0]) {
+			keyword = strings.ToLower(keyword)
+		}
+		candidates = append(candidates, keyword)
+	}
+	for _, schema := range metadata.Schemas {
+		for _, table := range schema.Tables {
+			candidates = append(candidates, table.Name)
+			for _, column := range table.Columns {
+				candidates = append(candidates, column.Name)
+			}
+		}
+	}
+	return candidates
+}
+
+func (s *shell) groupTypes() []string {
+	var groupTypes []string
+	seen := make(map[string]bool)
+	for _, item := range s.databaseStore.GetDatabaseItems() {
+		if !seen[item.GroupType] {
+			seen[item.GroupType] = true
+			groupTypes = append(groupTypes, item.GroupType)
+		}
+	}
+	return groupTypes
+}
+
+func (s *shell) groupNamesOf(groupType string) []string {
+	var groupNames []string
+	for _, item := range s.databaseItems(groupType) {
+		groupNames = append(groupNames, item.GroupName)
+	}
+	return groupNames
+}
+
+// completeWord returns sorted unique suffixes of candidates, which start with prefix(case-insensitive).
+func completeWord(prefix string, candidates []string) ([][]rune, int) {
+	seen := make(map[string]bool)
+	var suffixes []string
+	for _, candidate := range candidates {
+		if len(candidate) <= len(prefix) || !strings.EqualFold(candidate[:len(prefix)], prefix) {
+			continue
+		}
+		suffix := candidate[len(prefix):]
+		if !seen[suffix] {
+			seen[suffix] = true
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	sort.Strings(suffixes)
+
+	result := make([][]rune, len(suffixes))
+	for i, suffix := range suffixes {
+		result[i] = []rune(suffix + " ")
+	}
+	return result, len([]rune(prefix))
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$' || r == '.'
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/minlau/mdb-tool/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestShell() (*shell, *bytes.Buffer, *bytes.Buffer, *store.QueryOptions) {
+	var lastOptions store.QueryOptions
+	databaseStore := store.DatabaseStoreMock{
+		GetDatabaseItemsFunc: func() []store.DatabaseItem {
+			return []store.DatabaseItem{
+				{DatabaseGroup: store.DatabaseGroup{GroupName: "dev", GroupType: "messaging"}, Type: "postgresql"},
+				{DatabaseGroup: store.DatabaseGroup{GroupName: "prod", GroupType: "messaging"}, Type: "firebird"},
+				{DatabaseGroup: store.DatabaseGroup{GroupName: "dev", GroupType: "users"}},
+			}
+		},
+		GetTablesMetadataFunc: func(ctx context.Context, groupName string, groupType string, schemas []string) (*store.TablesMetadata, error) {
+			return &store.TablesMetadata{Schemas: []store.SchemaMetadata{{Name: "public", Tables: []store.TableMetadata{
+				{Schema: "public", Name: "messages", Type: store.TableTypeTable, Columns: []store.ColumnMetadata{
+					{Name: "id"}, {Name: "sender_id"}, {Name: "text"},
+				}},
+				{Schema: "public", Name: "senders", Type: store.TableTypeView, Columns: []store.ColumnMetadata{{Name: "id"}}},
+			}}}}, nil
+		},
+		QueryMultipleDatabasesFunc: func(ctx context.Context, groupType string, query string, options store.QueryOptions) []store.GroupQueryResult {
+			lastOptions = options
+			return []store.GroupQueryResult{{GroupName: "dev", Data: &store.QueryData{
+				Columns: []store.Column{{Name: "id", FieldName: "id"}},
+				Rows:    []map[string]any{{"id": int64(1)}},
+			}}}
+		},
+	}
+	var out, errOut bytes.Buffer
+	return newShell(databaseStore, &out, &errOut), &out, &errOut, &lastOptions
+}
+
+func completions(s *shell, line string) []string {
+	suffixes, _ := s.Do([]rune(line), len([]rune(line)))
+	var result []string
+	for _, suffix := range suffixes {
+		result = append(result, string(suffix))
+	}
+	return result
+}
+
+func Test_shell_Do(t *testing.T) {
+	s, _, _, _ := newTestShell()
+	s.executeMetaCommand(`\use messaging`)
+
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{name: "meta-command", line: `\t`, want: []string{"ables ", "iming "}},
+		{name: "group type", line: `\use u`, want: []string{"sers "}},
+		{name: "group name", line: `\groups p`, want: []string{"rod "}},
+		{name: "lower case keyword", line: "sel", want: []string{"ect "}},
+		{name: "upper case keyword", line: "SEL", want: []string{"ECT "}},
+		{name: "table", line: "select * from mes", want: []string{"sages "}},
+		{name: "column", line: "select sen", want: []string{"der_id ", "ders "}},
+		{name: "qualified column", line: "select messages.t", want: []string{"ext "}},
+		{name: "schema table", line: "select * from public.s", want: []string{"enders "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, completions(s, tt.line))
+		})
+	}
+}
+
+func Test_shell_executeMetaCommand(t *testing.T) {
+	s, out, errOut, _ := newTestShell()
+	assert.Equal(t, "mdb=> ", s.prompt())
+
+	s.executeMetaCommand(`\use missing`)
+	assert.Equal(t, "no databases registered with group type: missing\n", errOut.String())
+
+	s.executeMetaCommand(`\use messaging prod`)
+	assert.Equal(t, "messaging[prod]=> ", s.prompt())
+	s.executeMetaCommand(`\groups`)
+	assert.Equal(t, "  dev\n* prod\n", out.String())
+
+	out.Reset()
+	s.executeMetaCommand(`\groups all`)
+	assert.Equal(t, "messaging=> ", s.prompt())
+	s.executeMetaCommand(`\tables send`)
+	assert.Equal(t, "schema | name    | type\n-------+---------+-----\npublic | senders | view\n(1 row)\n", out.String())
+
+	s.executeMetaCommand(`\format json`)
+	assert.Equal(t, outputFormatJson, s.format)
+	s.executeMetaCommand(`\timing`)
+	assert.True(t, s.timing)
+	assert.True(t, s.executeMetaCommand(`\q`))
+}
+
+func Test_shell_executeStatement(t *testing.T) {
+	s, out, errOut, options := newTestShell()
+	s.executeStatement(context.Background(), "select 1;")
+	assert.Equal(t, "group type is not selected. Use \\use GROUP_TYPE\n", errOut.String())
+
+	s.executeMetaCommand(`\use messaging dev`)
+	s.executeStatement(context.Background(), "select 1;")
+	assert.Equal(t, []string{"dev"}, options.GroupNames)
+	assert.Equal(t, "groupName | id\n----------+---\ndev       | 1\n(1 row)\n", out.String())
+	assert.False(t, options.Confirm)
+
+	s.role = "support"
+	s.executeMetaCommand(`\confirm`)
+	s.executeStatement(context.Background(), "delete from messages;")
+	assert.True(t, options.Confirm)
+	assert.Equal(t, "support", options.Role)
+	s.executeStatement(context.Background(), "delete from messages;")
+	assert.False(t, options.Confirm, "confirmation applies to a single query")
+}
+
+func Test_shell_sqlType(t *testing.T) {
+	s, _, _, _ := newTestShell()
+	assert.Equal(t, "", s.sqlType())
+	s.executeMetaCommand(`\use messaging`)
+	assert.Equal(t, "postgresql", s.sqlType())
+	s.executeMetaCommand(`\groups prod`)
+	assert.Equal(t, "firebird", s.sqlType())
+}
@@ -0,0 +1,253 @@
+package export
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/pkg/errors"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+// timeLayouts are layouts of time values, which are returned as strings(i.e. mysql without parseTime).
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+func writeArrow(w io.Writer, data *store.QueryData) error {
+	record, err := newRecord(data)
+	if err != nil {
+		return err
+	}
+	defer record.Release()
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(record.Schema()))
+	err = writer.Write(record)
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func writeParquet(w io.Writer, data *store.QueryData) error {
+	record, err := newRecord(data)
+	if err != nil {
+		return err
+	}
+	defer record.Release()
+
+	writer, err := pqarrow.NewFileWriter(record.Schema(), w,
+		parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy)), pqarrow.DefaultWriterProps())
+	if err != nil {
+		return err
+	}
+	err = writer.Write(record)
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// newRecord builds arrow record from data. Column type is inferred from driver column type(see arrowType). If any
+// value of a column can not be converted to inferred type, column is written as string.
+func newRecord(data *store.QueryData) (arrow.Record, error) {
+	fields := make([]arrow.Field, len(data.Columns))
+	columns := make([][]any, len(data.Columns))
+	for i, column := range data.Columns {
+		dataType := arrowType(column.Type)
+		values, ok := convertValues(dataType, data.Rows, column.FieldName)
+		if !ok {
+			dataType = arrow.BinaryTypes.String
+			values, _ = convertValues(dataType, data.Rows, column.FieldName)
+		}
+		fields[i] = arrow.Field{Name: column.Name, Type: dataType, Nullable: true}
+		columns[i] = values
+	}
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, arrow.NewSchema(fields, nil))
+	defer builder.Release()
+	for i, values := range columns {
+		err := appendValues(builder.Field(i), values)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to build column: %s", data.Columns[i].Name)
+		}
+	}
+	return builder.NewRecord(), nil
+}
+
+// arrowType maps column logical type to arrow type. Decimals keep their precision and scale, types without arrow
+// counterpart are strings.
+func arrowType(columnType *store.ColumnType) arrow.DataType {
+	if columnType == nil {
+		return arrow.BinaryTypes.String
+	}
+	switch columnType.LogicalType {
+	case store.LogicalTypeInteger:
+		if strings.HasPrefix(columnType.DatabaseTypeName, "UNSIGNED ") {
+			return arrow.PrimitiveTypes.Uint64
+		}
+		return arrow.PrimitiveTypes.Int64
+	case store.LogicalTypeDecimal:
+		if columnType.Precision != nil && *columnType.Precision <= 38 {
+			return &arrow.Decimal128Type{Precision: int32(*columnType.Precision), Scale: int32(*columnType.Scale)}
+		}
+		return arrow.BinaryTypes.String
+	case store.LogicalTypeFloat:
+		return arrow.PrimitiveTypes.Float64
+	case store.LogicalTypeBoolean:
+		return arrow.FixedWidthTypes.Boolean
+	case store.LogicalTypeDate:
+		return arrow.FixedWidthTypes.Date32
+	case store.LogicalTypeTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_us
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+func convertValues(dataType arrow.DataType, rows []map[string]any, fieldName string) ([]any, bool) {
+	values := make([]any, len(rows))
+	for i, row := range rows {
+		value, ok := convertValue(dataType, row[fieldName])
+		if !ok {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// convertValue converts value to a Go type of arrow type builder. False is returned if value can not be converted.
+func convertValue(dataType arrow.DataType, value any) (any, bool) {
+	if value == nil {
+		return nil, true
+	}
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+
+	switch t := dataType.(type) {
+	case *arrow.Int64Type:
+		switch v := value.(type) {
+		case int, int8, int16, int32, int64:
+			return reflect.ValueOf(v).Int(), true
+		case uint, uint8, uint16, uint32, uint64:
+			u := reflect.ValueOf(v).Uint()
+			return int64(u), u <= math.MaxInt64
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			return i, err == nil
+		}
+	case *arrow.Uint64Type:
+		switch v := value.(type) {
+		case int, int8, int16, int32, int64:
+			i := reflect.ValueOf(v).Int()
+			return uint64(i), i >= 0
+		case uint, uint8, uint16, uint32, uint64:
+			return reflect.ValueOf(v).Uint(), true
+		case string:
+			u, err := strconv.ParseUint(v, 10, 64)
+			return u, err == nil
+		}
+	case *arrow.Float64Type:
+		switch v := value.(type) {
+		case float32:
+			return float64(v), true
+		case float64:
+			return v, true
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil
+		}
+	case *arrow.BooleanType:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case int64:
+			return v != 0, v == 0 || v == 1
+		case string:
+			b, err := strconv.ParseBool(v)
+			return b, err == nil
+		}
+	case *arrow.TimestampType:
+		if v, ok := parseTime(value); ok {
+			ts, err := arrow.TimestampFromTime(v.UTC(), t.Unit)
+			return ts, err == nil
+		}
+	case *arrow.Date32Type:
+		if v, ok := parseTime(value); ok {
+			return arrow.Date32FromTime(v), true
+		}
+	case *arrow.Decimal128Type:
+		switch v := value.(type) {
+		case string:
+			n, err := decimal128.FromString(v, t.Precision, t.Scale)
+			return n, err == nil && n.FitsInPrecision(t.Precision)
+		case int64:
+			n := decimal128.FromI64(v).IncreaseScaleBy(t.Scale)
+			return n, n.FitsInPrecision(t.Precision)
+		default:
+			// i.e. firebird decimal.Decimal
+			n, err := decimal128.FromString(formatValue(v), t.Precision, t.Scale)
+			return n, err == nil && n.FitsInPrecision(t.Precision)
+		}
+	case *arrow.StringType:
+		return formatValue(value), true
+	}
+	return nil, false
+}
+
+func parseTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range timeLayouts {
+			t, err := time.Parse(layout, v)
+			if err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func appendValues(builder array.Builder, values []any) error {
+	for _, value := range values {
+		if value == nil {
+			builder.AppendNull()
+			continue
+		}
+		switch b := builder.(type) {
+		case *array.Int64Builder:
+			b.Append(value.(int64))
+		case *array.Uint64Builder:
+			b.Append(value.(uint64))
+		case *array.Float64Builder:
+			b.Append(value.(float64))
+		case *array.BooleanBuilder:
+			b.Append(value.(bool))
+		case *array.TimestampBuilder:
+			b.Append(value.(arrow.Timestamp))
+		case *array.Date32Builder:
+			b.Append(value.(arrow.Date32))
+		case *array.Decimal128Builder:
+			b.Append(value.(decimal128.Num))
+		case *array.StringBuilder:
+			b.Append(value.(string))
+		default:
+			return errors.Errorf("unsupported builder: %T", builder)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+func int64Pointer(v int64) *int64 {
+	return &v
+}
+
+func columnarTestData() *store.QueryData {
+	return &store.QueryData{
+		Columns: []store.Column{
+			{Name: "groupName", FieldName: "groupName"},
+			{Name: "id", FieldName: "id", Type: &store.ColumnType{LogicalType: store.LogicalTypeInteger}},
+			// mysql returns numbers as strings
+			{Name: "count", FieldName: "count", Type: &store.ColumnType{LogicalType: store.LogicalTypeInteger}},
+			{Name: "price", FieldName: "price", Type: &store.ColumnType{LogicalType: store.LogicalTypeDecimal,
+				Precision: int64Pointer(10), Scale: int64Pointer(2)}},
+			{Name: "created", FieldName: "created", Type: &store.ColumnType{LogicalType: store.LogicalTypeTimestamp}},
+			// value, which is not a number, falls back to string
+			{Name: "code", FieldName: "code", Type: &store.ColumnType{LogicalType: store.LogicalTypeInteger}},
+		},
+		Rows: []map[string]any{
+			{"groupName": "a", "id": int64(1), "count": "10", "price": "1.50",
+				"created": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "code": int64(7)},
+			{"groupName": "b", "id": int64(2), "count": nil, "price": nil, "created": nil, "code": "x"},
+		},
+	}
+}
+
+func assertColumnarRecord(t *testing.T, record arrow.Record) {
+	assert.Equal(t, []arrow.DataType{
+		arrow.BinaryTypes.String,
+		arrow.PrimitiveTypes.Int64,
+		arrow.PrimitiveTypes.Int64,
+		&arrow.Decimal128Type{Precision: 10, Scale: 2},
+		arrow.FixedWidthTypes.Timestamp_us,
+		arrow.BinaryTypes.String,
+	}, fieldTypes(record.Schema()))
+	assert.Equal(t, int64(2), record.NumRows())
+	assert.Equal(t, "a", record.Column(0).(*array.String).Value(0))
+	assert.Equal(t, int64(10), record.Column(2).(*array.Int64).Value(0))
+	assert.True(t, record.Column(2).IsNull(1))
+	assert.Equal(t, decimal128.FromI64(150), record.Column(3).(*array.Decimal128).Value(0))
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		record.Column(4).(*array.Timestamp).Value(0).ToTime(arrow.Microsecond))
+	assert.Equal(t, "x", record.Column(5).(*array.String).Value(1))
+}
+
+func fieldTypes(schema *arrow.Schema) []arrow.DataType {
+	types := make([]arrow.DataType, 0, len(schema.Fields()))
+	for _, field := range schema.Fields() {
+		types = append(types, field.Type)
+	}
+	return types
+}
+
+func TestWrite_Arrow(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, FormatArrow, []Sheet{{Name: "results", Data: columnarTestData()}})
+	require.NoError(t, err)
+
+	reader, err := ipc.NewReader(&buf)
+	require.NoError(t, err)
+	defer reader.Release()
+	require.True(t, reader.Next())
+	assertColumnarRecord(t, reader.Record())
+}
+
+func TestWrite_Parquet(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, FormatParquet, []Sheet{{Name: "results", Data: columnarTestData()}})
+	require.NoError(t, err)
+
+	parquetReader, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	defer parquetReader.Close() //nolint:errcheck
+	reader, err := pqarrow.NewFileReader(parquetReader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	table, err := reader.ReadTable(context.Background())
+	require.NoError(t, err)
+	defer table.Release()
+
+	tableReader := array.NewTableReader(table, -1)
+	defer tableReader.Release()
+	require.True(t, tableReader.Next())
+	assertColumnarRecord(t, tableReader.Record())
+}
+
+func TestWrite_ColumnarMultipleSheets(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, FormatParquet, []Sheet{{Name: "a", Data: columnarTestData()}, {Name: "b", Data: columnarTestData()}})
+	assert.Error(t, err)
+}
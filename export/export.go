@@ -0,0 +1,315 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+const (
+	FormatCsv  = "csv"
+	FormatTsv  = "tsv"
+	FormatXlsx = "xlsx"
+	// FormatParquet is a parquet file with a single row group
+	FormatParquet = "parquet"
+	// FormatArrow is an Apache Arrow IPC stream
+	FormatArrow = "arrow"
+)
+
+// maxSheetNameLength is a limit of xlsx sheet name.
+const maxSheetNameLength = 31
+
+func IsValidFormat(format string) bool {
+	switch format {
+	case FormatCsv, FormatTsv, FormatXlsx, FormatParquet, FormatArrow:
+		return true
+	default:
+		return false
+	}
+}
+
+// SupportsSheets returns true if format can contain multiple sheets.
+func SupportsSheets(format string) bool {
+	return format == FormatXlsx
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatCsv:
+		return "text/csv; charset=utf-8"
+	case FormatTsv:
+		return "text/tab-separated-values; charset=utf-8"
+	case FormatXlsx:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	case FormatArrow:
+		return "application/vnd.apache.arrow.stream"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Sheet is a named table. Column.Name is used as a header and Column.FieldName is used to get row values.
+type Sheet struct {
+	Name string
+	Data *store.QueryData
+}
+
+// GroupErrorsSheet returns a sheet with failed groups.
+func GroupErrorsSheet(groupErrors []store.GroupError) Sheet {
+	data := &store.QueryData{
+		Columns: []store.Column{{Name: "groupName", FieldName: "groupName"}, {Name: "error", FieldName: "error"}},
+	}
+	for _, groupError := range groupErrors {
+		data.Rows = append(data.Rows, map[string]any{"groupName": groupError.GroupName, "error": groupError.Error.Message})
+	}
+	return Sheet{Name: "errors", Data: data}
+}
+
+// Write writes sheets to w in given format. Formats without sheets support only a single sheet.
+func Write(w io.Writer, format string, sheets []Sheet) error {
+	switch format {
+	case FormatXlsx:
+		return writeXlsx(w, sheets)
+	}
+
+	if !IsValidFormat(format) {
+		return errors.Errorf("unsupported format: %s", format)
+	}
+	if len(sheets) != 1 {
+		return errors.Errorf("format %s supports only a single sheet, got: %d", format, len(sheets))
+	}
+	switch format {
+	case FormatParquet:
+		return writeParquet(w, sheets[0].Data)
+	case FormatArrow:
+		return writeArrow(w, sheets[0].Data)
+	case FormatTsv:
+		return writeDelimited(w, '\t', sheets[0].Data)
+	default:
+		return writeDelimited(w, ',', sheets[0].Data)
+	}
+}
+
+func writeDelimited(w io.Writer, separator rune, data *store.QueryData) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = separator
+
+	record := make([]string, len(data.Columns))
+	for i, column := range data.Columns {
+		record[i] = column.Name
+	}
+	err := writer.Write(record)
+	if err != nil {
+		return err
+	}
+	for _, row := range data.Rows {
+		for i, column := range data.Columns {
+			record[i] = formatValue(row[column.FieldName])
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeXlsx writes every sheet as a worksheet. Numbers, booleans and times keep their types, other values are
+// written as strings.
+func writeXlsx(w io.Writer, sheets []Sheet) error {
+	f := excelize.NewFile()
+	defer f.Close() //nolint:errcheck
+
+	names := sheetNames(sheets)
+	for i, sheet := range sheets {
+		if i == 0 {
+			err := f.SetSheetName(f.GetSheetName(0), names[i])
+			if err != nil {
+				return err
+			}
+		} else {
+			_, err := f.NewSheet(names[i])
+			if err != nil {
+				return err
+			}
+		}
+		err := writeXlsxSheet(f, names[i], sheet.Data)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write sheet: %s", names[i])
+		}
+	}
+	_, err := f.WriteTo(w)
+	return err
+}
+
+func writeXlsxSheet(f *excelize.File, name string, data *store.QueryData) error {
+	sw, err := f.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+
+	values := make([]any, len(data.Columns))
+	for i, column := range data.Columns {
+		values[i] = column.Name
+	}
+	err = sw.SetRow("A1", values)
+	if err != nil {
+		return err
+	}
+	for rowIndex, row := range data.Rows {
+		values = make([]any, len(data.Columns))
+		for i, column := range data.Columns {
+			values[i] = xlsxValue(row[column.FieldName], column.Type)
+		}
+		cell, err := excelize.CoordinatesToCellName(1, rowIndex+2)
+		if err != nil {
+			return err
+		}
+		err = sw.SetRow(cell, values)
+		if err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
+
+// maxXlsxDigits is a count of significant digits, which excel number keeps exactly.
+const maxXlsxDigits = 15
+
+// xlsxValue returns a cell value. Text values of numeric and temporal columns are converted by column logical type.
+func xlsxValue(value any, columnType *store.ColumnType) any {
+	if columnType != nil {
+		if converted, ok := convertXlsxValue(value, columnType.LogicalType); ok {
+			value = converted
+		}
+	}
+	switch v := value.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, string:
+		return value
+	case time.Time:
+		// excel does not support time zones
+		return v.UTC()
+	default:
+		return formatValue(value)
+	}
+}
+
+// convertXlsxValue converts text value(i.e. mysql DECIMAL, INT and DATETIME are returned as []byte) to a number or
+// time. False is returned if value is not a text or can not be converted. Numbers with more than maxXlsxDigits
+// significant digits are not converted, they would be rounded by excel.
+func convertXlsxValue(value any, logicalType string) (any, bool) {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case []byte:
+		if logicalType == store.LogicalTypeBinary {
+			return nil, false
+		}
+		text = string(v)
+	case time.Time:
+		return nil, false
+	case fmt.Stringer:
+		// i.e. firebird decimal.Decimal
+		text = v.String()
+	default:
+		return nil, false
+	}
+
+	switch logicalType {
+	case store.LogicalTypeInteger:
+		if significantDigits(text) > maxXlsxDigits {
+			return nil, false
+		}
+		i, err := strconv.ParseInt(text, 10, 64)
+		return i, err == nil
+	case store.LogicalTypeDecimal, store.LogicalTypeFloat:
+		if logicalType == store.LogicalTypeDecimal && significantDigits(text) > maxXlsxDigits {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		return f, err == nil
+	case store.LogicalTypeTimestamp, store.LogicalTypeDate:
+		return parseTime(text)
+	}
+	return nil, false
+}
+
+// significantDigits returns count of digits of decimal text without leading and trailing zeros.
+func significantDigits(text string) int {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, text)
+	if strings.Contains(text, ".") {
+		digits = strings.TrimRight(digits, "0")
+	}
+	return len(strings.TrimLeft(digits, "0"))
+}
+
+// sheetNames returns unique valid xlsx sheet names.
+func sheetNames(sheets []Sheet) []string {
+	names := make([]string, len(sheets))
+	used := make(map[string]bool)
+	for i, sheet := range sheets {
+		name := sanitizeSheetName(sheet.Name)
+		unique := name
+		for j := 1; used[strings.ToLower(unique)]; j++ {
+			suffix := fmt.Sprintf("_%d", j)
+			unique = truncate(name, maxSheetNameLength-len(suffix)) + suffix
+		}
+		used[strings.ToLower(unique)] = true
+		names[i] = unique
+	}
+	return names
+}
+
+func sanitizeSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = "sheet"
+	}
+	return truncate(name, maxSheetNameLength)
+}
+
+func truncate(name string, length int) string {
+	runes := []rune(name)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return name
+}
@@ -0,0 +1,168 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+func testData() *store.QueryData {
+	return &store.QueryData{
+		Columns: []store.Column{
+			{Name: "groupName", FieldName: "groupName"},
+			{Name: "id", FieldName: "id"},
+			{Name: "name", FieldName: "name"},
+			{Name: "id", FieldName: "id__1"},
+			{Name: "created", FieldName: "created"},
+			{Name: "active", FieldName: "active"},
+		},
+		Rows: []map[string]any{
+			{"groupName": "a", "id": int64(1), "name": "x, \"y\"", "id__1": 1.5,
+				"created": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "active": true},
+			{"groupName": "b", "id": int64(2), "name": nil, "id__1": nil, "created": nil, "active": false},
+		},
+	}
+}
+
+func TestWrite_Delimited(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: FormatCsv,
+			want: "groupName,id,name,id,created,active\n" +
+				"a,1,\"x, \"\"y\"\"\",1.5,2024-01-02T03:04:05Z,true\n" +
+				"b,2,,,,false\n",
+		},
+		{
+			name:   "tsv",
+			format: FormatTsv,
+			want: "groupName\tid\tname\tid\tcreated\tactive\n" +
+				"a\t1\t\"x, \"\"y\"\"\"\t1.5\t2024-01-02T03:04:05Z\ttrue\n" +
+				"b\t2\t\t\t\tfalse\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Write(&buf, tt.format, []Sheet{{Name: "results", Data: testData()}})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestWrite_DelimitedMultipleSheets(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, FormatCsv, []Sheet{{Name: "a", Data: testData()}, {Name: "b", Data: testData()}})
+	assert.Error(t, err)
+}
+
+func TestWrite_Xlsx(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, FormatXlsx, []Sheet{{Name: "a", Data: testData()}, {Name: "A", Data: testData()}})
+	require.NoError(t, err)
+
+	f, err := excelize.OpenReader(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "A_1"}, f.GetSheetList())
+
+	rows, err := f.GetRows("a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"groupName", "id", "name", "id", "created", "active"}, rows[0])
+
+	cellTypes := map[string]excelize.CellType{
+		"B2": excelize.CellTypeUnset, // numbers do not have type attribute
+		"C2": excelize.CellTypeInlineString,
+		"D2": excelize.CellTypeUnset,
+		"F2": excelize.CellTypeBool,
+	}
+	for cell, want := range cellTypes {
+		got, err := f.GetCellType("a", cell)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, cell)
+	}
+	created, err := f.GetCellValue("a", "E2", excelize.Options{RawCellValue: true})
+	require.NoError(t, err)
+	assert.Equal(t, "45293.12783564815", created)
+}
+
+func TestWrite_XlsxTextValues(t *testing.T) {
+	columnType := func(logicalType string) *store.ColumnType {
+		return &store.ColumnType{LogicalType: logicalType}
+	}
+	data := &store.QueryData{
+		Columns: []store.Column{
+			{Name: "count", FieldName: "count", Type: columnType(store.LogicalTypeInteger)},
+			{Name: "price", FieldName: "price", Type: columnType(store.LogicalTypeDecimal)},
+			{Name: "created", FieldName: "created", Type: columnType(store.LogicalTypeTimestamp)},
+			{Name: "day", FieldName: "day", Type: columnType(store.LogicalTypeDate)},
+			{Name: "big", FieldName: "big", Type: columnType(store.LogicalTypeDecimal)},
+			{Name: "code", FieldName: "code", Type: columnType(store.LogicalTypeString)},
+			{Name: "bad", FieldName: "bad", Type: columnType(store.LogicalTypeTimestamp)},
+		},
+		Rows: []map[string]any{{
+			// mysql values are returned as []byte
+			"count":   []byte("42"),
+			"price":   []byte("12.50"),
+			"created": []byte("2024-01-02 03:04:05"),
+			"day":     []byte("2024-01-02"),
+			"big":     []byte("1234567890.1234567"),
+			"code":    []byte("007"),
+			"bad":     []byte("0000-00-00 00:00:00"),
+		}},
+	}
+	var buf bytes.Buffer
+	err := Write(&buf, FormatXlsx, []Sheet{{Name: "a", Data: data}})
+	require.NoError(t, err)
+
+	f, err := excelize.OpenReader(&buf)
+	require.NoError(t, err)
+	tests := []struct {
+		cell      string
+		wantType  excelize.CellType
+		wantValue string
+	}{
+		{cell: "A2", wantType: excelize.CellTypeUnset, wantValue: "42"},
+		{cell: "B2", wantType: excelize.CellTypeUnset, wantValue: "12.5"},
+		{cell: "C2", wantType: excelize.CellTypeUnset, wantValue: "45293.12783564815"},
+		{cell: "D2", wantType: excelize.CellTypeUnset, wantValue: "45293"},
+		{cell: "E2", wantType: excelize.CellTypeInlineString, wantValue: "1234567890.1234567"},
+		{cell: "F2", wantType: excelize.CellTypeInlineString, wantValue: "007"},
+		{cell: "G2", wantType: excelize.CellTypeInlineString, wantValue: "0000-00-00 00:00:00"},
+	}
+	for _, tt := range tests {
+		cellType, err := f.GetCellType("a", tt.cell)
+		require.NoError(t, err)
+		assert.Equal(t, tt.wantType, cellType, tt.cell)
+		value, err := f.GetCellValue("a", tt.cell, excelize.Options{RawCellValue: true})
+		require.NoError(t, err)
+		assert.Equal(t, tt.wantValue, value, tt.cell)
+	}
+}
+
+func TestSheetNames(t *testing.T) {
+	sheets := []Sheet{
+		{Name: "env/1"},
+		{Name: "ENV/1"},
+		{Name: ""},
+		{Name: "a very long group name which exceeds limit"},
+		{Name: "a very long group name which exceeds limit"},
+	}
+	assert.Equal(t, []string{
+		"env_1",
+		"ENV_1_1",
+		"sheet",
+		"a very long group name which ex",
+		"a very long group name which _1",
+	}, sheetNames(sheets))
+}
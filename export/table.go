@@ -0,0 +1,75 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+// tableValueReplacer keeps every table row on a single line.
+var tableValueReplacer = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\r`, "\t", " ")
+
+// WriteTable writes data as a text table with aligned columns, which is readable in terminal. Null values are
+// written as NULL.
+func WriteTable(w io.Writer, data *store.QueryData) error {
+	cells := make([][]string, 0, len(data.Rows)+1)
+	header := make([]string, len(data.Columns))
+	for i, column := range data.Columns {
+		header[i] = tableValueReplacer.Replace(column.Name)
+	}
+	cells = append(cells, header)
+	for _, row := range data.Rows {
+		record := make([]string, len(data.Columns))
+		for i, column := range data.Columns {
+			value := row[column.FieldName]
+			if value == nil {
+				record[i] = "NULL"
+			} else {
+				record[i] = tableValueReplacer.Replace(formatValue(value))
+			}
+		}
+		cells = append(cells, record)
+	}
+
+	widths := make([]int, len(data.Columns))
+	for _, record := range cells {
+		for i, cell := range record {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, record := range cells {
+		writeTableLine(bw, record, widths, " | ")
+		if i == 0 {
+			separators := make([]string, len(widths))
+			for j, width := range widths {
+				separators[j] = strings.Repeat("-", width)
+			}
+			writeTableLine(bw, separators, widths, "-+-")
+		}
+	}
+	if len(data.Rows) == 1 {
+		_, _ = fmt.Fprintln(bw, "(1 row)")
+	} else {
+		_, _ = fmt.Fprintf(bw, "(%d rows)\n", len(data.Rows))
+	}
+	return bw.Flush()
+}
+
+func writeTableLine(w *bufio.Writer, record []string, widths []int, separator string) {
+	for i, cell := range record {
+		if i > 0 {
+			_, _ = w.WriteString(separator)
+		}
+		_, _ = w.WriteString(cell)
+		if i < len(record)-1 {
+			_, _ = w.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+	}
+	_ = w.WriteByte('\n')
+}
@@ -0,0 +1,31 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteTable(&buf, testData())
+	require.NoError(t, err)
+	assert.Equal(t, `groupName | id | name   | id   | created              | active
+----------+----+--------+------+----------------------+-------
+a         | 1  | x, "y" | 1.5  | 2024-01-02T03:04:05Z | true
+b         | 2  | NULL   | NULL | NULL                 | false
+(2 rows)
+`, buf.String())
+
+	buf.Reset()
+	err = WriteTable(&buf, &store.QueryData{
+		Columns: []store.Column{{Name: "text", FieldName: "text"}},
+		Rows:    []map[string]any{{"text": "žalia\nline"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "text\n-----------\nžalia\\nline\n(1 row)\n", buf.String())
+}
@@ -0,0 +1,17 @@
+package list
+
+import (
+	"strings"
+)
+
+// Split splits comma separated value and returns trimmed non-empty items
+func Split(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
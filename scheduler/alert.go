@@ -0,0 +1,444 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+const (
+	// ConditionRowCount compares count of result rows with threshold
+	ConditionRowCount = "rowCount"
+	// ConditionValue compares values of result column with threshold. Rule fires if any row matches
+	ConditionValue = "value"
+	// ConditionError fires if query failed
+	ConditionError = "error"
+)
+
+const (
+	AlertStatusFiring   = "firing"
+	AlertStatusResolved = "resolved"
+	AlertStatusOk       = "ok"
+)
+
+type AlertsConfig struct {
+	Rules     []RuleConfig
+	Notifiers []NotifierConfig
+	// Silences are silencing windows, which are always active in given time range
+	Silences []Silence
+}
+
+type RuleConfig struct {
+	// Name is a unique name of rule
+	Name string
+	// Query is a name of evaluated scheduled query
+	Query string
+	// Condition is ConditionRowCount, ConditionValue or ConditionError
+	Condition string
+	// Column is a compared column of ConditionValue
+	Column string
+	// Operator is one of >, >=, <, <=, ==, != and is not used by ConditionError
+	Operator  string
+	Threshold float64
+	// Notifiers are names of notified notifiers. Default: all notifiers
+	Notifiers []string
+}
+
+// Silence suppresses notifications of rule and group name in time range. Empty Rule or GroupName matches all.
+type Silence struct {
+	ID        int64     `json:"id"`
+	Rule      string    `json:"rule"`
+	GroupName string    `json:"groupName"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	Comment   string    `json:"comment"`
+}
+
+func (s Silence) matches(rule string, groupName string, at time.Time) bool {
+	return (s.Rule == "" || s.Rule == rule) && (s.GroupName == "" || s.GroupName == groupName) &&
+		!at.Before(s.StartsAt) && at.Before(s.EndsAt)
+}
+
+// Alert is a state of rule in a single database.
+type Alert struct {
+	Rule      string `json:"rule"`
+	Query     string `json:"query"`
+	GroupName string `json:"groupName"`
+	// Status is AlertStatusFiring or AlertStatusOk
+	Status string `json:"status"`
+	// Since is a time of the run, which changed status
+	Since time.Time `json:"since"`
+	// Value is a row count or matched column value of the last evaluation
+	Value *float64 `json:"value"`
+	Error string   `json:"error"`
+	// Notified is true if firing notification was sent
+	Notified  bool      `json:"notified"`
+	Silenced  bool      `json:"silenced"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Notification is sent when alert starts firing and when firing alert is resolved.
+type Notification struct {
+	// Status is AlertStatusFiring or AlertStatusResolved
+	Status      string    `json:"status"`
+	Rule        string    `json:"rule"`
+	Query       string    `json:"query"`
+	GroupType   string    `json:"groupType"`
+	GroupName   string    `json:"groupName"`
+	Description string    `json:"description"`
+	Value       *float64  `json:"value"`
+	Error       string    `json:"error"`
+	RunID       int64     `json:"runId"`
+	Since       time.Time `json:"since"`
+	At          time.Time `json:"at"`
+}
+
+func (n Notification) Summary() string {
+	return fmt.Sprintf("[%s] %s %s/%s: %s", strings.ToUpper(n.Status), n.Rule, n.GroupType, n.GroupName, n.Description)
+}
+
+type rule struct {
+	config    RuleConfig
+	notifiers []notifier
+}
+
+// evaluate returns whether rule fires for result, compared value and error text of query.
+func (r rule) evaluate(result store.GroupQueryResult) (bool, *float64, string) {
+	var errorText string
+	if result.Error != nil {
+		errorText = result.Error.Message
+	}
+	switch r.config.Condition {
+	case ConditionError:
+		return result.Error != nil, nil, errorText
+	case ConditionRowCount:
+		rowCount := 0.0
+		if result.Data != nil {
+			rowCount = float64(len(result.Data.Rows))
+		}
+		return compare(rowCount, r.config.Operator, r.config.Threshold), &rowCount, errorText
+	case ConditionValue:
+		if result.Data == nil {
+			return false, nil, errorText
+		}
+		for _, column := range result.Data.Columns {
+			if !strings.EqualFold(column.Name, r.config.Column) {
+				continue
+			}
+			for _, row := range result.Data.Rows {
+				value, ok := toFloat(row[column.FieldName])
+				if ok && compare(value, r.config.Operator, r.config.Threshold) {
+					return true, &value, errorText
+				}
+			}
+		}
+	}
+	return false, nil, errorText
+}
+
+// description describes rule condition and evaluated value.
+func (r rule) description(value *float64, errorText string) string {
+	if r.config.Condition == ConditionError {
+		if errorText == "" {
+			return "query succeeded"
+		}
+		return "query failed: " + errorText
+	}
+	subject := r.config.Condition
+	if r.config.Condition == ConditionValue {
+		subject = r.config.Column
+	}
+	condition := fmt.Sprintf("%s %s %s", subject, r.config.Operator, formatFloat(r.config.Threshold))
+	if value == nil {
+		return "no value matches " + condition
+	}
+	return fmt.Sprintf("%s is %s, condition: %s", subject, formatFloat(*value), condition)
+}
+
+func compare(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
+
+func isValidOperator(operator string) bool {
+	switch operator {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil && !math.IsNaN(f)
+	case []byte:
+		return toFloat(string(v))
+	}
+	return 0, false
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// alerts evaluates rules of scheduled queries and notifies about alert state changes. Alert states are stored, so
+// notifications are not repeated after restart.
+type alerts struct {
+	m        *sync.Mutex
+	storage  *storage
+	rules    []rule
+	silences []Silence
+}
+
+func newAlerts(config AlertsConfig, queries map[string]*scheduledQuery, storage *storage) (*alerts, error) {
+	notifiers := make(map[string]notifier, len(config.Notifiers))
+	allNotifiers := make([]notifier, 0, len(config.Notifiers))
+	for _, notifierConfig := range config.Notifiers {
+		if _, ok := notifiers[notifierConfig.Name]; ok {
+			return nil, errors.Errorf("duplicate notifier name: %s", notifierConfig.Name)
+		}
+		n, err := newNotifier(notifierConfig)
+		if err != nil {
+			return nil, err
+		}
+		notifiers[notifierConfig.Name] = n
+		allNotifiers = append(allNotifiers, n)
+	}
+
+	a := &alerts{m: &sync.Mutex{}, storage: storage, silences: config.Silences}
+	names := make(map[string]bool, len(config.Rules))
+	for _, ruleConfig := range config.Rules {
+		err := validateRuleConfig(ruleConfig, queries)
+		if err != nil {
+			return nil, err
+		}
+		if names[ruleConfig.Name] {
+			return nil, errors.Errorf("duplicate alert rule name: %s", ruleConfig.Name)
+		}
+		names[ruleConfig.Name] = true
+
+		r := rule{config: ruleConfig, notifiers: allNotifiers}
+		if len(ruleConfig.Notifiers) > 0 {
+			r.notifiers = make([]notifier, 0, len(ruleConfig.Notifiers))
+			for _, name := range ruleConfig.Notifiers {
+				n, ok := notifiers[name]
+				if !ok {
+					return nil, errors.Errorf("unknown notifier of alert rule %s: %s", ruleConfig.Name, name)
+				}
+				r.notifiers = append(r.notifiers, n)
+			}
+		}
+		a.rules = append(a.rules, r)
+	}
+	return a, nil
+}
+
+func validateRuleConfig(config RuleConfig, queries map[string]*scheduledQuery) error {
+	if config.Name == "" {
+		return errors.New("alert rule name is empty")
+	}
+	if _, ok := queries[config.Query]; !ok {
+		return errors.Errorf("unknown scheduled query of alert rule %s: %s", config.Name, config.Query)
+	}
+	switch config.Condition {
+	case ConditionError:
+		return nil
+	case ConditionValue:
+		if config.Column == "" {
+			return errors.Errorf("column of alert rule is required. name=%s", config.Name)
+		}
+	case ConditionRowCount:
+	default:
+		return errors.Errorf("unknown condition of alert rule %s: %s", config.Name, config.Condition)
+	}
+	if !isValidOperator(config.Operator) {
+		return errors.Errorf("unknown operator of alert rule %s: %s", config.Name, config.Operator)
+	}
+	return nil
+}
+
+// evaluate evaluates rules of query for every group of run results and sends notifications of changed alerts.
+// Firing notification is sent once per alert. If alert was silenced, it is sent after silence ends if alert is still
+// firing. Resolve notification is sent only if firing notification was sent.
+func (a *alerts) evaluate(ctx context.Context, query QueryConfig, run Run, results []store.GroupQueryResult) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	silences, err := a.currentSilences(ctx, run.StartedAt)
+	if err != nil {
+		return err
+	}
+	for _, r := range a.rules {
+		if r.config.Query != query.Name {
+			continue
+		}
+		for _, result := range results {
+			// failed query is only evaluated by error condition, other rules keep their state
+			if result.Error != nil && r.config.Condition != ConditionError {
+				continue
+			}
+			err = a.evaluateGroup(ctx, r, query, run, result, silences)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *alerts) evaluateGroup(ctx context.Context, r rule, query QueryConfig, run Run, result store.GroupQueryResult,
+	silences []Silence) error {
+	firing, value, errorText := r.evaluate(result)
+	previous, err := a.storage.alert(ctx, r.config.Name, result.GroupName)
+	if err != nil {
+		return err
+	}
+
+	alert := Alert{
+		Rule:      r.config.Name,
+		Query:     query.Name,
+		GroupName: result.GroupName,
+		Status:    AlertStatusOk,
+		Since:     run.StartedAt,
+		Value:     value,
+		Error:     errorText,
+		UpdatedAt: run.StartedAt,
+	}
+	if firing {
+		alert.Status = AlertStatusFiring
+	}
+	if previous != nil && previous.Status == alert.Status {
+		alert.Since = previous.Since
+		alert.Notified = previous.Notified
+	}
+	for _, silence := range silences {
+		if silence.matches(r.config.Name, result.GroupName, run.StartedAt) {
+			alert.Silenced = true
+			break
+		}
+	}
+
+	notification := Notification{
+		Rule:        r.config.Name,
+		Query:       query.Name,
+		GroupType:   query.GroupType,
+		GroupName:   result.GroupName,
+		Description: r.description(value, errorText),
+		Value:       value,
+		Error:       errorText,
+		RunID:       run.ID,
+		Since:       alert.Since,
+		At:          run.StartedAt,
+	}
+	switch {
+	case firing && !alert.Notified && !alert.Silenced:
+		notification.Status = AlertStatusFiring
+		alert.Notified = a.notify(ctx, r, notification)
+	// silences suppress only firing notifications, receivers of firing notification are always notified of resolve
+	case !firing && previous != nil && previous.Status == AlertStatusFiring && previous.Notified:
+		notification.Status = AlertStatusResolved
+		a.notify(ctx, r, notification)
+	}
+	return a.storage.saveAlert(ctx, alert)
+}
+
+// notify sends notification to notifiers of rule and returns true if any notifier succeeded.
+func (a *alerts) notify(ctx context.Context, r rule, notification Notification) bool {
+	sent := false
+	for _, n := range r.notifiers {
+		err := n.notify(ctx, notification)
+		if err != nil {
+			log.Error().Err(err).
+				Str("notifier", n.name()).
+				Str("rule", notification.Rule).
+				Str("groupName", notification.GroupName).
+				Msg("failed to send alert notification")
+			continue
+		}
+		sent = true
+	}
+	return sent
+}
+
+// currentSilences returns config and stored silences, which end after given time.
+func (a *alerts) currentSilences(ctx context.Context, at time.Time) ([]Silence, error) {
+	stored, err := a.storage.silences(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	silences := make([]Silence, 0, len(a.silences)+len(stored))
+	for _, silence := range a.silences {
+		if at.Before(silence.EndsAt) {
+			silences = append(silences, silence)
+		}
+	}
+	return append(silences, stored...), nil
+}
+
+func (a *alerts) hasRule(name string) bool {
+	for _, r := range a.rules {
+		if r.config.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns stored alert states of configured rules.
+func (a *alerts) list(ctx context.Context) ([]Alert, error) {
+	alerts, err := a.storage.alerts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configured := make(map[string]bool, len(a.rules))
+	for _, r := range a.rules {
+		configured[r.config.Name] = true
+	}
+	result := make([]Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		if configured[alert.Rule] {
+			result = append(result, alert)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,439 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+func TestRule_evaluate(t *testing.T) {
+	data := &store.QueryData{
+		Columns: []store.Column{{Name: "name", FieldName: "name"}, {Name: "pending", FieldName: "pending"}},
+		Rows: []map[string]any{
+			{"name": "a", "pending": int64(10)},
+			{"name": "b", "pending": "150.5"},
+			{"name": "c", "pending": nil},
+		},
+	}
+	value := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name       string
+		config     RuleConfig
+		result     store.GroupQueryResult
+		wantFiring bool
+		wantValue  *float64
+	}{
+		{
+			name:       "row count fires",
+			config:     RuleConfig{Condition: ConditionRowCount, Operator: ">", Threshold: 0},
+			result:     store.GroupQueryResult{Data: data},
+			wantFiring: true,
+			wantValue:  value(3),
+		},
+		{
+			name:      "row count without data",
+			config:    RuleConfig{Condition: ConditionRowCount, Operator: ">", Threshold: 0},
+			result:    store.GroupQueryResult{},
+			wantValue: value(0),
+		},
+		{
+			name:       "value fires on first matching row",
+			config:     RuleConfig{Condition: ConditionValue, Column: "PENDING", Operator: ">", Threshold: 100},
+			result:     store.GroupQueryResult{Data: data},
+			wantFiring: true,
+			wantValue:  value(150.5),
+		},
+		{
+			name:   "value does not match",
+			config: RuleConfig{Condition: ConditionValue, Column: "pending", Operator: ">=", Threshold: 200},
+			result: store.GroupQueryResult{Data: data},
+		},
+		{
+			name:   "value of unknown column",
+			config: RuleConfig{Condition: ConditionValue, Column: "unknown", Operator: "!=", Threshold: 0},
+			result: store.GroupQueryResult{Data: data},
+		},
+		{
+			name:       "error fires",
+			config:     RuleConfig{Condition: ConditionError},
+			result:     store.GroupQueryResult{Error: &store.QueryError{Message: "failed"}},
+			wantFiring: true,
+		},
+		{
+			name:   "error does not fire",
+			config: RuleConfig{Condition: ConditionError},
+			result: store.GroupQueryResult{Data: data},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firing, value, _ := rule{config: tt.config}.evaluate(tt.result)
+			assert.Equal(t, tt.wantFiring, firing)
+			assert.Equal(t, tt.wantValue, value)
+		})
+	}
+}
+
+func TestNew_alertsConfig(t *testing.T) {
+	queries := []QueryConfig{{Name: "q", Cron: "@hourly", GroupType: "t", Query: "SELECT 1"}}
+	tests := []struct {
+		name    string
+		alerts  AlertsConfig
+		wantErr string
+	}{
+		{
+			name:    "unknown query",
+			alerts:  AlertsConfig{Rules: []RuleConfig{{Name: "r", Query: "unknown", Condition: ConditionError}}},
+			wantErr: "unknown scheduled query of alert rule r: unknown",
+		},
+		{
+			name:    "unknown condition",
+			alerts:  AlertsConfig{Rules: []RuleConfig{{Name: "r", Query: "q", Condition: "avg"}}},
+			wantErr: "unknown condition of alert rule r: avg",
+		},
+		{
+			name:    "unknown operator",
+			alerts:  AlertsConfig{Rules: []RuleConfig{{Name: "r", Query: "q", Condition: ConditionRowCount, Operator: "=>"}}},
+			wantErr: "unknown operator of alert rule r: =>",
+		},
+		{
+			name:    "value without column",
+			alerts:  AlertsConfig{Rules: []RuleConfig{{Name: "r", Query: "q", Condition: ConditionValue, Operator: ">"}}},
+			wantErr: "column of alert rule is required. name=r",
+		},
+		{
+			name: "unknown notifier",
+			alerts: AlertsConfig{
+				Rules: []RuleConfig{{Name: "r", Query: "q", Condition: ConditionError, Notifiers: []string{"n"}}},
+			},
+			wantErr: "unknown notifier of alert rule r: n",
+		},
+		{
+			name:    "invalid notifier",
+			alerts:  AlertsConfig{Notifiers: []NotifierConfig{{Name: "n", Type: NotifierSmtp, Host: "localhost"}}},
+			wantErr: "host, port, from and to of notifier are required. name=n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(Config{DatabasePath: ":memory:", Queries: queries, Alerts: tt.alerts}, store.DatabaseStoreMock{})
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+// webhookRecorder is a HTTP stand-in of webhook receiver, which records request bodies.
+type webhookRecorder struct {
+	m      sync.Mutex
+	bodies []string
+}
+
+func (rec *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	rec.m.Lock()
+	rec.bodies = append(rec.bodies, string(b))
+	rec.m.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rec *webhookRecorder) take() []string {
+	rec.m.Lock()
+	defer rec.m.Unlock()
+	bodies := rec.bodies
+	rec.bodies = nil
+	return bodies
+}
+
+func (rec *webhookRecorder) notifications(t *testing.T) []Notification {
+	var notifications []Notification
+	for _, body := range rec.take() {
+		var notification Notification
+		require.NoError(t, json.Unmarshal([]byte(body), &notification))
+		notifications = append(notifications, notification)
+	}
+	return notifications
+}
+
+func TestScheduler_alerts(t *testing.T) {
+	webhook := &webhookRecorder{}
+	webhookServer := httptest.NewServer(webhook)
+	defer webhookServer.Close()
+	slack := &webhookRecorder{}
+	slackServer := httptest.NewServer(slack)
+	defer slackServer.Close()
+
+	rows := map[string]int{"a": 0, "b": 0}
+	databaseStore := store.DatabaseStoreMock{
+		QueryMultipleDatabasesFunc: func(ctx context.Context, groupType string, query string, options store.QueryOptions) []store.GroupQueryResult {
+			var results []store.GroupQueryResult
+			for _, groupName := range []string{"a", "b"} {
+				data := &store.QueryData{Columns: []store.Column{{Name: "id", FieldName: "id"}}, Rows: []map[string]any{}}
+				for i := 0; i < rows[groupName]; i++ {
+					data.Rows = append(data.Rows, map[string]any{"id": int64(i)})
+				}
+				results = append(results, store.GroupQueryResult{GroupName: groupName, Data: data})
+			}
+			return results
+		},
+	}
+	s := newTestScheduler(t, Config{
+		Queries: []QueryConfig{{Name: "q", Cron: "@hourly", GroupType: "t", Query: "SELECT id FROM failed_jobs"}},
+		Alerts: AlertsConfig{
+			Rules: []RuleConfig{{Name: "failed-jobs", Query: "q", Condition: ConditionRowCount, Operator: ">", Threshold: 0}},
+			Notifiers: []NotifierConfig{
+				{Name: "webhook", Type: NotifierWebhook, URL: webhookServer.URL},
+				{Name: "slack", Type: NotifierSlack, URL: slackServer.URL},
+			},
+		},
+	}, databaseStore)
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	run := func() Run {
+		t.Helper()
+		r, err := s.Run(ctx, "q")
+		require.NoError(t, err)
+		now = now.Add(time.Hour)
+		return r
+	}
+
+	run()
+	assert.Empty(t, webhook.take())
+
+	rows["a"] = 2
+	firingRun := run()
+	notifications := webhook.notifications(t)
+	require.Len(t, notifications, 1)
+	value := 2.0
+	assert.Equal(t, Notification{
+		Status:      AlertStatusFiring,
+		Rule:        "failed-jobs",
+		Query:       "q",
+		GroupType:   "t",
+		GroupName:   "a",
+		Description: "rowCount is 2, condition: rowCount > 0",
+		Value:       &value,
+		RunID:       firingRun.ID,
+		Since:       firingRun.StartedAt,
+		At:          firingRun.StartedAt,
+	}, notifications[0])
+	slackBodies := slack.take()
+	require.Len(t, slackBodies, 1)
+	var slackMessage struct {
+		Text string
+	}
+	require.NoError(t, json.Unmarshal([]byte(slackBodies[0]), &slackMessage))
+	assert.Equal(t, "[FIRING] failed-jobs t/a: rowCount is 2, condition: rowCount > 0", slackMessage.Text)
+
+	// alert is not repeated while it is firing
+	rows["a"] = 3
+	run()
+	assert.Empty(t, webhook.take())
+
+	rows["a"] = 0
+	resolvedRun := run()
+	notifications = webhook.notifications(t)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, AlertStatusResolved, notifications[0].Status)
+	assert.Equal(t, "a", notifications[0].GroupName)
+	assert.Equal(t, resolvedRun.StartedAt, notifications[0].Since)
+
+	alerts, err := s.Alerts(ctx)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "a", alerts[0].GroupName)
+	assert.Equal(t, AlertStatusOk, alerts[0].Status)
+
+	// silenced alert is notified after silence ends
+	silence, err := s.AddSilence(ctx, Silence{Rule: "failed-jobs", GroupName: "b", EndsAt: now.Add(30 * time.Minute)})
+	require.NoError(t, err)
+	assert.NotZero(t, silence.ID)
+	silences, err := s.Silences(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Silence{silence}, silences)
+
+	rows["b"] = 1
+	run()
+	assert.Empty(t, webhook.take())
+	alerts, err = s.Alerts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, AlertStatusFiring, alerts[1].Status)
+	assert.True(t, alerts[1].Silenced)
+	assert.False(t, alerts[1].Notified)
+
+	run()
+	notifications = webhook.notifications(t)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, AlertStatusFiring, notifications[0].Status)
+	assert.Equal(t, "b", notifications[0].GroupName)
+
+	// notified alert, which resolves during silence, is notified of resolve
+	_, err = s.AddSilence(ctx, Silence{GroupName: "b", EndsAt: now.Add(30 * time.Minute)})
+	require.NoError(t, err)
+	rows["b"] = 0
+	run()
+	notifications = webhook.notifications(t)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, AlertStatusResolved, notifications[0].Status)
+	assert.Equal(t, "b", notifications[0].GroupName)
+
+	_, err = s.AddSilence(ctx, Silence{Rule: "unknown", EndsAt: now.Add(time.Hour)})
+	assert.EqualError(t, err, "unknown alert rule: unknown")
+	_, err = s.AddSilence(ctx, Silence{EndsAt: now.Add(-time.Hour)})
+	assert.EqualError(t, err, "silence must end after it starts and in the future")
+}
+
+func TestScheduler_alertsAreNotRepeatedAfterRestart(t *testing.T) {
+	webhook := &webhookRecorder{}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	failed := true
+	databaseStore := store.DatabaseStoreMock{
+		QueryMultipleDatabasesFunc: func(ctx context.Context, groupType string, query string, options store.QueryOptions) []store.GroupQueryResult {
+			result := store.GroupQueryResult{GroupName: "a"}
+			if failed {
+				result.Error = &store.QueryError{Message: "connection refused"}
+			}
+			return []store.GroupQueryResult{result}
+		},
+	}
+	config := Config{
+		DatabasePath: t.TempDir() + "/scheduler.db",
+		Queries:      []QueryConfig{{Name: "q", Cron: "@hourly", GroupType: "t", Query: "SELECT 1"}},
+		Alerts: AlertsConfig{
+			Rules:     []RuleConfig{{Name: "errored", Query: "q", Condition: ConditionError}},
+			Notifiers: []NotifierConfig{{Name: "webhook", Type: NotifierWebhook, URL: server.URL}},
+		},
+	}
+	s, err := New(config, databaseStore)
+	require.NoError(t, err)
+	_, err = s.Run(context.Background(), "q")
+	require.NoError(t, err)
+	require.NoError(t, s.Stop())
+	notifications := webhook.notifications(t)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "query failed: connection refused", notifications[0].Description)
+
+	s = newTestScheduler(t, config, databaseStore)
+	_, err = s.Run(context.Background(), "q")
+	require.NoError(t, err)
+	assert.Empty(t, webhook.take())
+
+	failed = false
+	_, err = s.Run(context.Background(), "q")
+	require.NoError(t, err)
+	notifications = webhook.notifications(t)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, AlertStatusResolved, notifications[0].Status)
+	assert.Equal(t, "query succeeded", notifications[0].Description)
+}
+
+// startSmtpServer starts a minimal SMTP stand-in, which sends received messages to returned channel.
+func startSmtpServer(t *testing.T) (int, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			handleSmtpConn(conn, messages)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, messages
+}
+
+func handleSmtpConn(conn net.Conn, messages chan<- string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	write("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			write("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			write("354 end data with <CR><LF>.<CR><LF>")
+			var message strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				message.WriteString(dataLine)
+			}
+			messages <- message.String()
+			write("250 ok")
+		case strings.HasPrefix(command, "QUIT"):
+			write("221 bye")
+			return
+		default:
+			write("250 ok")
+		}
+	}
+}
+
+func TestSmtpNotifier(t *testing.T) {
+	port, messages := startSmtpServer(t)
+	n, err := newNotifier(NotifierConfig{
+		Name: "mail",
+		Type: NotifierSmtp,
+		Host: "127.0.0.1",
+		Port: port,
+		From: "mdb-tool@example.com",
+		To:   []string{"ops@example.com", "dba@example.com"},
+	})
+	require.NoError(t, err)
+
+	at := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	err = n.notify(context.Background(), Notification{
+		Status:      AlertStatusFiring,
+		Rule:        "failed-jobs",
+		Query:       "q",
+		GroupType:   "t",
+		GroupName:   "a",
+		Description: "rowCount is 2, condition: rowCount > 0",
+		RunID:       7,
+		Since:       at,
+		At:          at,
+	})
+	require.NoError(t, err)
+
+	select {
+	case message := <-messages:
+		assert.Contains(t, message, "To: ops@example.com, dba@example.com\r\n")
+		assert.Contains(t, message, "Subject: [FIRING] failed-jobs t/a: rowCount is 2, condition: rowCount > 0\r\n")
+		assert.Contains(t, message, "Run: 7\r\n")
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+}
@@ -0,0 +1,233 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/encoding/json"
+
+	"github.com/minlau/mdb-tool/internal/utils/closer"
+)
+
+const (
+	// NotifierWebhook posts Notification as JSON
+	NotifierWebhook = "webhook"
+	// NotifierSlack posts Slack incoming webhook compatible JSON message
+	NotifierSlack = "slack"
+	// NotifierSmtp sends email
+	NotifierSmtp = "smtp"
+)
+
+const defaultNotifierTimeout = 10 * time.Second
+
+type NotifierConfig struct {
+	// Name is a unique name of notifier, used by RuleConfig.Notifiers
+	Name string
+	// Type is NotifierWebhook, NotifierSlack or NotifierSmtp
+	Type string
+	// URL is a webhook URL of NotifierWebhook and NotifierSlack
+	URL string
+	// Headers are additional HTTP headers of NotifierWebhook(i.e. Authorization)
+	Headers map[string]string
+	// Host and Port are SMTP server address of NotifierSmtp. STARTTLS is used if server supports it
+	Host string
+	Port int
+	// Username and Password are used for PLAIN authentication if Username is set
+	Username string
+	Password string
+	From     string
+	To       []string
+	// TimeoutInSeconds is a timeout of a single notification. Default: 10
+	TimeoutInSeconds int
+}
+
+type notifier interface {
+	name() string
+	notify(ctx context.Context, notification Notification) error
+}
+
+func newNotifier(config NotifierConfig) (notifier, error) {
+	if config.Name == "" {
+		return nil, errors.New("notifier name is empty")
+	}
+	timeout := defaultNotifierTimeout
+	if config.TimeoutInSeconds > 0 {
+		timeout = time.Duration(config.TimeoutInSeconds) * time.Second
+	}
+	switch config.Type {
+	case NotifierWebhook, NotifierSlack:
+		if config.URL == "" {
+			return nil, errors.Errorf("url of notifier is required. name=%s", config.Name)
+		}
+		return &webhookNotifier{config: config, client: &http.Client{Timeout: timeout}}, nil
+	case NotifierSmtp:
+		if config.Host == "" || config.Port == 0 || config.From == "" || len(config.To) == 0 {
+			return nil, errors.Errorf("host, port, from and to of notifier are required. name=%s", config.Name)
+		}
+		return &smtpNotifier{config: config, timeout: timeout}, nil
+	default:
+		return nil, errors.Errorf("unknown type of notifier %s: %s", config.Name, config.Type)
+	}
+}
+
+type webhookNotifier struct {
+	config NotifierConfig
+	client *http.Client
+}
+
+func (n *webhookNotifier) name() string {
+	return n.config.Name
+}
+
+func (n *webhookNotifier) notify(ctx context.Context, notification Notification) error {
+	var body any = notification
+	if n.config.Type == NotifierSlack {
+		body = slackMessage(notification)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode notification")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.config.Headers {
+		req.Header.Set(key, value)
+	}
+	res, err := n.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+	defer closer.Handle(res.Body, "response body")
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("unexpected response status: %d", res.StatusCode)
+	}
+	return nil
+}
+
+// slackMessage returns message of Slack incoming webhook. Mattermost and other compatible services accept it too.
+func slackMessage(notification Notification) map[string]any {
+	color := "danger"
+	if notification.Status == AlertStatusResolved {
+		color = "good"
+	}
+	return map[string]any{
+		"text": notification.Summary(),
+		"attachments": []map[string]any{{
+			"color": color,
+			"fields": []map[string]any{
+				{"title": "Query", "value": notification.Query, "short": true},
+				{"title": "Group", "value": notification.GroupType + "/" + notification.GroupName, "short": true},
+				{"title": "Since", "value": notification.Since.Format(time.RFC3339), "short": true},
+				{"title": "Run", "value": strconv.FormatInt(notification.RunID, 10), "short": true},
+			},
+		}},
+	}
+}
+
+type smtpNotifier struct {
+	config  NotifierConfig
+	timeout time.Duration
+}
+
+func (n *smtpNotifier) name() string {
+	return n.config.Name
+}
+
+func (n *smtpNotifier) notify(ctx context.Context, notification Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.config.Host, strconv.Itoa(n.config.Port)))
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to smtp server")
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return errors.Wrap(err, "failed to create smtp client")
+	}
+	// Close returns error after successful Quit, which closes connection too
+	defer func() { _ = c.Close() }()
+	err = n.send(c, notification)
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (n *smtpNotifier) send(c *smtp.Client, notification Notification) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err := c.StartTLS(&tls.Config{ServerName: n.config.Host})
+		if err != nil {
+			return errors.Wrap(err, "failed to start tls")
+		}
+	}
+	if n.config.Username != "" {
+		err := c.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host))
+		if err != nil {
+			return errors.Wrap(err, "failed to authenticate")
+		}
+	}
+	err := c.Mail(n.config.From)
+	if err != nil {
+		return errors.Wrap(err, "failed to set sender")
+	}
+	for _, to := range n.config.To {
+		err = c.Rcpt(to)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set recipient %s", to)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "failed to start message")
+	}
+	_, err = w.Write(n.message(notification))
+	if err != nil {
+		_ = w.Close()
+		return errors.Wrap(err, "failed to write message")
+	}
+	return errors.Wrap(w.Close(), "failed to send message")
+}
+
+func (n *smtpNotifier) message(notification Notification) []byte {
+	var b bytes.Buffer
+	_, _ = fmt.Fprintf(&b, "From: %s\r\n", n.config.From)
+	_, _ = fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	_, _ = fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(notification.Summary()))
+	_, _ = fmt.Fprintf(&b, "Date: %s\r\n", notification.At.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	_, _ = fmt.Fprintf(&b, "%s\r\n\r\n", notification.Description)
+	_, _ = fmt.Fprintf(&b, "Status: %s\r\n", notification.Status)
+	_, _ = fmt.Fprintf(&b, "Rule: %s\r\n", notification.Rule)
+	_, _ = fmt.Fprintf(&b, "Query: %s\r\n", notification.Query)
+	_, _ = fmt.Fprintf(&b, "Group: %s/%s\r\n", notification.GroupType, notification.GroupName)
+	_, _ = fmt.Fprintf(&b, "Since: %s\r\n", notification.Since.Format(time.RFC3339))
+	_, _ = fmt.Fprintf(&b, "Run: %d\r\n", notification.RunID)
+	return b.Bytes()
+}
+
+// headerValue keeps header on a single line.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,325 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+const (
+	defaultDatabasePath     = "scheduler.db"
+	defaultRetentionInHours = 7 * 24
+)
+
+var ErrRunNotFound = errors.New("run not found")
+
+type Config struct {
+	// DatabasePath is a path of SQLite database file where run snapshots are stored. Default: scheduler.db
+	DatabasePath string
+	Queries      []QueryConfig
+	// Alerts are rules evaluated on results of every run
+	Alerts AlertsConfig
+}
+
+type QueryConfig struct {
+	// Name is a unique name of scheduled query
+	Name string
+	// Cron is a standard 5 field cron expression or a descriptor(i.e. @hourly, @every 10m)
+	Cron      string
+	GroupType string
+	Query     string
+	// Schema is a default schema of query execution
+	Schema string
+	// RetentionInHours is how long runs are kept. Default: 168(7 days)
+	RetentionInHours int
+	// TimeoutInSeconds is a timeout of a single run. No timeout if not set
+	TimeoutInSeconds int
+}
+
+// Query is a scheduled query with its schedule state.
+type Query struct {
+	Name             string    `json:"name"`
+	Cron             string    `json:"cron"`
+	GroupType        string    `json:"groupType"`
+	Query            string    `json:"query"`
+	Schema           string    `json:"schema"`
+	RetentionInHours int       `json:"retentionInHours"`
+	NextRunAt        time.Time `json:"nextRunAt"`
+	LastRun          *Run      `json:"lastRun"`
+}
+
+// Run is a single execution of scheduled query.
+type Run struct {
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	StartedAt        time.Time `json:"startedAt"`
+	FinishedAt       time.Time `json:"finishedAt"`
+	GroupCount       int       `json:"groupCount"`
+	FailedGroupCount int       `json:"failedGroupCount"`
+}
+
+// Snapshot is a run with its stored results. Results are a JSON array of store.GroupQueryResult.
+type Snapshot struct {
+	Run
+	Results json.RawMessage `json:"results"`
+}
+
+type SchedulerI interface {
+	Queries() []Query
+	Run(ctx context.Context, name string) (Run, error)
+	Runs(ctx context.Context, name string, before time.Time, limit int) ([]Run, error)
+	Snapshot(ctx context.Context, id int64) (Snapshot, error)
+	SnapshotAt(ctx context.Context, name string, at time.Time) (Snapshot, error)
+	Alerts(ctx context.Context) ([]Alert, error)
+	Silences(ctx context.Context) ([]Silence, error)
+	AddSilence(ctx context.Context, silence Silence) (Silence, error)
+}
+
+type scheduledQuery struct {
+	config  QueryConfig
+	entryID cron.EntryID
+	m       *sync.Mutex
+	lastRun *Run
+}
+
+type Scheduler struct {
+	databaseStore store.DatabaseStoreI
+	storage       *storage
+	cron          *cron.Cron
+	alerts        *alerts
+	queries       map[string]*scheduledQuery
+	// names keep config order of queries
+	names []string
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// New validates config and opens snapshots database. Queries are not run until Start is called.
+func New(config Config, databaseStore store.DatabaseStoreI) (*Scheduler, error) {
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+	s := &Scheduler{
+		databaseStore: databaseStore,
+		cron:          c,
+		queries:       make(map[string]*scheduledQuery, len(config.Queries)),
+		now:           time.Now,
+	}
+	for _, queryConfig := range config.Queries {
+		err := validateQueryConfig(queryConfig)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := s.queries[queryConfig.Name]; ok {
+			return nil, errors.Errorf("duplicate scheduled query name: %s", queryConfig.Name)
+		}
+		if queryConfig.RetentionInHours <= 0 {
+			queryConfig.RetentionInHours = defaultRetentionInHours
+		}
+		query := &scheduledQuery{config: queryConfig, m: &sync.Mutex{}}
+		name := queryConfig.Name
+		query.entryID, err = c.AddFunc(queryConfig.Cron, func() {
+			_, err := s.Run(context.Background(), name)
+			if err != nil {
+				log.Error().Err(err).Str("name", name).Msg("failed to run scheduled query")
+			}
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cron expression of scheduled query: %s", name)
+		}
+		s.queries[name] = query
+		s.names = append(s.names, name)
+	}
+
+	path := config.DatabasePath
+	if path == "" {
+		path = defaultDatabasePath
+	}
+	var err error
+	s.storage, err = openStorage(path)
+	if err != nil {
+		return nil, err
+	}
+	s.alerts, err = newAlerts(config.Alerts, s.queries, s.storage)
+	if err != nil {
+		_ = s.storage.Close()
+		return nil, err
+	}
+	for _, query := range s.queries {
+		query.lastRun, err = s.storage.lastRun(context.Background(), query.config.Name)
+		if err != nil {
+			_ = s.storage.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func validateQueryConfig(config QueryConfig) error {
+	if config.Name == "" {
+		return errors.New("scheduled query name is empty")
+	}
+	if config.Cron == "" || config.GroupType == "" || config.Query == "" {
+		return errors.Errorf("cron, groupType and query are required. name=%s", config.Name)
+	}
+	return nil
+}
+
+// Start starts running queries by their schedules.
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling, waits for running queries to finish and closes snapshots database.
+func (s *Scheduler) Stop() error {
+	<-s.cron.Stop().Done()
+	return s.storage.Close()
+}
+
+func (s *Scheduler) Queries() []Query {
+	queries := make([]Query, 0, len(s.names))
+	for _, name := range s.names {
+		query := s.queries[name]
+		item := Query{
+			Name:             query.config.Name,
+			Cron:             query.config.Cron,
+			GroupType:        query.config.GroupType,
+			Query:            query.config.Query,
+			Schema:           query.config.Schema,
+			RetentionInHours: query.config.RetentionInHours,
+		}
+		entry := s.cron.Entry(query.entryID)
+		next := entry.Next
+		if next.IsZero() {
+			// entries are scheduled only after Start
+			next = entry.Schedule.Next(s.now())
+		}
+		item.NextRunAt = next
+		query.m.Lock()
+		item.LastRun = query.lastRun
+		query.m.Unlock()
+		queries = append(queries, item)
+	}
+	return queries
+}
+
+// Run executes scheduled query in all databases of its group type, saves results, evaluates alert rules and deletes
+// expired runs.
+func (s *Scheduler) Run(ctx context.Context, name string) (Run, error) {
+	query, ok := s.queries[name]
+	if !ok {
+		return Run{}, errors.Errorf("unknown scheduled query: %s", name)
+	}
+	config := query.config
+	if config.TimeoutInSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(config.TimeoutInSeconds)*time.Second)
+		defer cancel()
+	}
+
+	run := Run{Name: name, StartedAt: s.timestamp()}
+	// runs are stored and shown to any caller, so results are masked by all masking rules
+	results := s.databaseStore.QueryMultipleDatabases(ctx, config.GroupType, config.Query,
+		store.QueryOptions{Schema: config.Schema, MaskAll: true})
+	run.FinishedAt = s.timestamp()
+	run.GroupCount = len(results)
+	for _, result := range results {
+		if result.Error != nil {
+			run.FailedGroupCount++
+		}
+		// Data is usually one of Results, default encoding can be applied repeatedly
+		store.EncodeQueryData(result.Data, store.EncodingOptions{})
+		for _, data := range result.Results {
+			store.EncodeQueryData(data, store.EncodingOptions{})
+		}
+	}
+	b, err := json.Marshal(results)
+	if err != nil {
+		return Run{}, errors.Wrap(err, "failed to encode results")
+	}
+
+	// results are saved even if request was cancelled, run itself is already done
+	saveCtx := context.WithoutCancel(ctx)
+	run.ID, err = s.storage.insert(saveCtx, run, b)
+	if err != nil {
+		return Run{}, err
+	}
+	query.m.Lock()
+	query.lastRun = &run
+	query.m.Unlock()
+
+	err = s.alerts.evaluate(saveCtx, config, run, results)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("failed to evaluate alert rules")
+	}
+
+	retention := time.Duration(config.RetentionInHours) * time.Hour
+	err = s.storage.deleteBefore(saveCtx, name, run.StartedAt.Add(-retention))
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("failed to delete expired runs")
+	}
+	return run, nil
+}
+
+// timestamp returns current time with precision of stored run times.
+func (s *Scheduler) timestamp() time.Time {
+	return s.now().UTC().Truncate(time.Millisecond)
+}
+
+// Runs returns runs of scheduled query started before given time ordered from the latest.
+func (s *Scheduler) Runs(ctx context.Context, name string, before time.Time, limit int) ([]Run, error) {
+	if _, ok := s.queries[name]; !ok {
+		return nil, errors.Errorf("unknown scheduled query: %s", name)
+	}
+	return s.storage.runs(ctx, name, before, limit)
+}
+
+// Snapshot returns run with results by id. ErrRunNotFound is returned if run does not exist.
+func (s *Scheduler) Snapshot(ctx context.Context, id int64) (Snapshot, error) {
+	return s.storage.snapshot(ctx, id)
+}
+
+// SnapshotAt returns the latest run of scheduled query started at or before given time.
+func (s *Scheduler) SnapshotAt(ctx context.Context, name string, at time.Time) (Snapshot, error) {
+	if _, ok := s.queries[name]; !ok {
+		return Snapshot{}, errors.Errorf("unknown scheduled query: %s", name)
+	}
+	return s.storage.snapshotAt(ctx, name, at)
+}
+
+// Alerts returns states of alert rules in every evaluated database.
+func (s *Scheduler) Alerts(ctx context.Context) ([]Alert, error) {
+	return s.alerts.list(ctx)
+}
+
+// Silences returns config and added silences, which did not end yet.
+func (s *Scheduler) Silences(ctx context.Context) ([]Silence, error) {
+	return s.alerts.currentSilences(ctx, s.timestamp())
+}
+
+// AddSilence saves silence and returns it with id. Silence starts now if StartsAt is not set.
+func (s *Scheduler) AddSilence(ctx context.Context, silence Silence) (Silence, error) {
+	now := s.timestamp()
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	silence.StartsAt = silence.StartsAt.UTC().Truncate(time.Millisecond)
+	silence.EndsAt = silence.EndsAt.UTC().Truncate(time.Millisecond)
+	if !silence.EndsAt.After(silence.StartsAt) || !silence.EndsAt.After(now) {
+		return Silence{}, errors.New("silence must end after it starts and in the future")
+	}
+	if silence.Rule != "" && !s.alerts.hasRule(silence.Rule) {
+		return Silence{}, errors.Errorf("unknown alert rule: %s", silence.Rule)
+	}
+	var err error
+	silence.ID, err = s.storage.insertSilence(ctx, silence, now)
+	if err != nil {
+		return Silence{}, err
+	}
+	return silence, nil
+}
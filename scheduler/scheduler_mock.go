@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+type SchedulerMock struct {
+	QueriesFunc    func() []Query
+	RunFunc        func(ctx context.Context, name string) (Run, error)
+	RunsFunc       func(ctx context.Context, name string, before time.Time, limit int) ([]Run, error)
+	SnapshotFunc   func(ctx context.Context, id int64) (Snapshot, error)
+	SnapshotAtFunc func(ctx context.Context, name string, at time.Time) (Snapshot, error)
+	AlertsFunc     func(ctx context.Context) ([]Alert, error)
+	SilencesFunc   func(ctx context.Context) ([]Silence, error)
+	AddSilenceFunc func(ctx context.Context, silence Silence) (Silence, error)
+}
+
+func (s SchedulerMock) Queries() []Query {
+	return s.QueriesFunc()
+}
+
+func (s SchedulerMock) Run(ctx context.Context, name string) (Run, error) {
+	return s.RunFunc(ctx, name)
+}
+
+func (s SchedulerMock) Runs(ctx context.Context, name string, before time.Time, limit int) ([]Run, error) {
+	return s.RunsFunc(ctx, name, before, limit)
+}
+
+func (s SchedulerMock) Snapshot(ctx context.Context, id int64) (Snapshot, error) {
+	return s.SnapshotFunc(ctx, id)
+}
+
+func (s SchedulerMock) SnapshotAt(ctx context.Context, name string, at time.Time) (Snapshot, error) {
+	return s.SnapshotAtFunc(ctx, name, at)
+}
+
+func (s SchedulerMock) Alerts(ctx context.Context) ([]Alert, error) {
+	return s.AlertsFunc(ctx)
+}
+
+func (s SchedulerMock) Silences(ctx context.Context) ([]Silence, error) {
+	return s.SilencesFunc(ctx)
+}
+
+func (s SchedulerMock) AddSilence(ctx context.Context, silence Silence) (Silence, error) {
+	return s.AddSilenceFunc(ctx, silence)
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/minlau/mdb-tool/store"
+)
+
+func newTestScheduler(t *testing.T, config Config, databaseStore store.DatabaseStoreI) *Scheduler {
+	t.Helper()
+	if config.DatabasePath == "" {
+		config.DatabasePath = filepath.Join(t.TempDir(), "scheduler.db")
+	}
+	s, err := New(config, databaseStore)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = s.Stop()
+	})
+	return s
+}
+
+func testDatabaseStore(calls *int) store.DatabaseStoreMock {
+	return store.DatabaseStoreMock{
+		QueryMultipleDatabasesFunc: func(ctx context.Context, groupType string, query string, options store.QueryOptions) []store.GroupQueryResult {
+			*calls++
+			data := &store.QueryData{
+				Columns: []store.Column{{Name: "value", FieldName: "value"}},
+				Rows:    []map[string]any{{"value": []byte("abc")}},
+			}
+			return []store.GroupQueryResult{
+				{GroupName: "a", Data: data, Results: []*store.QueryData{data}},
+				{GroupName: "b", Error: &store.QueryError{Message: "failed"}},
+			}
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		queries []QueryConfig
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			queries: []QueryConfig{{Name: "q", Cron: "@every 1m", GroupType: "t", Query: "SELECT 1"}},
+		},
+		{
+			name:    "empty name",
+			queries: []QueryConfig{{Cron: "@every 1m", GroupType: "t", Query: "SELECT 1"}},
+			wantErr: "scheduled query name is empty",
+		},
+		{
+			name:    "missing query",
+			queries: []QueryConfig{{Name: "q", Cron: "@every 1m", GroupType: "t"}},
+			wantErr: "cron, groupType and query are required. name=q",
+		},
+		{
+			name:    "invalid cron",
+			queries: []QueryConfig{{Name: "q", Cron: "* *", GroupType: "t", Query: "SELECT 1"}},
+			wantErr: "invalid cron expression of scheduled query: q",
+		},
+		{
+			name: "duplicate name",
+			queries: []QueryConfig{
+				{Name: "q", Cron: "@every 1m", GroupType: "t", Query: "SELECT 1"},
+				{Name: "q", Cron: "@every 1m", GroupType: "t", Query: "SELECT 2"},
+			},
+			wantErr: "duplicate scheduled query name: q",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(Config{
+				DatabasePath: filepath.Join(t.TempDir(), "scheduler.db"),
+				Queries:      tt.queries,
+			}, store.DatabaseStoreMock{})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, s.Stop())
+		})
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	calls := 0
+	databaseStore := testDatabaseStore(&calls)
+	query := databaseStore.QueryMultipleDatabasesFunc
+	databaseStore.QueryMultipleDatabasesFunc = func(ctx context.Context, groupType string, q string, options store.QueryOptions) []store.GroupQueryResult {
+		// stored results are shown to any caller
+		assert.True(t, options.MaskAll)
+		return query(ctx, groupType, q, options)
+	}
+	s := newTestScheduler(t, Config{
+		Queries: []QueryConfig{{Name: "q", Cron: "@hourly", GroupType: "t", Query: "SELECT 1", RetentionInHours: 2}},
+	}, databaseStore)
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	first, err := s.Run(ctx, "q")
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, Run{ID: first.ID, Name: "q", StartedAt: now, FinishedAt: now, GroupCount: 2, FailedGroupCount: 1}, first)
+
+	now = now.Add(time.Hour)
+	second, err := s.Run(ctx, "q")
+	require.NoError(t, err)
+
+	runs, err := s.Runs(ctx, "q", now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Run{second, first}, runs)
+
+	runs, err = s.Runs(ctx, "q", now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Run{first}, runs)
+
+	snapshot, err := s.Snapshot(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first, snapshot.Run)
+	var results []store.GroupQueryResult
+	require.NoError(t, json.Unmarshal(snapshot.Results, &results))
+	require.Len(t, results, 2)
+	assert.Equal(t, "abc", results[0].Data.Rows[0]["value"])
+	assert.Equal(t, "failed", results[1].Error.Message)
+
+	snapshot, err = s.SnapshotAt(ctx, "q", now.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, first, snapshot.Run)
+
+	_, err = s.SnapshotAt(ctx, "q", now.Add(-2*time.Hour))
+	assert.ErrorIs(t, err, ErrRunNotFound)
+
+	queries := s.Queries()
+	require.Len(t, queries, 1)
+	assert.Equal(t, &second, queries[0].LastRun)
+
+	// first run is older than retention of 2 hours
+	now = now.Add(time.Hour + time.Minute)
+	third, err := s.Run(ctx, "q")
+	require.NoError(t, err)
+	runs, err = s.Runs(ctx, "q", now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Run{third, second}, runs)
+
+	_, err = s.Run(ctx, "unknown")
+	assert.EqualError(t, err, "unknown scheduled query: unknown")
+}
+
+func TestScheduler_lastRunIsLoaded(t *testing.T) {
+	calls := 0
+	config := Config{
+		DatabasePath: filepath.Join(t.TempDir(), "scheduler.db"),
+		Queries:      []QueryConfig{{Name: "q", Cron: "@hourly", GroupType: "t", Query: "SELECT 1"}},
+	}
+	s, err := New(config, testDatabaseStore(&calls))
+	require.NoError(t, err)
+	run, err := s.Run(context.Background(), "q")
+	require.NoError(t, err)
+	require.NoError(t, s.Stop())
+
+	s = newTestScheduler(t, config, testDatabaseStore(&calls))
+	queries := s.Queries()
+	require.Len(t, queries, 1)
+	assert.Equal(t, &run, queries[0].LastRun)
+	assert.False(t, queries[0].NextRunAt.IsZero())
+}
@@ -0,0 +1,274 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/segmentio/encoding/json"
+	_ "modernc.org/sqlite"
+)
+
+const storageSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    started_at INTEGER NOT NULL,
+    finished_at INTEGER NOT NULL,
+    group_count INTEGER NOT NULL,
+    failed_group_count INTEGER NOT NULL,
+    results BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_name_started_at ON runs (name, started_at);
+CREATE TABLE IF NOT EXISTS alerts (
+    rule TEXT NOT NULL,
+    group_name TEXT NOT NULL,
+    query TEXT NOT NULL,
+    status TEXT NOT NULL,
+    since INTEGER NOT NULL,
+    value REAL,
+    error TEXT NOT NULL,
+    notified INTEGER NOT NULL,
+    silenced INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (rule, group_name)
+);
+CREATE TABLE IF NOT EXISTS silences (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rule TEXT NOT NULL,
+    group_name TEXT NOT NULL,
+    starts_at INTEGER NOT NULL,
+    ends_at INTEGER NOT NULL,
+    comment TEXT NOT NULL
+);
+`
+
+// storage keeps run snapshots in embedded SQLite database. Times are stored as unix milliseconds.
+type storage struct {
+	db *sqlx.DB
+}
+
+type runRow struct {
+	ID               int64  `db:"id"`
+	Name             string `db:"name"`
+	StartedAt        int64  `db:"started_at"`
+	FinishedAt       int64  `db:"finished_at"`
+	GroupCount       int    `db:"group_count"`
+	FailedGroupCount int    `db:"failed_group_count"`
+	Results          []byte `db:"results"`
+}
+
+func (r runRow) run() Run {
+	return Run{
+		ID:               r.ID,
+		Name:             r.Name,
+		StartedAt:        time.UnixMilli(r.StartedAt).UTC(),
+		FinishedAt:       time.UnixMilli(r.FinishedAt).UTC(),
+		GroupCount:       r.GroupCount,
+		FailedGroupCount: r.FailedGroupCount,
+	}
+}
+
+func openStorage(path string) (*storage, error) {
+	db, err := sqlx.Open("sqlite", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open scheduler database. path=%s", path)
+	}
+	// single connection avoids SQLITE_BUSY errors of concurrent writes
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(storageSchema)
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "failed to create scheduler database schema")
+	}
+	return &storage{db: db}, nil
+}
+
+func (s *storage) Close() error {
+	return s.db.Close()
+}
+
+// insert saves run with its results and returns run id.
+func (s *storage) insert(ctx context.Context, run Run, results json.RawMessage) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO runs
+		(name, started_at, finished_at, group_count, failed_group_count, results) VALUES (?, ?, ?, ?, ?, ?)`,
+		run.Name, run.StartedAt.UnixMilli(), run.FinishedAt.UnixMilli(), run.GroupCount, run.FailedGroupCount,
+		[]byte(results))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to save run")
+	}
+	return res.LastInsertId()
+}
+
+// deleteBefore deletes runs of query started before given time.
+func (s *storage) deleteBefore(ctx context.Context, name string, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM runs WHERE name = ? AND started_at < ?", name, before.UnixMilli())
+	if err != nil {
+		return errors.Wrap(err, "failed to delete expired runs")
+	}
+	return nil
+}
+
+// runs returns runs of query started before given time ordered from the latest. Results are not loaded.
+func (s *storage) runs(ctx context.Context, name string, before time.Time, limit int) ([]Run, error) {
+	var rows []runRow
+	err := s.db.SelectContext(ctx, &rows, `SELECT id, name, started_at, finished_at, group_count, failed_group_count
+		FROM runs WHERE name = ? AND started_at < ? ORDER BY started_at DESC, id DESC LIMIT ?`,
+		name, before.UnixMilli(), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read runs")
+	}
+	runs := make([]Run, len(rows))
+	for i, row := range rows {
+		runs[i] = row.run()
+	}
+	return runs, nil
+}
+
+// lastRun returns the latest run of query or nil if query was never run.
+func (s *storage) lastRun(ctx context.Context, name string) (*Run, error) {
+	runs, err := s.runs(ctx, name, time.UnixMilli(math.MaxInt64), 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// snapshot returns run with results by id.
+func (s *storage) snapshot(ctx context.Context, id int64) (Snapshot, error) {
+	return s.getSnapshot(ctx, "SELECT * FROM runs WHERE id = ?", id)
+}
+
+// snapshotAt returns the latest run of query started at or before given time.
+func (s *storage) snapshotAt(ctx context.Context, name string, at time.Time) (Snapshot, error) {
+	return s.getSnapshot(ctx, `SELECT * FROM runs WHERE name = ? AND started_at <= ?
+		ORDER BY started_at DESC, id DESC LIMIT 1`, name, at.UnixMilli())
+}
+
+func (s *storage) getSnapshot(ctx context.Context, query string, args ...any) (Snapshot, error) {
+	var row runRow
+	err := s.db.GetContext(ctx, &row, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, ErrRunNotFound
+	}
+	if err != nil {
+		return Snapshot{}, errors.Wrap(err, "failed to read run")
+	}
+	return Snapshot{Run: row.run(), Results: row.Results}, nil
+}
+
+type alertRow struct {
+	Rule      string   `db:"rule"`
+	GroupName string   `db:"group_name"`
+	Query     string   `db:"query"`
+	Status    string   `db:"status"`
+	Since     int64    `db:"since"`
+	Value     *float64 `db:"value"`
+	Error     string   `db:"error"`
+	Notified  bool     `db:"notified"`
+	Silenced  bool     `db:"silenced"`
+	UpdatedAt int64    `db:"updated_at"`
+}
+
+func (r alertRow) alert() Alert {
+	return Alert{
+		Rule:      r.Rule,
+		Query:     r.Query,
+		GroupName: r.GroupName,
+		Status:    r.Status,
+		Since:     time.UnixMilli(r.Since).UTC(),
+		Value:     r.Value,
+		Error:     r.Error,
+		Notified:  r.Notified,
+		Silenced:  r.Silenced,
+		UpdatedAt: time.UnixMilli(r.UpdatedAt).UTC(),
+	}
+}
+
+// alert returns state of rule in database or nil if rule was not evaluated yet.
+func (s *storage) alert(ctx context.Context, rule string, groupName string) (*Alert, error) {
+	var row alertRow
+	err := s.db.GetContext(ctx, &row, "SELECT * FROM alerts WHERE rule = ? AND group_name = ?", rule, groupName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read alert")
+	}
+	alert := row.alert()
+	return &alert, nil
+}
+
+func (s *storage) alerts(ctx context.Context) ([]Alert, error) {
+	var rows []alertRow
+	err := s.db.SelectContext(ctx, &rows, "SELECT * FROM alerts ORDER BY rule, group_name")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read alerts")
+	}
+	alerts := make([]Alert, len(rows))
+	for i, row := range rows {
+		alerts[i] = row.alert()
+	}
+	return alerts, nil
+}
+
+func (s *storage) saveAlert(ctx context.Context, alert Alert) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO alerts
+		(rule, group_name, query, status, since, value, error, notified, silenced, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		alert.Rule, alert.GroupName, alert.Query, alert.Status, alert.Since.UnixMilli(), alert.Value, alert.Error,
+		alert.Notified, alert.Silenced, alert.UpdatedAt.UnixMilli())
+	if err != nil {
+		return errors.Wrap(err, "failed to save alert")
+	}
+	return nil
+}
+
+type silenceRow struct {
+	ID        int64  `db:"id"`
+	Rule      string `db:"rule"`
+	GroupName string `db:"group_name"`
+	StartsAt  int64  `db:"starts_at"`
+	EndsAt    int64  `db:"ends_at"`
+	Comment   string `db:"comment"`
+}
+
+// silences returns silences, which end after given time.
+func (s *storage) silences(ctx context.Context, endsAfter time.Time) ([]Silence, error) {
+	var rows []silenceRow
+	err := s.db.SelectContext(ctx, &rows, "SELECT * FROM silences WHERE ends_at > ? ORDER BY starts_at, id",
+		endsAfter.UnixMilli())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read silences")
+	}
+	silences := make([]Silence, len(rows))
+	for i, row := range rows {
+		silences[i] = Silence{
+			ID:        row.ID,
+			Rule:      row.Rule,
+			GroupName: row.GroupName,
+			StartsAt:  time.UnixMilli(row.StartsAt).UTC(),
+			EndsAt:    time.UnixMilli(row.EndsAt).UTC(),
+			Comment:   row.Comment,
+		}
+	}
+	return silences, nil
+}
+
+// insertSilence saves silence and returns its id. Silences, which ended before given time, are deleted.
+func (s *storage) insertSilence(ctx context.Context, silence Silence, now time.Time) (int64, error) {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM silences WHERE ends_at <= ?", now.UnixMilli())
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete expired silences")
+	}
+	res, err := s.db.ExecContext(ctx, `INSERT INTO silences (rule, group_name, starts_at, ends_at, comment)
+		VALUES (?, ?, ?, ?, ?)`, silence.Rule, silence.GroupName, silence.StartsAt.UnixMilli(), silence.EndsAt.UnixMilli(),
+		silence.Comment)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to save silence")
+	}
+	return res.LastInsertId()
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+
+	"github.com/minlau/mdb-tool/internal/utils/closer"
+)
+
+// AggregationTable is a name of in-memory table with merged results, which can be used by aggregation query.
+const AggregationTable = "results"
+
+// AggregateGroupQueryResults merges results(see MergeGroupQueryResults) into in-memory SQLite table AggregationTable
+// and executes aggregation query on it. Merged result Data is replaced by aggregation query result.
+// Column names of the table are merged column field names, so groupName column can be used to group by environment.
+// Aggregation query can contain only SELECT statements, which are executed on a read-only connection.
+func AggregateGroupQueryResults(ctx context.Context, results []GroupQueryResult, query string) (MergedQueryResult, error) {
+	merged := MergeGroupQueryResults(results)
+	err := validateAggregationQuery(query)
+	if err != nil {
+		return merged, err
+	}
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	if err != nil {
+		return merged, errors.Wrap(err, "failed to open aggregation database")
+	}
+	defer closer.Handle(db, "aggregation database")
+	// every connection to :memory: opens a separate database
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return merged, errors.Wrap(err, "failed to open aggregation database connection")
+	}
+	defer closer.Handle(conn, "aggregation database connection")
+
+	err = loadAggregationTable(ctx, conn, merged.Data)
+	if err != nil {
+		return merged, errors.Wrap(err, "failed to load results into aggregation database")
+	}
+	_, err = conn.ExecContext(ctx, "PRAGMA query_only = ON")
+	if err != nil {
+		return merged, errors.Wrap(err, "failed to make aggregation database read-only")
+	}
+
+	aggregated, err := executeQuery(ctx, conn, "sqlite", query)
+	if err != nil {
+		return merged, errors.Wrap(err, "failed to execute aggregation query")
+	}
+	merged.Data = primaryResult(aggregated)
+	return merged, nil
+}
+
+// validateAggregationQuery rejects statements other than SELECT, i.e. ATTACH DATABASE, which can write files of server.
+func validateAggregationQuery(query string) error {
+	statements := splitStatements(query, "sqlite")
+	if len(statements) == 0 {
+		return errors.New("aggregation query is empty")
+	}
+	for _, s := range statements {
+		switch s.mainKeyword() {
+		case "SELECT", "VALUES":
+		default:
+			return errors.Errorf("aggregation query must contain only SELECT statements: %s", s.text)
+		}
+	}
+	return nil
+}
+
+func loadAggregationTable(ctx context.Context, conn *sqlx.Conn, data *QueryData) error {
+	columns := make([]string, 0, len(data.Columns))
+	placeholders := make([]string, 0, len(data.Columns))
+	for _, column := range data.Columns {
+		columns = append(columns, quoteIdentifier(column.FieldName))
+		placeholders = append(placeholders, "?")
+	}
+
+	_, err := conn.ExecContext(ctx, "CREATE TABLE "+AggregationTable+" ("+strings.Join(columns, ", ")+")")
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO "+AggregationTable+" VALUES ("+strings.Join(placeholders, ", ")+")")
+	if err != nil {
+		return err
+	}
+	defer closer.Handle(stmt, "aggregation statement")
+
+	values := make([]any, len(data.Columns))
+	for _, row := range data.Rows {
+		for i, column := range data.Columns {
+			values[i] = sqliteValue(row[column.FieldName])
+		}
+		_, err = stmt.ExecContext(ctx, values...)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqliteValue converts driver specific values, which are not supported by SQLite driver, to strings.
+func sqliteValue(value any) any {
+	switch value.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, string, []byte,
+		time.Time:
+		return value
+	default:
+		return stringValue(value)
+	}
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateGroupQueryResults(t *testing.T) {
+	createDate := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	results := []GroupQueryResult{
+		{
+			GroupName: "lemon",
+			Data: &QueryData{
+				Columns: []Column{{Name: "id", FieldName: "id"}, {Name: "create_date", FieldName: "create_date"}},
+				Rows: []map[string]any{
+					{"id": int64(1), "create_date": createDate},
+					{"id": int64(2), "create_date": createDate.Add(time.Hour)},
+				},
+			},
+		},
+		{
+			GroupName: "pear",
+			Data: &QueryData{
+				Columns: []Column{{Name: "id", FieldName: "id"}, {Name: "create_date", FieldName: "create_date"}},
+				Rows: []map[string]any{
+					{"id": int64(3), "create_date": createDate},
+				},
+			},
+		},
+	}
+
+	got, err := AggregateGroupQueryResults(context.Background(), results,
+		`select "groupName", count(*) as cnt, sum(id) as total from results group by "groupName" order by 1`)
+
+	assert.NoError(t, err)
+	for i := range got.Data.Columns {
+		assert.NotNil(t, got.Data.Columns[i].Type)
+		got.Data.Columns[i].Type = nil
+	}
+	assert.Equal(t, []Column{
+		{Name: "groupName", FieldName: "groupName"},
+		{Name: "cnt", FieldName: "cnt"},
+		{Name: "total", FieldName: "total"},
+	}, got.Data.Columns)
+	assert.Equal(t, []map[string]any{
+		{"groupName": "lemon", "cnt": int64(2), "total": int64(3)},
+		{"groupName": "pear", "cnt": int64(1), "total": int64(3)},
+	}, got.Data.Rows)
+}
+
+func TestAggregateGroupQueryResults_InvalidQuery(t *testing.T) {
+	_, err := AggregateGroupQueryResults(context.Background(), nil, "select missing from results")
+
+	assert.Error(t, err)
+}
+
+func TestAggregateGroupQueryResults_notSelectQuery(t *testing.T) {
+	dir := t.TempDir()
+	for _, query := range []string{
+		"attach database '" + filepath.Join(dir, "x.db") + "' as x; create table x.t(a)",
+		"select 1; detach database main",
+		"pragma writable_schema = on",
+		"vacuum into '" + filepath.Join(dir, "y.db") + "'",
+		"/* select */",
+	} {
+		_, err := AggregateGroupQueryResults(context.Background(), nil, query)
+		assert.Error(t, err, query)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	results := []GroupQueryResult{{GroupName: "a", Data: &QueryData{
+		Columns: []Column{{Name: "id", FieldName: "id"}},
+		Rows:    []map[string]any{{"id": int64(1)}},
+	}}}
+	got, err := AggregateGroupQueryResults(context.Background(), results,
+		"with x as (select id from results) select count(*) as cnt from x")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"cnt": int64(1)}}, got.Data.Rows)
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type MetadataCacheConfig struct {
+	// TTLInSeconds is a lifetime of cached tables metadata. Cache is disabled if it is 0
+	TTLInSeconds int
+	// InvalidateOnDdl invalidates cached tables metadata of database after a query with DDL statement
+	InvalidateOnDdl bool
+}
+
+type metadataCacheEntry struct {
+	metadata *TablesMetadata
+	cachedAt time.Time
+}
+
+// metadataCache is a cache of tables metadata per database and requested schemas.
+type metadataCache struct {
+	m       sync.Mutex
+	ttl     time.Duration
+	entries map[DatabaseGroup]map[string]metadataCacheEntry
+	// generations are incremented by invalidate, so that metadata loaded before invalidation is not cached
+	generations map[DatabaseGroup]uint64
+	now         func() time.Time
+}
+
+func newMetadataCache(config MetadataCacheConfig) *metadataCache {
+	return &metadataCache{
+		ttl:         time.Duration(config.TTLInSeconds) * time.Second,
+		entries:     make(map[DatabaseGroup]map[string]metadataCacheEntry),
+		generations: make(map[DatabaseGroup]uint64),
+		now:         time.Now,
+	}
+}
+
+func (c *metadataCache) enabled() bool {
+	return c.ttl > 0
+}
+
+func schemasCacheKey(schemas []string) string {
+	return strings.Join(schemas, ",")
+}
+
+// get returns a copy of cached metadata with its age or false if there is no cached metadata or it is expired.
+func (c *metadataCache) get(group DatabaseGroup, schemas []string) (*TablesMetadata, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	entry, ok := c.entries[group][schemasCacheKey(schemas)]
+	if !ok {
+		return nil, false
+	}
+	age := c.now().Sub(entry.cachedAt)
+	if age >= c.ttl {
+		delete(c.entries[group], schemasCacheKey(schemas))
+		return nil, false
+	}
+	metadata := *entry.metadata
+	metadata.Cached = true
+	metadata.AgeInMilliseconds = age.Milliseconds()
+	return &metadata, true
+}
+
+// generation returns current generation of database cache. It must be read before metadata is loaded and passed to
+// set.
+func (c *metadataCache) generation(group DatabaseGroup) uint64 {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.generations[group]
+}
+
+// set caches metadata loaded at generation. Metadata is dropped if cache was invalidated since then.
+func (c *metadataCache) set(group DatabaseGroup, schemas []string, generation uint64, metadata *TablesMetadata) {
+	if !c.enabled() {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.generations[group] != generation {
+		return
+	}
+	if _, ok := c.entries[group]; !ok {
+		c.entries[group] = make(map[string]metadataCacheEntry)
+	}
+	c.entries[group][schemasCacheKey(schemas)] = metadataCacheEntry{metadata: metadata, cachedAt: c.now()}
+}
+
+// invalidate removes cached metadata of all schemas of database.
+func (c *metadataCache) invalidate(group DatabaseGroup) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	delete(c.entries, group)
+	c.generations[group]++
+}
+
+// containsDdl returns true if any statement of query changes database schema.
+func containsDdl(query string, sqlType string) bool {
+	for _, s := range splitStatements(query, sqlType) {
+		switch s.firstKeyword() {
+		case "CREATE", "ALTER", "DROP", "RENAME", "RECREATE":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newMetadataCache(MetadataCacheConfig{TTLInSeconds: 60})
+	cache.now = func() time.Time { return now }
+	group := DatabaseGroup{GroupName: "a", GroupType: "t"}
+	metadata := &TablesMetadata{Schemas: []SchemaMetadata{{Name: "public"}}}
+
+	_, ok := cache.get(group, nil)
+	assert.False(t, ok)
+
+	cache.set(group, nil, cache.generation(group), metadata)
+	now = now.Add(10 * time.Second)
+	cached, ok := cache.get(group, nil)
+	assert.True(t, ok)
+	assert.True(t, cached.Cached)
+	assert.Equal(t, int64(10000), cached.AgeInMilliseconds)
+	assert.Equal(t, metadata.Schemas, cached.Schemas)
+	assert.False(t, metadata.Cached, "cached entry must not be modified")
+
+	_, ok = cache.get(group, []string{"other"})
+	assert.False(t, ok, "schemas are part of a key")
+
+	cache.invalidate(group)
+	_, ok = cache.get(group, nil)
+	assert.False(t, ok)
+
+	cache.set(group, nil, cache.generation(group), metadata)
+	now = now.Add(60 * time.Second)
+	_, ok = cache.get(group, nil)
+	assert.False(t, ok, "entry is expired")
+}
+
+func TestMetadataCache_setAfterInvalidate(t *testing.T) {
+	cache := newMetadataCache(MetadataCacheConfig{TTLInSeconds: 60})
+	group := DatabaseGroup{GroupName: "a", GroupType: "t"}
+	other := DatabaseGroup{GroupName: "b", GroupType: "t"}
+
+	// load started before invalidation
+	generation := cache.generation(group)
+	otherGeneration := cache.generation(other)
+	cache.invalidate(group)
+	cache.set(group, nil, generation, &TablesMetadata{})
+	_, ok := cache.get(group, nil)
+	assert.False(t, ok, "stale metadata is not cached")
+
+	cache.set(other, nil, otherGeneration, &TablesMetadata{})
+	_, ok = cache.get(other, nil)
+	assert.True(t, ok, "generation is per database")
+
+	cache.set(group, nil, cache.generation(group), &TablesMetadata{})
+	_, ok = cache.get(group, nil)
+	assert.True(t, ok)
+}
+
+func TestMetadataCache_Disabled(t *testing.T) {
+	cache := newMetadataCache(MetadataCacheConfig{})
+	group := DatabaseGroup{GroupName: "a", GroupType: "t"}
+	cache.set(group, nil, cache.generation(group), &TablesMetadata{})
+	_, ok := cache.get(group, nil)
+	assert.False(t, ok)
+}
+
+func TestContainsDdl(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		sqlType string
+		want    bool
+	}{
+		{name: "select", query: "select 1", sqlType: "postgresql", want: false},
+		{name: "create table", query: "CREATE TABLE t (id int)", sqlType: "postgresql", want: true},
+		{name: "second statement", query: "select 1; alter table t add c int", sqlType: "postgresql", want: true},
+		{name: "keyword in literal", query: "select 'drop table t'", sqlType: "postgresql", want: false},
+		{name: "keyword in comment", query: "/* create */ select 1", sqlType: "mysql", want: false},
+		{name: "insert", query: "insert into t values ('create')", sqlType: "mysql", want: false},
+		{name: "firebird recreate", query: "recreate table t (id int)", sqlType: "firebird", want: true},
+		{name: "mysql rename", query: "rename table a to b", sqlType: "mysql", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, containsDdl(tt.query, tt.sqlType))
+		})
+	}
+}
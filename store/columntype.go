@@ -0,0 +1,160 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Logical types are dialect independent column types.
+const (
+	LogicalTypeString    = "string"
+	LogicalTypeInteger   = "integer"
+	LogicalTypeDecimal   = "decimal"
+	LogicalTypeFloat     = "float"
+	LogicalTypeBoolean   = "boolean"
+	LogicalTypeDate      = "date"
+	LogicalTypeTime      = "time"
+	LogicalTypeTimestamp = "timestamp"
+	LogicalTypeInterval  = "interval"
+	LogicalTypeBinary    = "binary"
+	LogicalTypeJson      = "json"
+	LogicalTypeUuid      = "uuid"
+	LogicalTypeArray     = "array"
+	LogicalTypeUnknown   = "unknown"
+)
+
+// maxDecimalPrecision is the largest supported decimal precision(postgresql). Larger precision is reported by drivers
+// for unconstrained decimals.
+const maxDecimalPrecision = 1000
+
+// ColumnType is a column type reported by database driver.
+type ColumnType struct {
+	// DatabaseTypeName is an upper case database type name without length(i.e. VARCHAR, INT4, NUMERIC)
+	DatabaseTypeName string `json:"databaseTypeName"`
+	// LogicalType is a dialect independent type(see LogicalTypeString etc.)
+	LogicalType string `json:"logicalType"`
+	// ScanType is a Go type suitable for scanning column value. It is nil if driver does not report it
+	ScanType reflect.Type `json:"-"`
+	// Nullable is nil if driver does not report nullability
+	Nullable *bool `json:"nullable"`
+	// Length is a length of variable length types. It is nil if driver does not report it, type is not variable
+	// or length is not limited
+	Length *int64 `json:"length"`
+	// Precision and Scale are nil if driver does not report them, type is not decimal or precision is not limited
+	Precision *int64 `json:"precision"`
+	Scale     *int64 `json:"scale"`
+}
+
+func NewColumnType(columnType *sql.ColumnType) *ColumnType {
+	t := &ColumnType{
+		DatabaseTypeName: strings.ToUpper(columnType.DatabaseTypeName()),
+		ScanType:         columnType.ScanType(),
+	}
+	if nullable, ok := columnType.Nullable(); ok {
+		t.Nullable = &nullable
+	}
+	if length, ok := columnType.Length(); ok && length != math.MaxInt64 {
+		t.Length = &length
+	}
+	if precision, scale, ok := columnType.DecimalSize(); ok && precision > 0 && precision <= maxDecimalPrecision {
+		// firebird reports negative scale
+		if scale < 0 {
+			scale = -scale
+		}
+		t.Precision = &precision
+		t.Scale = &scale
+	}
+	t.LogicalType = logicalType(t)
+	return t
+}
+
+// logicalType maps database type name of postgresql, mysql, firebird or sqlite(aggregation) to a logical type.
+// Scan type is used for unknown database types.
+func logicalType(t *ColumnType) string {
+	name := t.DatabaseTypeName
+	switch {
+	case strings.HasPrefix(name, "_") || name == "ARRAY":
+		// postgresql array type names start with underscore
+		return LogicalTypeArray
+	case strings.HasPrefix(name, "UNSIGNED "):
+		// mysql unsigned integers
+		return LogicalTypeInteger
+	case strings.HasPrefix(name, "DECFLOAT"):
+		return LogicalTypeDecimal
+	}
+
+	switch name {
+	case "SHORT", "LONG", "INT64", "INT128":
+		// firebird scaled integers are decimals
+		if t.Scale != nil && *t.Scale != 0 {
+			return LogicalTypeDecimal
+		}
+		return LogicalTypeInteger
+	case "INT2", "INT4", "INT8", "SMALLINT", "INTEGER", "INT", "BIGINT", "TINYINT", "MEDIUMINT", "YEAR", "OID":
+		return LogicalTypeInteger
+	case "NUMERIC", "DECIMAL", "DECFIXED":
+		return LogicalTypeDecimal
+	case "FLOAT4", "FLOAT8", "FLOAT", "DOUBLE", "REAL", "D_FLOAT":
+		return LogicalTypeFloat
+	case "BOOL", "BOOLEAN":
+		return LogicalTypeBoolean
+	case "DATE":
+		return LogicalTypeDate
+	case "TIME", "TIMETZ", "TIME WITH TIMEZONE":
+		return LogicalTypeTime
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATETIME", "TIMESTAMP WITH TIMEZONE":
+		return LogicalTypeTimestamp
+	case "INTERVAL":
+		return LogicalTypeInterval
+	case "BYTEA", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BIT", "GEOMETRY", "QUAD":
+		return LogicalTypeBinary
+	case "JSON", "JSONB":
+		return LogicalTypeJson
+	case "UUID":
+		return LogicalTypeUuid
+	case "TEXT", "VARCHAR", "CHAR", "BPCHAR", "NAME", "VARYING", "TINYTEXT", "MEDIUMTEXT", "LONGTEXT", "ENUM", "SET",
+		"CITEXT", "XML":
+		return LogicalTypeString
+	}
+	return scanLogicalType(t.ScanType)
+}
+
+func scanLogicalType(scanType reflect.Type) string {
+	if scanType == nil {
+		return LogicalTypeUnknown
+	}
+	if scanType.Kind() == reflect.Pointer {
+		scanType = scanType.Elem()
+	}
+	switch scanType {
+	case reflect.TypeOf(sql.NullInt64{}), reflect.TypeOf(sql.NullInt32{}), reflect.TypeOf(sql.NullInt16{}),
+		reflect.TypeOf(sql.NullByte{}):
+		return LogicalTypeInteger
+	case reflect.TypeOf(sql.NullFloat64{}):
+		return LogicalTypeFloat
+	case reflect.TypeOf(sql.NullBool{}):
+		return LogicalTypeBoolean
+	case reflect.TypeOf(sql.NullTime{}), reflect.TypeOf(time.Time{}):
+		return LogicalTypeTimestamp
+	case reflect.TypeOf(sql.NullString{}):
+		return LogicalTypeString
+	case reflect.TypeOf([]byte{}), reflect.TypeOf(sql.RawBytes{}):
+		return LogicalTypeBinary
+	}
+	switch scanType.Kind() {
+	case reflect.Bool:
+		return LogicalTypeBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return LogicalTypeInteger
+	case reflect.Float32, reflect.Float64:
+		return LogicalTypeFloat
+	case reflect.String:
+		return LogicalTypeString
+	default:
+		return LogicalTypeUnknown
+	}
+}
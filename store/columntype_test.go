@@ -0,0 +1,47 @@
+package store
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogicalType(t *testing.T) {
+	scale := func(v int64) *int64 {
+		return &v
+	}
+	tests := []struct {
+		name       string
+		columnType ColumnType
+		want       string
+	}{
+		{name: "postgresql integer", columnType: ColumnType{DatabaseTypeName: "INT8"}, want: LogicalTypeInteger},
+		{name: "postgresql numeric", columnType: ColumnType{DatabaseTypeName: "NUMERIC"}, want: LogicalTypeDecimal},
+		{name: "postgresql timestamptz", columnType: ColumnType{DatabaseTypeName: "TIMESTAMPTZ"}, want: LogicalTypeTimestamp},
+		{name: "postgresql array", columnType: ColumnType{DatabaseTypeName: "_INT4"}, want: LogicalTypeArray},
+		{name: "postgresql jsonb", columnType: ColumnType{DatabaseTypeName: "JSONB"}, want: LogicalTypeJson},
+		{name: "postgresql bytea", columnType: ColumnType{DatabaseTypeName: "BYTEA"}, want: LogicalTypeBinary},
+		{name: "postgresql unknown oid", columnType: ColumnType{DatabaseTypeName: "16385", ScanType: reflect.TypeOf("")},
+			want: LogicalTypeString},
+		{name: "mysql unsigned", columnType: ColumnType{DatabaseTypeName: "UNSIGNED BIGINT"}, want: LogicalTypeInteger},
+		{name: "mysql datetime", columnType: ColumnType{DatabaseTypeName: "DATETIME"}, want: LogicalTypeTimestamp},
+		{name: "mysql blob", columnType: ColumnType{DatabaseTypeName: "LONGBLOB"}, want: LogicalTypeBinary},
+		{name: "firebird integer", columnType: ColumnType{DatabaseTypeName: "LONG"}, want: LogicalTypeInteger},
+		{name: "firebird scaled integer", columnType: ColumnType{DatabaseTypeName: "INT64", Scale: scale(2)},
+			want: LogicalTypeDecimal},
+		{name: "firebird varying", columnType: ColumnType{DatabaseTypeName: "VARYING"}, want: LogicalTypeString},
+		{name: "firebird decfloat", columnType: ColumnType{DatabaseTypeName: "DECFLOAT(34)"}, want: LogicalTypeDecimal},
+		{name: "scan type time", columnType: ColumnType{ScanType: reflect.TypeOf(sql.NullTime{})}, want: LogicalTypeTimestamp},
+		{name: "scan type pointer", columnType: ColumnType{ScanType: reflect.TypeOf(new(int64))}, want: LogicalTypeInteger},
+		{name: "scan type kind", columnType: ColumnType{ScanType: reflect.TypeOf(time.Duration(0))}, want: LogicalTypeInteger},
+		{name: "unknown", columnType: ColumnType{}, want: LogicalTypeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, logicalType(&tt.columnType))
+		})
+	}
+}
@@ -23,6 +23,10 @@ type DatabaseConfig struct {
 	DatabaseGroup
 	DatabaseConnConfig
 	DatabaseConnPoolConfig
+	// Label is an optional sort key of database
+	Label string `db:"label"`
+	// Schemas are used for tables metadata. Default schema is used if not set
+	Schemas StringList `db:"schemas"`
 }
 
 type DatabaseGroup struct {
@@ -110,15 +114,16 @@ func OpenDatabase(c DatabaseConnConfig) (*sqlx.DB, error) {
 	return db, nil
 }
 
+// GetDatabaseConfigsFromDataSources returns configs in data sources order.
 func GetDatabaseConfigsFromDataSources(dataSources []DataSource) ([]DatabaseConfig, []error) {
-	databaseConfigs := make([]DatabaseConfig, 0)
+	results := make([][]DatabaseConfig, len(dataSources))
 	errs := make([]error, 0)
 	var mutex sync.Mutex
 	var wg sync.WaitGroup
 
-	for _, item := range dataSources {
+	for i, item := range dataSources {
 		wg.Add(1)
-		go func(dataSource DataSource) {
+		go func(i int, dataSource DataSource) {
 			defer wg.Done()
 
 			configs, err := GetDatabaseConfigsFromDataSource(dataSource)
@@ -128,13 +133,15 @@ func GetDatabaseConfigsFromDataSources(dataSources []DataSource) ([]DatabaseConf
 				errs = append(errs, err)
 				return
 			}
-
-			mutex.Lock()
-			defer mutex.Unlock()
-			databaseConfigs = append(databaseConfigs, configs...)
-		}(item)
+			results[i] = configs
+		}(i, item)
 	}
 	wg.Wait()
+
+	databaseConfigs := make([]DatabaseConfig, 0)
+	for _, configs := range results {
+		databaseConfigs = append(databaseConfigs, configs...)
+	}
 	return databaseConfigs, errs
 }
 
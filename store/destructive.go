@@ -0,0 +1,70 @@
+package store
+
+// Destructive statement kinds.
+const (
+	// DestructiveDdl is a DDL statement, except of TRUNCATE
+	DestructiveDdl = "ddl"
+	// DestructiveTruncate is a TRUNCATE statement
+	DestructiveTruncate = "truncate"
+	// DestructiveDeleteWithoutWhere is a DELETE statement without WHERE clause
+	DestructiveDeleteWithoutWhere = "deleteWithoutWhere"
+	// DestructiveUpdateWithoutWhere is an UPDATE statement without WHERE clause
+	DestructiveUpdateWithoutWhere = "updateWithoutWhere"
+	// DestructiveUnknown is a statement, which executes other statements(i.e. DO, EXECUTE BLOCK, CALL), so its effect
+	// is unknown
+	DestructiveUnknown = "unknown"
+)
+
+// Destructive statements policies(see PolicyConfig.DestructiveStatements).
+const (
+	// DestructiveStatementsConfirm executes destructive statements only if query is confirmed(see QueryOptions.Confirm)
+	DestructiveStatementsConfirm = "confirm"
+	// DestructiveStatementsReject rejects destructive statements
+	DestructiveStatementsReject = "reject"
+)
+
+// destructive returns destructive kind of statement(see DestructiveDdl etc.) or empty string if statement is not
+// destructive. Statements inside of routine bodies and dynamic SQL are not classified, so statements executing them
+// are DestructiveUnknown.
+func (s statement) destructive() string {
+	switch s.mainKeyword() {
+	case "DO", "CALL", "EXECUTE", "EXEC", "PREPARE":
+		return DestructiveUnknown
+	case "TRUNCATE":
+		return DestructiveTruncate
+	case "DELETE":
+		if !s.hasTopLevelWhere() {
+			return DestructiveDeleteWithoutWhere
+		}
+	case "UPDATE":
+		// firebird UPDATE OR INSERT modifies only matching rows
+		if s.firstKeyword() == "UPDATE" && len(s.tokens) > 1 && s.tokens[1].isKeyword("OR") {
+			return ""
+		}
+		if !s.hasTopLevelWhere() {
+			return DestructiveUpdateWithoutWhere
+		}
+	default:
+		if s.kind() == StatementKindDdl {
+			return DestructiveDdl
+		}
+	}
+	return ""
+}
+
+// hasTopLevelWhere returns true if statement has WHERE outside of parentheses, i.e. WHERE of subquery or common table
+// expression is not a WHERE of DELETE.
+func (s statement) hasTopLevelWhere() bool {
+	depth := 0
+	for _, t := range s.tokens {
+		switch {
+		case t.isPunctuation("("):
+			depth++
+		case t.isPunctuation(")"):
+			depth--
+		case depth == 0 && t.isKeyword("WHERE"):
+			return true
+		}
+	}
+	return false
+}
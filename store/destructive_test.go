@@ -0,0 +1,56 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatement_destructive(t *testing.T) {
+	tests := []struct {
+		query   string
+		sqlType string
+		want    string
+	}{
+		{query: "select * from t", sqlType: "postgresql"},
+		{query: "drop table t", sqlType: "postgresql", want: DestructiveDdl},
+		{query: "alter table t add c int", sqlType: "mysql", want: DestructiveDdl},
+		{query: "recreate table t (id int)", sqlType: "firebird", want: DestructiveDdl},
+		{query: "truncate table t", sqlType: "mysql", want: DestructiveTruncate},
+		{query: "truncate t", sqlType: "postgresql", want: DestructiveTruncate},
+		{query: "delete from t", sqlType: "postgresql", want: DestructiveDeleteWithoutWhere},
+		{query: "DELETE FROM t WHERE id = 1", sqlType: "postgresql"},
+		{query: "delete from t -- where id = 1", sqlType: "postgresql", want: DestructiveDeleteWithoutWhere},
+		{query: "delete from t # where id = 1", sqlType: "mysql", want: DestructiveDeleteWithoutWhere},
+		{query: "delete from t where id in (select id from s)", sqlType: "postgresql"},
+		{query: "delete from t using s where s.id = t.id", sqlType: "postgresql"},
+		{query: "delete t from t join s on s.id = t.id", sqlType: "mysql", want: DestructiveDeleteWithoutWhere},
+		{
+			query:   "with old as (select id from t where id < 10) delete from t",
+			sqlType: "postgresql",
+			want:    DestructiveDeleteWithoutWhere,
+		},
+		{query: "update t set a = 'where'", sqlType: "postgresql", want: DestructiveUpdateWithoutWhere},
+		{query: "update t set a = (select b from s where s.id = t.id)", sqlType: "postgresql",
+			want: DestructiveUpdateWithoutWhere},
+		{query: "update `t` set a = 1 where id = 1", sqlType: "mysql"},
+		{query: "update or insert into t (id) values (1) matching (id)", sqlType: "firebird"},
+		{query: "insert into t select * from s", sqlType: "postgresql"},
+		{query: "select id from t for update", sqlType: "postgresql"},
+		{query: "do $$ begin drop table t; end $$", sqlType: "postgresql", want: DestructiveUnknown},
+		{query: "execute block as begin delete from t; end", sqlType: "firebird", want: DestructiveUnknown},
+		{query: "execute procedure purge", sqlType: "firebird", want: DestructiveUnknown},
+		{query: "prepare s from 'drop table t'", sqlType: "mysql", want: DestructiveUnknown},
+		{query: "execute s", sqlType: "mysql", want: DestructiveUnknown},
+		{query: "call purge()", sqlType: "mysql", want: DestructiveUnknown},
+		{query: "CALL purge()", sqlType: "postgresql", want: DestructiveUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			statements := splitStatements(tt.query, tt.sqlType)
+			require.Len(t, statements, 1)
+			assert.Equal(t, tt.want, statements[0].destructive())
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DiffResult is a comparison of the same query results in multiple databases against base(first) database.
+type DiffResult struct {
+	BaseGroupName string      `json:"baseGroupName"`
+	KeyColumns    []string    `json:"keyColumns"`
+	Columns       []Column    `json:"columns"`
+	Error         *QueryError `json:"error"`
+	Diffs         []GroupDiff `json:"diffs"`
+}
+
+// GroupDiff contains rows, which differ from base database rows. Rows are matched by key columns.
+type GroupDiff struct {
+	GroupName string           `json:"groupName"`
+	Added     []map[string]any `json:"added"`
+	Removed   []map[string]any `json:"removed"`
+	Changed   []ChangedRow     `json:"changed"`
+	Error     *QueryError      `json:"error"`
+}
+
+type ChangedRow struct {
+	Key  map[string]any `json:"key"`
+	Base map[string]any `json:"base"`
+	Row  map[string]any `json:"row"`
+	// Columns are field names of differing columns
+	Columns []string `json:"columns"`
+}
+
+// DiffDatabases executes query in every group and compares results with first group results. Results are masked for
+// caller role(see QueryOptions.Role).
+func (s *DatabaseStore) DiffDatabases(ctx context.Context, groupType string, groupNames []string, query string,
+	keyColumns []string, role string) DiffResult {
+	results := make([]GroupQueryResult, len(groupNames))
+	var wg sync.WaitGroup
+	for i, groupName := range groupNames {
+		wg.Add(1)
+		go func(i int, groupName string) {
+			defer wg.Done()
+			results[i] = s.QueryDatabase(ctx, groupName, groupType, query, QueryOptions{Role: role})
+		}(i, groupName)
+	}
+	wg.Wait()
+
+	return DiffGroupQueryResults(results, keyColumns)
+}
+
+// DiffGroupQueryResults compares every result with first result. Key columns are matched by column Name or FieldName.
+func DiffGroupQueryResults(results []GroupQueryResult, keyColumns []string) DiffResult {
+	diffResult := DiffResult{KeyColumns: keyColumns, Columns: []Column{}, Diffs: []GroupDiff{}}
+	if len(results) == 0 {
+		return diffResult
+	}
+
+	base := results[0]
+	diffResult.BaseGroupName = base.GroupName
+	diffResult.Error = base.Error
+	if base.Data != nil {
+		diffResult.Columns = base.Data.Columns
+	}
+
+	for _, result := range results[1:] {
+		groupDiff := GroupDiff{
+			GroupName: result.GroupName,
+			Added:     []map[string]any{},
+			Removed:   []map[string]any{},
+			Changed:   []ChangedRow{},
+			Error:     result.Error,
+		}
+		if base.Error == nil && result.Error == nil {
+			err := diffQueryData(&groupDiff, base.Data, result.Data, keyColumns)
+			if err != nil {
+				groupDiff.Error = NewQueryError(err)
+			}
+		}
+		diffResult.Diffs = append(diffResult.Diffs, groupDiff)
+	}
+	return diffResult
+}
+
+func diffQueryData(groupDiff *GroupDiff, base *QueryData, data *QueryData, keyColumns []string) error {
+	if base == nil {
+		base = &QueryData{}
+	}
+	if data == nil {
+		data = &QueryData{}
+	}
+
+	baseRows, baseKeys, err := indexRows(base, keyColumns)
+	if err != nil {
+		return errors.Wrap(err, "failed to index base rows")
+	}
+	rows, keys, err := indexRows(data, keyColumns)
+	if err != nil {
+		return errors.Wrapf(err, "failed to index rows of %s", groupDiff.GroupName)
+	}
+
+	fieldNames := unionFieldNames(base.Columns, data.Columns)
+	for _, key := range baseKeys {
+		baseRow := baseRows[key]
+		row, ok := rows[key]
+		if !ok {
+			groupDiff.Removed = append(groupDiff.Removed, baseRow)
+			continue
+		}
+
+		var changedColumns []string
+		for _, fieldName := range fieldNames {
+			baseValue, baseOk := baseRow[fieldName]
+			value, ok := row[fieldName]
+			if baseOk != ok || !valuesEqual(baseValue, value) {
+				changedColumns = append(changedColumns, fieldName)
+			}
+		}
+		if len(changedColumns) > 0 {
+			keyValues, _ := getKeyValues(base.Columns, baseRow, keyColumns)
+			groupDiff.Changed = append(groupDiff.Changed, ChangedRow{
+				Key:     keyValues,
+				Base:    baseRow,
+				Row:     row,
+				Columns: changedColumns,
+			})
+		}
+	}
+	for _, key := range keys {
+		if _, ok := baseRows[key]; !ok {
+			groupDiff.Added = append(groupDiff.Added, rows[key])
+		}
+	}
+	return nil
+}
+
+// indexRows maps rows by key. Keys are returned in rows order.
+func indexRows(data *QueryData, keyColumns []string) (map[string]map[string]any, []string, error) {
+	index := make(map[string]map[string]any, len(data.Rows))
+	keys := make([]string, 0, len(data.Rows))
+	for _, row := range data.Rows {
+		keyValues, err := getKeyValues(data.Columns, row, keyColumns)
+		if err != nil {
+			return nil, nil, err
+		}
+		key := rowKey(keyValues, keyColumns)
+		if _, ok := index[key]; ok {
+			return nil, nil, errors.Errorf("duplicate key: %v", keyValues)
+		}
+		index[key] = row
+		keys = append(keys, key)
+	}
+	return index, keys, nil
+}
+
+func getKeyValues(columns []Column, row map[string]any, keyColumns []string) (map[string]any, error) {
+	keyValues := make(map[string]any, len(keyColumns))
+	for _, keyColumn := range keyColumns {
+		fieldName, ok := findFieldName(columns, keyColumn)
+		if !ok {
+			return nil, errors.Errorf("key column not found: %s", keyColumn)
+		}
+		keyValues[keyColumn] = row[fieldName]
+	}
+	return keyValues, nil
+}
+
+// findFieldName returns FieldName of first column with given Name or FieldName.
+func findFieldName(columns []Column, name string) (string, bool) {
+	for _, column := range columns {
+		if column.Name == name {
+			return column.FieldName, true
+		}
+	}
+	for _, column := range columns {
+		if column.FieldName == name {
+			return column.FieldName, true
+		}
+	}
+	return "", false
+}
+
+func rowKey(keyValues map[string]any, keyColumns []string) string {
+	parts := make([]string, 0, len(keyColumns))
+	for _, keyColumn := range keyColumns {
+		value := keyValues[keyColumn]
+		if value == nil {
+			parts = append(parts, "null")
+			continue
+		}
+		parts = append(parts, valueKind(value)+":"+stringValue(value))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func unionFieldNames(a []Column, b []Column) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	fieldNames := make([]string, 0, len(a)+len(b))
+	for _, columns := range [][]Column{a, b} {
+		for _, column := range columns {
+			if !seen[column.FieldName] {
+				seen[column.FieldName] = true
+				fieldNames = append(fieldNames, column.FieldName)
+			}
+		}
+	}
+	return fieldNames
+}
+
+func valuesEqual(a any, b any) bool {
+	switch av := a.(type) {
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	case []byte:
+		bv, ok := b.([]byte)
+		return ok && bytes.Equal(av, bv)
+	}
+	if valueKind(a) == "number" && valueKind(b) == "number" {
+		return stringValue(a) == stringValue(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
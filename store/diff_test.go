@@ -0,0 +1,83 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffGroupQueryResults(t *testing.T) {
+	columns := []Column{{Name: "id", FieldName: "id"}, {Name: "code", FieldName: "code"}, {Name: "name", FieldName: "name"}}
+	results := []GroupQueryResult{
+		{
+			GroupName: "lemon",
+			Data: &QueryData{
+				Columns: columns,
+				Rows: []map[string]any{
+					{"id": int64(1), "code": "a", "name": "one"},
+					{"id": int64(2), "code": "a", "name": "two"},
+					{"id": int64(3), "code": "a", "name": "three"},
+				},
+			},
+		},
+		{
+			GroupName: "pear",
+			Data: &QueryData{
+				Columns: columns,
+				Rows: []map[string]any{
+					{"id": int32(1), "code": "a", "name": "one"},
+					{"id": int32(2), "code": "a", "name": "TWO"},
+					{"id": int32(4), "code": "a", "name": "four"},
+				},
+			},
+		},
+		{
+			GroupName: "apple",
+			Data: &QueryData{
+				Columns: columns,
+				Rows: []map[string]any{
+					{"id": int64(1), "code": "a", "name": "one"},
+					{"id": int64(1), "code": "a", "name": "duplicate"},
+				},
+			},
+		},
+	}
+
+	got := DiffGroupQueryResults(results, []string{"id", "code"})
+
+	assert.Equal(t, "lemon", got.BaseGroupName)
+	assert.Equal(t, columns, got.Columns)
+	assert.Len(t, got.Diffs, 2)
+
+	assert.Equal(t, GroupDiff{
+		GroupName: "pear",
+		Added:     []map[string]any{{"id": int32(4), "code": "a", "name": "four"}},
+		Removed:   []map[string]any{{"id": int64(3), "code": "a", "name": "three"}},
+		Changed: []ChangedRow{{
+			Key:     map[string]any{"id": int64(2), "code": "a"},
+			Base:    map[string]any{"id": int64(2), "code": "a", "name": "two"},
+			Row:     map[string]any{"id": int32(2), "code": "a", "name": "TWO"},
+			Columns: []string{"name"},
+		}},
+	}, got.Diffs[0])
+
+	assert.Equal(t, "apple", got.Diffs[1].GroupName)
+	require.NotNil(t, got.Diffs[1].Error)
+	assert.Equal(t, "failed to index rows of apple: duplicate key: map[code:a id:1]", got.Diffs[1].Error.Message)
+}
+
+func TestDiffGroupQueryResults_MissingKeyColumn(t *testing.T) {
+	results := []GroupQueryResult{
+		{GroupName: "lemon", Data: &QueryData{
+			Columns: []Column{{Name: "id", FieldName: "id"}},
+			Rows:    []map[string]any{{"id": int64(1)}},
+		}},
+		{GroupName: "pear", Data: &QueryData{Columns: []Column{{Name: "id", FieldName: "id"}}}},
+	}
+
+	got := DiffGroupQueryResults(results, []string{"missing"})
+
+	assert.Len(t, got.Diffs, 1)
+	assert.Equal(t, "failed to index base rows: key column not found: missing", got.Diffs[0].Error.Message)
+}
@@ -0,0 +1,317 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaDriftReport compares tables and columns of every database of group type with base database schema.
+// If base group name is not set, majority schema is used as a base(see majoritySchema).
+type SchemaDriftReport struct {
+	GroupType     string          `json:"groupType"`
+	BaseGroupName string          `json:"baseGroupName"`
+	Databases     []DatabaseDrift `json:"databases"`
+}
+
+type DatabaseDrift struct {
+	GroupName      string             `json:"groupName"`
+	MissingTables  []string           `json:"missingTables"`
+	ExtraTables    []string           `json:"extraTables"`
+	MissingColumns []TableColumn      `json:"missingColumns"`
+	ExtraColumns   []TableColumn      `json:"extraColumns"`
+	ChangedColumns []ColumnDifference `json:"changedColumns"`
+	Error          *QueryError        `json:"error"`
+}
+
+type TableColumn struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+}
+
+// ColumnDifference is a column with different data type or nullability.
+type ColumnDifference struct {
+	Table    string         `json:"table"`
+	Expected ColumnMetadata `json:"expected"`
+	Actual   ColumnMetadata `json:"actual"`
+}
+
+func (d DatabaseDrift) HasDrift() bool {
+	return len(d.MissingTables) > 0 || len(d.ExtraTables) > 0 || len(d.MissingColumns) > 0 ||
+		len(d.ExtraColumns) > 0 || len(d.ChangedColumns) > 0
+}
+
+// schemaMetadata is table columns of base tables. Only data type and nullability of columns are compared.
+type schemaMetadata map[string][]ColumnMetadata
+
+// newSchemaMetadata maps tables by name. Tables are mapped by schema qualified name, if qualified is true, because
+// default schema name can differ between databases(i.e. mysql database name).
+func newSchemaMetadata(metadata *TablesMetadata, qualified bool) schemaMetadata {
+	schema := make(schemaMetadata)
+	for _, schemaMetadata := range metadata.Schemas {
+		for _, table := range schemaMetadata.Tables {
+			if table.Type != TableTypeTable {
+				continue
+			}
+			columns := make([]ColumnMetadata, 0, len(table.Columns))
+			for _, column := range table.Columns {
+				columns = append(columns, ColumnMetadata{Name: column.Name, DataType: column.DataType, Nullable: column.Nullable})
+			}
+			name := table.Name
+			if qualified {
+				name = table.Schema + "." + table.Name
+			}
+			schema[name] = columns
+		}
+	}
+	return schema
+}
+
+// qualifiedTableNames returns true if tables of group should be compared by schema qualified names. Names must be
+// the same for every database of group, otherwise the same table is reported as missing and extra, so tables are
+// qualified only if every database has configured schemas.
+func qualifiedTableNames(instances []DatabaseInstance) bool {
+	for _, instance := range instances {
+		if len(instance.Config.Schemas) == 0 {
+			return false
+		}
+	}
+	return len(instances) > 0
+}
+
+// GetSchemaDrift fetches columns metadata of every database of group type concurrently and compares it with base
+// database. Majority schema is used, if baseGroupName is empty.
+func (s *DatabaseStore) GetSchemaDrift(ctx context.Context, groupType string, baseGroupName string) (SchemaDriftReport, error) {
+	var instances []DatabaseInstance
+	for key, value := range s.databases {
+		if key.GroupType == groupType {
+			instances = append(instances, value)
+		}
+	}
+	if len(instances) == 0 {
+		return SchemaDriftReport{}, errors.Errorf("no databases registered with groupType: %s", groupType)
+	}
+	sortDatabaseInstances(instances, ResultOrder{By: s.orderBy})
+	qualified := qualifiedTableNames(instances)
+
+	schemas := make([]schemaMetadata, len(instances))
+	errs := make([]error, len(instances))
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance DatabaseInstance) {
+			defer wg.Done()
+			release, _, err := s.limiter.acquire(ctx, hostKey(instance.Config.DatabaseConnConfig))
+			if err != nil {
+				errs[i] = errors.Wrap(err, "failed to wait for free query slot")
+				return
+			}
+			defer release()
+			metadata, err := queryTablesMetadata(ctx, instance.DB, instance.Config.Type, instance.Config.Schemas)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			schemas[i] = newSchemaMetadata(metadata, qualified)
+		}(i, instance)
+	}
+	wg.Wait()
+
+	var base schemaMetadata
+	if baseGroupName != "" {
+		found := false
+		for i, instance := range instances {
+			if instance.Config.GroupName == baseGroupName {
+				found = true
+				if errs[i] != nil {
+					return SchemaDriftReport{}, errors.Wrapf(errs[i], "failed to get base schema. groupName=%s", baseGroupName)
+				}
+				base = schemas[i]
+			}
+		}
+		if !found {
+			return SchemaDriftReport{}, errors.Errorf("no database registered with groupName: %s, groupType: %s",
+				baseGroupName, groupType)
+		}
+	} else {
+		var fetched []schemaMetadata
+		for i := range instances {
+			if errs[i] == nil {
+				fetched = append(fetched, schemas[i])
+			}
+		}
+		base = majoritySchema(fetched)
+	}
+
+	report := SchemaDriftReport{GroupType: groupType, BaseGroupName: baseGroupName, Databases: []DatabaseDrift{}}
+	for i, instance := range instances {
+		if instance.Config.GroupName == baseGroupName {
+			continue
+		}
+		drift := compareSchemas(base, schemas[i])
+		drift.GroupName = instance.Config.GroupName
+		if errs[i] != nil {
+			drift = DatabaseDrift{GroupName: instance.Config.GroupName, Error: NewQueryError(errs[i])}
+		}
+		report.Databases = append(report.Databases, drift)
+	}
+	return report, nil
+}
+
+// majoritySchema builds a schema of tables, which exist in more than half of schemas, and columns, which exist in
+// more than half of schemas with that table. Column definition is the most common one.
+func majoritySchema(schemas []schemaMetadata) schemaMetadata {
+	tableCounts := make(map[string]int)
+	for _, schema := range schemas {
+		for table := range schema {
+			tableCounts[table]++
+		}
+	}
+
+	majority := make(schemaMetadata)
+	for table, tableCount := range tableCounts {
+		if tableCount*2 <= len(schemas) {
+			continue
+		}
+
+		var names []string
+		definitions := make(map[string]map[ColumnMetadata]int)
+		for _, schema := range schemas {
+			for _, column := range schema[table] {
+				if _, ok := definitions[column.Name]; !ok {
+					definitions[column.Name] = make(map[ColumnMetadata]int)
+					names = append(names, column.Name)
+				}
+				definitions[column.Name][column]++
+			}
+		}
+
+		columns := make([]ColumnMetadata, 0, len(names))
+		for _, name := range names {
+			var best ColumnMetadata
+			bestCount, columnCount := 0, 0
+			for column, count := range definitions[name] {
+				columnCount += count
+				if count > bestCount || (count == bestCount && columnKey(column) < columnKey(best)) {
+					best, bestCount = column, count
+				}
+			}
+			if columnCount*2 > tableCount {
+				columns = append(columns, best)
+			}
+		}
+		majority[table] = columns
+	}
+	return majority
+}
+
+func columnKey(column ColumnMetadata) string {
+	return fmt.Sprintf("%s %s %t", column.Name, column.DataType, column.Nullable)
+}
+
+func compareSchemas(base schemaMetadata, schema schemaMetadata) DatabaseDrift {
+	drift := DatabaseDrift{
+		MissingTables:  []string{},
+		ExtraTables:    []string{},
+		MissingColumns: []TableColumn{},
+		ExtraColumns:   []TableColumn{},
+		ChangedColumns: []ColumnDifference{},
+	}
+
+	for _, table := range sortedTables(base) {
+		columns, ok := schema[table]
+		if !ok {
+			drift.MissingTables = append(drift.MissingTables, table)
+			continue
+		}
+
+		actual := make(map[string]ColumnMetadata, len(columns))
+		for _, column := range columns {
+			actual[column.Name] = column
+		}
+		expected := make(map[string]bool, len(base[table]))
+		for _, column := range base[table] {
+			expected[column.Name] = true
+			actualColumn, ok := actual[column.Name]
+			if !ok {
+				drift.MissingColumns = append(drift.MissingColumns, TableColumn{Table: table, Column: column.Name})
+			} else if actualColumn != column {
+				drift.ChangedColumns = append(drift.ChangedColumns, ColumnDifference{
+					Table:    table,
+					Expected: column,
+					Actual:   actualColumn,
+				})
+			}
+		}
+		for _, column := range columns {
+			if !expected[column.Name] {
+				drift.ExtraColumns = append(drift.ExtraColumns, TableColumn{Table: table, Column: column.Name})
+			}
+		}
+	}
+	for _, table := range sortedTables(schema) {
+		if _, ok := base[table]; !ok {
+			drift.ExtraTables = append(drift.ExtraTables, table)
+		}
+	}
+	return drift
+}
+
+func sortedTables(schema schemaMetadata) []string {
+	tables := make([]string, 0, len(schema))
+	for table := range schema {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// Text returns human-readable report.
+func (r SchemaDriftReport) Text() string {
+	base := r.BaseGroupName
+	if base == "" {
+		base = "majority"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Schema drift of %s compared to %s\n", r.GroupType, base)
+	for _, d := range r.Databases {
+		fmt.Fprintf(&b, "\n%s:\n", d.GroupName)
+		if d.Error != nil {
+			fmt.Fprintf(&b, "  error: %s\n", d.Error.Message)
+			continue
+		}
+		if !d.HasDrift() {
+			b.WriteString("  no drift\n")
+			continue
+		}
+		for _, table := range d.MissingTables {
+			fmt.Fprintf(&b, "  missing table %s\n", table)
+		}
+		for _, table := range d.ExtraTables {
+			fmt.Fprintf(&b, "  extra table %s\n", table)
+		}
+		for _, c := range d.MissingColumns {
+			fmt.Fprintf(&b, "  missing column %s.%s\n", c.Table, c.Column)
+		}
+		for _, c := range d.ExtraColumns {
+			fmt.Fprintf(&b, "  extra column %s.%s\n", c.Table, c.Column)
+		}
+		for _, c := range d.ChangedColumns {
+			fmt.Fprintf(&b, "  changed column %s.%s: %s -> %s\n", c.Table, c.Expected.Name,
+				columnDefinition(c.Expected), columnDefinition(c.Actual))
+		}
+	}
+	return b.String()
+}
+
+func columnDefinition(column ColumnMetadata) string {
+	if column.Nullable {
+		return column.DataType + " NULL"
+	}
+	return column.DataType + " NOT NULL"
+}
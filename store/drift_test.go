@@ -0,0 +1,81 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	idColumn       = ColumnMetadata{Name: "id", DataType: "integer", Nullable: false}
+	nameColumn     = ColumnMetadata{Name: "name", DataType: "text", Nullable: true}
+	nameLongColumn = ColumnMetadata{Name: "name", DataType: "character varying(10)", Nullable: true}
+)
+
+func Test_majoritySchema(t *testing.T) {
+	schemas := []schemaMetadata{
+		{"users": {idColumn, nameColumn}, "messages": {idColumn}},
+		{"users": {idColumn, nameColumn}},
+		{"users": {idColumn, nameLongColumn}, "audit": {idColumn}},
+	}
+
+	got := majoritySchema(schemas)
+
+	assert.Equal(t, schemaMetadata{"users": {idColumn, nameColumn}}, got)
+}
+
+func Test_qualifiedTableNames(t *testing.T) {
+	configured := DatabaseInstance{Config: DatabaseConfig{Schemas: StringList{"public"}}}
+	notConfigured := DatabaseInstance{}
+	assert.True(t, qualifiedTableNames([]DatabaseInstance{configured, configured}))
+	assert.False(t, qualifiedTableNames([]DatabaseInstance{configured, notConfigured}))
+	assert.False(t, qualifiedTableNames(nil))
+}
+
+func Test_compareSchemas(t *testing.T) {
+	base := schemaMetadata{
+		"users":    {idColumn, nameColumn},
+		"messages": {idColumn},
+	}
+	schema := schemaMetadata{
+		"users": {idColumn, nameLongColumn, {Name: "email", DataType: "text", Nullable: true}},
+		"audit": {idColumn},
+	}
+
+	got := compareSchemas(base, schema)
+
+	assert.Equal(t, DatabaseDrift{
+		MissingTables:  []string{"messages"},
+		ExtraTables:    []string{"audit"},
+		MissingColumns: []TableColumn{},
+		ExtraColumns:   []TableColumn{{Table: "users", Column: "email"}},
+		ChangedColumns: []ColumnDifference{{Table: "users", Expected: nameColumn, Actual: nameLongColumn}},
+	}, got)
+	assert.True(t, got.HasDrift())
+	assert.False(t, compareSchemas(base, base).HasDrift())
+}
+
+func TestSchemaDriftReport_Text(t *testing.T) {
+	report := SchemaDriftReport{
+		GroupType: "messaging",
+		Databases: []DatabaseDrift{
+			{GroupName: "lemon"},
+			{GroupName: "pear", MissingTables: []string{"messages"},
+				ChangedColumns: []ColumnDifference{{Table: "users", Expected: nameColumn, Actual: nameLongColumn}}},
+			{GroupName: "apple", Error: &QueryError{Message: "connection refused"}},
+		},
+	}
+
+	assert.Equal(t, `Schema drift of messaging compared to majority
+
+lemon:
+  no drift
+
+pear:
+  missing table messages
+  changed column users.name: text NULL -> character varying(10) NULL
+
+apple:
+  error: connection refused
+`, report.Text())
+}
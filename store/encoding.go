@@ -0,0 +1,277 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/encoding/json"
+)
+
+const (
+	// EncodingDefault renders values as returned by driver, binary values are converted to strings
+	EncodingDefault = "default"
+	// EncodingLossless renders values, which can not be represented by json losslessly, as strings or marked objects
+	EncodingLossless = "lossless"
+
+	BinaryEncodingBase64 = "base64"
+	BinaryEncodingHex    = "hex"
+)
+
+// maxSafeInteger is the largest integer, which can be represented by javascript number.
+const maxSafeInteger = 1<<53 - 1
+
+// EncodingOptions are per request json encoding options of query results values.
+type EncodingOptions struct {
+	// Encoding is EncodingDefault or EncodingLossless. Default: EncodingDefault
+	Encoding string
+	// BinaryEncoding is an encoding of binary values used by EncodingLossless. Default: BinaryEncodingBase64
+	BinaryEncoding string
+}
+
+func IsValidEncoding(encoding string) bool {
+	return encoding == "" || encoding == EncodingDefault || encoding == EncodingLossless
+}
+
+func IsValidBinaryEncoding(encoding string) bool {
+	return encoding == "" || encoding == BinaryEncodingBase64 || encoding == BinaryEncodingHex
+}
+
+// BinaryValue is a losslessly encoded binary value. Encoding marks how Data is encoded.
+type BinaryValue struct {
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"`
+}
+
+// EncodeQueryData encodes values of data in place for json response.
+//
+// Lossless encoding rules by column logical type(see ColumnType):
+//   - decimals and 64-bit integers are strings
+//   - binary values are BinaryValue
+//   - timestamps are RFC3339 strings with time zone, mysql timestamps are RFC3339 strings without time zone(session
+//     time zone is not known), dates are 2006-01-02 strings
+//   - json values are embedded as json
+//   - postgresql arrays are json arrays of element strings
+//   - intervals, uuids and other values are rendered as returned by database
+//
+// Values of columns without type are encoded by their Go type.
+func EncodeQueryData(data *QueryData, options EncodingOptions) {
+	if data == nil {
+		return
+	}
+	for _, column := range data.Columns {
+		for _, row := range data.Rows {
+			value, ok := row[column.FieldName]
+			if !ok {
+				continue
+			}
+			if options.Encoding == EncodingLossless {
+				row[column.FieldName] = encodeLosslessValue(value, column.Type, options.BinaryEncoding)
+			} else if b, ok := value.([]byte); ok {
+				row[column.FieldName] = string(b)
+			}
+		}
+	}
+}
+
+func encodeLosslessValue(value any, columnType *ColumnType, binaryEncoding string) any {
+	if value == nil {
+		return nil
+	}
+	logicalType := LogicalTypeUnknown
+	databaseTypeName := ""
+	if columnType != nil {
+		logicalType = columnType.LogicalType
+		databaseTypeName = columnType.DatabaseTypeName
+	}
+
+	if b, ok := value.([]byte); ok {
+		if logicalType == LogicalTypeBinary || columnType == nil {
+			return encodeBinary(b, binaryEncoding)
+		}
+		value = string(b)
+	}
+
+	switch logicalType {
+	case LogicalTypeDecimal:
+		return stringValue(value)
+	case LogicalTypeInteger:
+		if is64BitInteger(databaseTypeName) {
+			return stringValue(value)
+		}
+	case LogicalTypeTimestamp:
+		switch v := value.(type) {
+		case time.Time:
+			return v.Format(time.RFC3339Nano)
+		case string:
+			if t, err := time.Parse(mysqlTimeLayout, v); err == nil {
+				return t.Format(localTimeLayout)
+			}
+		}
+	case LogicalTypeDate:
+		if t, ok := value.(time.Time); ok {
+			return t.Format(time.DateOnly)
+		}
+	case LogicalTypeJson:
+		if s, ok := value.(string); ok && json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+	case LogicalTypeArray:
+		if s, ok := value.(string); ok {
+			if array, ok := parsePostgresArray(s); ok {
+				return array
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case bool, string, float32, float64, int8, int16, int32, uint8, uint16, uint32:
+		return v
+	case int:
+		return encodeInteger(int64(v))
+	case int64:
+		return encodeInteger(v)
+	case uint:
+		return encodeUnsignedInteger(uint64(v))
+	case uint64:
+		return encodeUnsignedInteger(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		// i.e. firebird decimal.Decimal, big.Int
+		return stringValue(v)
+	}
+}
+
+func encodeBinary(b []byte, binaryEncoding string) BinaryValue {
+	if binaryEncoding == BinaryEncodingHex {
+		return BinaryValue{Encoding: BinaryEncodingHex, Data: hex.EncodeToString(b)}
+	}
+	return BinaryValue{Encoding: BinaryEncodingBase64, Data: base64.StdEncoding.EncodeToString(b)}
+}
+
+// encodeInteger returns integers, which can not be represented by javascript number, as strings.
+func encodeInteger(v int64) any {
+	if v > maxSafeInteger || v < -maxSafeInteger {
+		return strconv.FormatInt(v, 10)
+	}
+	return v
+}
+
+func encodeUnsignedInteger(v uint64) any {
+	if v > maxSafeInteger {
+		return strconv.FormatUint(v, 10)
+	}
+	return v
+}
+
+func is64BitInteger(databaseTypeName string) bool {
+	switch databaseTypeName {
+	case "INT8", "BIGINT", "UNSIGNED BIGINT", "INT64", "INT128":
+		return true
+	default:
+		return false
+	}
+}
+
+// mysqlTimeLayout is a layout of mysql DATETIME and TIMESTAMP values, which are returned as strings. Values have no
+// time zone: DATETIME is stored as is and TIMESTAMP is returned in session time zone.
+const mysqlTimeLayout = "2006-01-02 15:04:05.999999999"
+
+// localTimeLayout is RFC3339 without time zone.
+const localTimeLayout = "2006-01-02T15:04:05.999999999"
+
+// parseTimestamp parses time.Time or mysql timestamp string. Timestamp string is expected to be written in UTC, i.e.
+// applied_at of migrations table.
+func parseTimestamp(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(mysqlTimeLayout, v)
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// parsePostgresArray parses postgresql array text representation(i.e. {1,NULL,"a b",{2,3}}) into nested slices of
+// strings. NULL elements are nil.
+func parsePostgresArray(s string) ([]any, bool) {
+	// arrays with non-default bounds have dimensions prefix: [0:1]={1,2}
+	if strings.HasPrefix(s, "[") {
+		i := strings.Index(s, "=")
+		if i == -1 {
+			return nil, false
+		}
+		s = s[i+1:]
+	}
+	array, end, ok := parsePostgresArrayAt(s, 0)
+	if !ok || end != len(s) {
+		return nil, false
+	}
+	return array, true
+}
+
+func parsePostgresArrayAt(s string, i int) ([]any, int, bool) {
+	if i >= len(s) || s[i] != '{' {
+		return nil, i, false
+	}
+	i++
+	array := []any{}
+	if i < len(s) && s[i] == '}' {
+		return array, i + 1, true
+	}
+	for i < len(s) {
+		switch {
+		case s[i] == '{':
+			element, end, ok := parsePostgresArrayAt(s, i)
+			if !ok {
+				return nil, i, false
+			}
+			array = append(array, element)
+			i = end
+		case s[i] == '"':
+			var b strings.Builder
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, i, false
+			}
+			array = append(array, b.String())
+			i++
+		default:
+			start := i
+			for i < len(s) && s[i] != ',' && s[i] != '}' {
+				i++
+			}
+			element := strings.TrimSpace(s[start:i])
+			if element == "NULL" {
+				array = append(array, nil)
+			} else {
+				array = append(array, element)
+			}
+		}
+
+		if i >= len(s) {
+			return nil, i, false
+		}
+		switch s[i] {
+		case ',':
+			i++
+		case '}':
+			return array, i + 1, true
+		default:
+			return nil, i, false
+		}
+	}
+	return nil, i, false
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeQueryData(t *testing.T) {
+	columnType := func(databaseTypeName string, logicalType string) *ColumnType {
+		return &ColumnType{DatabaseTypeName: databaseTypeName, LogicalType: logicalType}
+	}
+	newData := func() *QueryData {
+		return &QueryData{
+			Columns: []Column{
+				{Name: "id", FieldName: "id", Type: columnType("INT8", LogicalTypeInteger)},
+				{Name: "small", FieldName: "small", Type: columnType("INT4", LogicalTypeInteger)},
+				{Name: "count", FieldName: "count", Type: columnType("INTEGER", LogicalTypeInteger)},
+				{Name: "price", FieldName: "price", Type: columnType("NUMERIC", LogicalTypeDecimal)},
+				{Name: "data", FieldName: "data", Type: columnType("BYTEA", LogicalTypeBinary)},
+				{Name: "created", FieldName: "created", Type: columnType("TIMESTAMPTZ", LogicalTypeTimestamp)},
+				{Name: "updated", FieldName: "updated", Type: columnType("DATETIME", LogicalTypeTimestamp)},
+				{Name: "day", FieldName: "day", Type: columnType("DATE", LogicalTypeDate)},
+				{Name: "doc", FieldName: "doc", Type: columnType("JSONB", LogicalTypeJson)},
+				{Name: "tags", FieldName: "tags", Type: columnType("_TEXT", LogicalTypeArray)},
+				{Name: "uuid", FieldName: "uuid", Type: columnType("UUID", LogicalTypeUuid)},
+				{Name: "duration", FieldName: "duration", Type: columnType("INTERVAL", LogicalTypeInterval)},
+				{Name: "total", FieldName: "total"},
+			},
+			Rows: []map[string]any{{
+				"id":       int64(9007199254740993),
+				"small":    int64(5),
+				"count":    int64(7),
+				"price":    "12.30",
+				"data":     []byte{0xde, 0xad, 0xbe, 0xef},
+				"created":  time.Date(2024, 1, 2, 3, 4, 5, 600, time.FixedZone("", 2*60*60)),
+				"updated":  "2024-01-02 03:04:05.5",
+				"day":      time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+				"doc":      `{"a": [1, 2]}`,
+				"tags":     `{a,"b c",NULL,"d\"e"}`,
+				"uuid":     "6d8e1f6e-5b55-4b53-9d2a-7c2c6b8f3f0e",
+				"duration": "1 day 02:00:00",
+				"total":    int64(-9007199254740993),
+			}},
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		data := newData()
+		EncodeQueryData(data, EncodingOptions{})
+		assert.Equal(t, "\xde\xad\xbe\xef", data.Rows[0]["data"])
+		assert.Equal(t, int64(9007199254740993), data.Rows[0]["id"])
+	})
+
+	t.Run("lossless", func(t *testing.T) {
+		data := newData()
+		EncodeQueryData(data, EncodingOptions{Encoding: EncodingLossless})
+		b, err := json.Marshal(data.Rows[0])
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"id": "9007199254740993",
+			"small": 5,
+			"count": 7,
+			"price": "12.30",
+			"data": {"encoding": "base64", "data": "3q2+7w=="},
+			"created": "2024-01-02T03:04:05.0000006+02:00",
+			"updated": "2024-01-02T03:04:05.5",
+			"day": "2024-01-02",
+			"doc": {"a": [1, 2]},
+			"tags": ["a", "b c", null, "d\"e"],
+			"uuid": "6d8e1f6e-5b55-4b53-9d2a-7c2c6b8f3f0e",
+			"duration": "1 day 02:00:00",
+			"total": "-9007199254740993"
+		}`, string(b))
+	})
+
+	t.Run("lossless hex", func(t *testing.T) {
+		data := newData()
+		EncodeQueryData(data, EncodingOptions{Encoding: EncodingLossless, BinaryEncoding: BinaryEncodingHex})
+		assert.Equal(t, BinaryValue{Encoding: BinaryEncodingHex, Data: "deadbeef"}, data.Rows[0]["data"])
+	})
+}
+
+func TestParsePostgresArray(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   []any
+		wantOk bool
+	}{
+		{name: "empty", value: "{}", want: []any{}, wantOk: true},
+		{name: "numbers", value: "{1,2,3}", want: []any{"1", "2", "3"}, wantOk: true},
+		{name: "nested", value: "{{1,2},{3,NULL}}", want: []any{[]any{"1", "2"}, []any{"3", nil}}, wantOk: true},
+		{name: "quoted", value: `{"a,b","NULL","c\\d"}`, want: []any{"a,b", "NULL", `c\d`}, wantOk: true},
+		{name: "bounds", value: "[0:1]={1,2}", want: []any{"1", "2"}, wantOk: true},
+		{name: "not array", value: "abc", wantOk: false},
+		{name: "unterminated", value: "{1,2", wantOk: false},
+		{name: "trailing text", value: "{1}x", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parsePostgresArray(tt.value)
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
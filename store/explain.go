@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/minlau/mdb-tool/internal/utils/closer"
+	"github.com/pkg/errors"
+	"github.com/segmentio/encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PlanFormatJson = "json"
+	PlanFormatText = "text"
+)
+
+// ExplainResult is a query plan of a single database. Plan is json of PlanFormatJson or string of PlanFormatText.
+// Estimated cost and rows are nil if database does not report them(i.e. firebird).
+type ExplainResult struct {
+	GroupName                   string      `json:"groupName"`
+	Format                      string      `json:"format"`
+	Plan                        any         `json:"plan"`
+	EstimatedCost               *float64    `json:"estimatedCost"`
+	EstimatedRows               *float64    `json:"estimatedRows"`
+	Error                       *QueryError `json:"error"`
+	ExecutionTimeInMilliseconds int64       `json:"executionTimeInMilliseconds"`
+}
+
+// ExplainQuery returns estimated query plan of every database of group type(or given groups). Query is not executed,
+// but plan is requested in a transaction, which is rolled back.
+func (s *DatabaseStore) ExplainQuery(ctx context.Context, groupType string, groupNames []string,
+	query string) ([]ExplainResult, error) {
+	instances, err := s.groupDatabaseInstances(groupType, groupNames)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		if len(splitStatements(query, instance.Config.Type)) != 1 {
+			return nil, errors.New("explain query must be a single statement")
+		}
+	}
+
+	results := make([]ExplainResult, len(instances))
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance DatabaseInstance) {
+			defer wg.Done()
+			results[i] = s.explainDatabaseQuery(ctx, instance, query)
+		}(i, instance)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func (s *DatabaseStore) explainDatabaseQuery(ctx context.Context, instance DatabaseInstance,
+	query string) ExplainResult {
+	result := ExplainResult{GroupName: instance.Config.GroupName}
+	// plan reveals structure of denied tables, but query is not executed, so destructive statements need no
+	// confirmation
+	violation := checkPolicies(s.policies, instance.Config.DatabaseGroup, instance.Config.Type, query, true)
+	if violation != nil {
+		result.Error = NewQueryError(violation)
+		return result
+	}
+	conn, releaseSlot, err := s.beginTx(ctx, instance)
+	if err != nil {
+		result.Error = NewQueryError(err)
+		return result
+	}
+	defer releaseSlot()
+	defer conn.rollback()
+
+	start := time.Now()
+	query = splitStatements(query, instance.Config.Type)[0].text
+	switch instance.Config.Type {
+	case "postgresql":
+		err = explainPostgresql(ctx, conn.tx, query, &result)
+	case "mysql":
+		err = explainMysql(ctx, conn.tx, query, &result)
+	case "firebird":
+		err = explainFirebird(ctx, conn.tx, query, &result)
+	default:
+		err = errors.Errorf("explain is not supported by database type: %s", instance.Config.Type)
+	}
+	result.ExecutionTimeInMilliseconds = time.Since(start).Milliseconds()
+	result.Error = NewQueryError(err)
+	return result
+}
+
+// queryJsonPlan executes explain query, which returns json plan in the first column of a single row.
+func queryJsonPlan(ctx context.Context, tx *sqlx.Tx, query string) (any, error) {
+	var plan string
+	err := tx.QueryRowxContext(ctx, query).Scan(&plan)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	err = json.Unmarshal([]byte(plan), &value)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse plan")
+	}
+	return value, nil
+}
+
+// explainPostgresql reads plan of EXPLAIN (FORMAT JSON): [{"Plan": {"Total Cost": 1.5, "Plan Rows": 10, ...}}].
+func explainPostgresql(ctx context.Context, tx *sqlx.Tx, query string, result *ExplainResult) error {
+	plan, err := queryJsonPlan(ctx, tx, "EXPLAIN (FORMAT JSON) "+query)
+	if err != nil {
+		return err
+	}
+	result.Format = PlanFormatJson
+	result.Plan = plan
+	result.EstimatedCost, result.EstimatedRows = postgresqlEstimates(plan)
+	return nil
+}
+
+func postgresqlEstimates(plan any) (cost *float64, rows *float64) {
+	root := jsonPath(plan, 0, "Plan")
+	return jsonNumber(jsonPath(root, "Total Cost")), jsonNumber(jsonPath(root, "Plan Rows"))
+}
+
+// explainMysql reads plan of EXPLAIN FORMAT=JSON. Cost is a total query cost, rows is a rows count produced by the last
+// joined table.
+func explainMysql(ctx context.Context, tx *sqlx.Tx, query string, result *ExplainResult) error {
+	plan, err := queryJsonPlan(ctx, tx, "EXPLAIN FORMAT=JSON "+query)
+	if err != nil {
+		return err
+	}
+	result.Format = PlanFormatJson
+	result.Plan = plan
+	result.EstimatedCost, result.EstimatedRows = mysqlEstimates(plan)
+	return nil
+}
+
+func mysqlEstimates(plan any) (cost *float64, rows *float64) {
+	queryBlock := jsonPath(plan, "query_block")
+	table := jsonPath(queryBlock, "table")
+	if nestedLoop, ok := jsonPath(queryBlock, "nested_loop").([]any); ok && len(nestedLoop) > 0 {
+		table = jsonPath(nestedLoop[len(nestedLoop)-1], "table")
+	}
+	return jsonNumber(jsonPath(queryBlock, "cost_info", "query_cost")),
+		jsonNumber(jsonPath(table, "rows_produced_per_join"))
+}
+
+// explainFirebird prepares query and reads its explained plan from monitoring tables. Statement is only prepared, so
+// plan is the same as returned by isc_info_sql_get_plan(SET PLAN of isql), which is not exposed by database driver.
+// MON$EXPLAINED_PLAN requires firebird 3 or newer.
+func explainFirebird(ctx context.Context, tx *sqlx.Tx, query string, result *ExplainResult) error {
+	var version string
+	err := tx.QueryRowxContext(ctx, "SELECT RDB$GET_CONTEXT('SYSTEM', 'ENGINE_VERSION') FROM RDB$DATABASE").
+		Scan(&version)
+	if err != nil {
+		return errors.Wrap(err, "failed to read server version")
+	}
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return errors.Errorf("unknown server version: %s", version)
+	}
+	if major < 3 {
+		return errors.Errorf("explain requires firebird 3 or newer, server version: %s", version)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer closer.Handle(stmt, "statement")
+
+	var plan *string
+	// monitoring snapshot is created on first access of monitoring tables in a transaction, so it includes prepared
+	// statement
+	err = tx.QueryRowxContext(ctx, `SELECT MON$EXPLAINED_PLAN FROM MON$STATEMENTS
+		WHERE MON$ATTACHMENT_ID = CURRENT_CONNECTION AND MON$SQL_TEXT = ?
+		ORDER BY MON$STATEMENT_ID DESC ROWS 1`, query).Scan(&plan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("prepared statement is not found in MON$STATEMENTS")
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read plan")
+	}
+	if plan == nil {
+		return errors.New("plan is not reported by server")
+	}
+	result.Format = PlanFormatText
+	result.Plan = *plan
+	return nil
+}
+
+// jsonPath returns value of decoded json by object keys and array indexes. Nil is returned if path does not exist.
+func jsonPath(value any, path ...any) any {
+	for _, item := range path {
+		switch key := item.(type) {
+		case string:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil
+			}
+			value = object[key]
+		case int:
+			array, ok := value.([]any)
+			if !ok || key >= len(array) {
+				return nil
+			}
+			value = array[key]
+		}
+	}
+	return value
+}
+
+// jsonNumber returns json number or numeric string(mysql reports costs as strings).
+func jsonNumber(value any) *float64 {
+	switch v := value.(type) {
+	case float64:
+		return &v
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err == nil {
+			return &f
+		}
+	}
+	return nil
+}
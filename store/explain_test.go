@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeJson(t *testing.T, s string) any {
+	var value any
+	require.NoError(t, json.Unmarshal([]byte(s), &value))
+	return value
+}
+
+func Test_postgresqlEstimates(t *testing.T) {
+	cost, rows := postgresqlEstimates(decodeJson(t, `[{"Plan": {"Node Type": "Seq Scan", "Total Cost": 35.5,
+		"Plan Rows": 2550}}]`))
+	assert.Equal(t, 35.5, *cost)
+	assert.Equal(t, float64(2550), *rows)
+
+	cost, rows = postgresqlEstimates(decodeJson(t, `[]`))
+	assert.Nil(t, cost)
+	assert.Nil(t, rows)
+}
+
+func Test_mysqlEstimates(t *testing.T) {
+	tests := []struct {
+		name     string
+		plan     string
+		wantCost *float64
+		wantRows *float64
+	}{
+		{
+			name: "single table",
+			plan: `{"query_block": {"cost_info": {"query_cost": "1.25"},
+				"table": {"table_name": "t", "rows_produced_per_join": 10}}}`,
+			wantCost: ptr(1.25),
+			wantRows: ptr(10.0),
+		},
+		{
+			name: "join",
+			plan: `{"query_block": {"cost_info": {"query_cost": "7.50"}, "nested_loop": [
+				{"table": {"table_name": "a", "rows_produced_per_join": 3}},
+				{"table": {"table_name": "b", "rows_produced_per_join": 6}}]}}`,
+			wantCost: ptr(7.5),
+			wantRows: ptr(6.0),
+		},
+		{
+			name: "no tables",
+			plan: `{"query_block": {"select_id": 1, "message": "No tables used"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost, rows := mysqlEstimates(decodeJson(t, tt.plan))
+			assert.Equal(t, tt.wantCost, cost)
+			assert.Equal(t, tt.wantRows, rows)
+		})
+	}
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+func TestDatabaseStore_ExplainQuery(t *testing.T) {
+	s := newSqliteTestStore(t)
+
+	_, err := s.ExplainQuery(context.Background(), "t", nil, "select 1; select 2")
+	assert.EqualError(t, err, "explain query must be a single statement")
+
+	results, err := s.ExplainQuery(context.Background(), "t", []string{"a"}, "select 1")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "a", results[0].GroupName)
+	assert.Equal(t, "explain is not supported by database type: sqlite", results[0].Error.Message)
+
+	s.policies = mustNewPolicies(t, []PolicyConfig{{
+		Name:      "fruit",
+		GroupType: "t",
+		Deny:      []PolicyDenyConfig{{Table: "fruit", Columns: []string{"name"}}},
+	}})
+	results, err = s.ExplainQuery(context.Background(), "t", []string{"a"}, "select name from fruit")
+	require.NoError(t, err)
+	require.NotNil(t, results[0].Error)
+	assert.Equal(t, QueryErrorCodePolicyViolation, results[0].Error.Code)
+	assert.Equal(t, "query violates policy fruit: statement 1: select of column fruit.name is denied",
+		results[0].Error.Message)
+}
+
+// firebirdTestConnector is a database/sql connector, which answers queries of explainFirebird. Plan is found only for
+// prepared statements.
+type firebirdTestConnector struct {
+	version string
+	plan    driver.Value
+}
+
+func (c *firebirdTestConnector) Connect(context.Context) (driver.Conn, error) {
+	return &firebirdTestConn{connector: c}, nil
+}
+
+func (c *firebirdTestConnector) Driver() driver.Driver {
+	return nil
+}
+
+type firebirdTestConn struct {
+	connector *firebirdTestConnector
+	prepared  []string
+}
+
+func (c *firebirdTestConn) Prepare(query string) (driver.Stmt, error) {
+	if !strings.Contains(query, "RDB$DATABASE") && !strings.Contains(query, "MON$STATEMENTS") {
+		c.prepared = append(c.prepared, query)
+	}
+	return &firebirdTestStmt{conn: c, query: query}, nil
+}
+
+func (c *firebirdTestConn) Close() error {
+	return nil
+}
+
+func (c *firebirdTestConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *firebirdTestConn) Commit() error {
+	return nil
+}
+
+func (c *firebirdTestConn) Rollback() error {
+	return nil
+}
+
+type firebirdTestStmt struct {
+	conn  *firebirdTestConn
+	query string
+}
+
+func (s *firebirdTestStmt) Close() error {
+	return nil
+}
+
+func (s *firebirdTestStmt) NumInput() int {
+	return -1
+}
+
+func (s *firebirdTestStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *firebirdTestStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows := &firebirdTestRows{}
+	switch {
+	case strings.Contains(s.query, "ENGINE_VERSION"):
+		rows.values = [][]driver.Value{{s.conn.connector.version}}
+	case strings.Contains(s.query, "MON$EXPLAINED_PLAN"):
+		for _, query := range s.conn.prepared {
+			if query == args[0] {
+				rows.values = [][]driver.Value{{s.conn.connector.plan}}
+			}
+		}
+	}
+	return rows, nil
+}
+
+type firebirdTestRows struct {
+	values [][]driver.Value
+}
+
+func (r *firebirdTestRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *firebirdTestRows) Close() error {
+	return nil
+}
+
+func (r *firebirdTestRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func Test_explainFirebird(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		plan     driver.Value
+		wantPlan string
+		wantErr  string
+	}{
+		{
+			name:     "plan",
+			version:  "3.0.10",
+			plan:     "Select Expression\n    -> Table \"T\" Full Scan",
+			wantPlan: "Select Expression\n    -> Table \"T\" Full Scan",
+		},
+		{
+			name:    "plan is not reported",
+			version: "4.0.2",
+			wantErr: "plan is not reported by server",
+		},
+		{
+			name:    "old server",
+			version: "2.5.9",
+			wantErr: "explain requires firebird 3 or newer, server version: 2.5.9",
+		},
+		{
+			name:    "unknown version",
+			version: "WI-V3",
+			wantErr: "unknown server version: WI-V3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqlx.NewDb(sql.OpenDB(&firebirdTestConnector{version: tt.version, plan: tt.plan}), "firebirdsql")
+			defer db.Close() //nolint:errcheck
+			tx, err := db.Beginx()
+			require.NoError(t, err)
+			defer tx.Rollback() //nolint:errcheck
+
+			var result ExplainResult
+			err = explainFirebird(context.Background(), tx, "select * from t", &result)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, PlanFormatText, result.Format)
+			assert.Equal(t, tt.wantPlan, result.Plan)
+		})
+	}
+}
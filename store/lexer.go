@@ -0,0 +1,339 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	// tokenWord is an unquoted keyword, identifier, number or parameter
+	tokenWord tokenKind = iota
+	tokenQuotedIdentifier
+	tokenString
+	// tokenPunctuation is a single character: ( ) , . ; * = etc.
+	tokenPunctuation
+)
+
+type token struct {
+	kind tokenKind
+	// text is a word as is, unquoted identifier or string content
+	text string
+	// start and end are byte offsets of token in query
+	start int
+	end   int
+	// executable is true for tokens inside of mysql executable comment(/*! */)
+	executable bool
+}
+
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) isPunctuation(p string) bool {
+	return t.kind == tokenPunctuation && t.text == p
+}
+
+// tokenize splits query into tokens. Whitespace and comments are skipped. It is not a full SQL parser, it only knows
+// enough of postgresql, mysql and firebird lexical rules to not confuse literals and comments with keywords.
+func tokenize(query string, sqlType string) []token {
+	var tokens []token
+	// inExecutableComment is true inside of mysql /*! */ or /*+ */ comment, which content is executed by server
+	inExecutableComment := false
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		n := len(tokens)
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '*' && inExecutableComment && strings.HasPrefix(query[i:], "*/"):
+			inExecutableComment = false
+			i += 2
+		case c == '/' && sqlType == "mysql" && !inExecutableComment && executableCommentLength(query[i:]) > 0:
+			inExecutableComment = true
+			i += executableCommentLength(query[i:])
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '#' && sqlType == "mysql":
+			end := strings.IndexByte(query[i:], '\n')
+			if end == -1 {
+				i = len(query)
+			} else {
+				i += end + 1
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end == -1 {
+				i = len(query)
+			} else {
+				i += 2 + end + 2
+			}
+		case c == '\'':
+			end, text := scanQuoted(query, i, '\'', sqlType == "mysql")
+			tokens = append(tokens, token{kind: tokenString, text: text, start: i, end: end})
+			i = end
+		case (c == 'E' || c == 'e') && sqlType == "postgresql" && strings.HasPrefix(query[i+1:], "'"):
+			// postgresql escape string constant E'it\'s'
+			end, text := scanQuoted(query, i+1, '\'', true)
+			tokens = append(tokens, token{kind: tokenString, text: text, start: i, end: end})
+			i = end
+		case c == '"':
+			end, text := scanQuoted(query, i, '"', sqlType == "mysql")
+			kind := tokenQuotedIdentifier
+			if sqlType == "mysql" {
+				kind = tokenString
+			}
+			tokens = append(tokens, token{kind: kind, text: text, start: i, end: end})
+			i = end
+		case c == '`':
+			end, text := scanQuoted(query, i, '`', false)
+			tokens = append(tokens, token{kind: tokenQuotedIdentifier, text: text, start: i, end: end})
+			i = end
+		case c == '$' && sqlType == "postgresql" && dollarQuoteTag(query[i:]) != "":
+			tag := dollarQuoteTag(query[i:])
+			contentStart := i + len(tag)
+			end := strings.Index(query[contentStart:], tag)
+			if end == -1 {
+				tokens = append(tokens, token{kind: tokenString, text: query[contentStart:], start: i, end: len(query)})
+				i = len(query)
+			} else {
+				tokens = append(tokens, token{kind: tokenString, text: query[contentStart : contentStart+end], start: i,
+					end: contentStart + end + len(tag)})
+				i = contentStart + end + len(tag)
+			}
+		case isWordChar(rune(c)) || c == '@' || c >= 0x80:
+			start := i
+			for i < len(query) && (isWordChar(rune(query[i])) || query[i] == '@' || query[i] >= 0x80) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: query[start:i], start: start, end: i})
+		default:
+			tokens = append(tokens, token{kind: tokenPunctuation, text: string(c), start: i, end: i + 1})
+			i++
+		}
+		if len(tokens) > n {
+			tokens[n].executable = inExecutableComment
+		}
+	}
+	return tokens
+}
+
+// executableCommentLength returns length of mysql executable comment start(/*!, /*!50000, /*M!100100 or optimizer hint
+// /*+) at the beginning of text or 0 if text does not start with it. Version is a part of the start only if it has 5 or
+// 6 digits, otherwise digits are a content of comment, i.e. /*!1 */ is 1.
+func executableCommentLength(text string) int {
+	var n int
+	switch {
+	case strings.HasPrefix(text, "/*+"), strings.HasPrefix(text, "/*!"):
+		n = 3
+	case strings.HasPrefix(text, "/*M!"):
+		n = 4
+	default:
+		return 0
+	}
+	if text[n-1] == '+' {
+		return n
+	}
+	digits := 0
+	for n+digits < len(text) && text[n+digits] >= '0' && text[n+digits] <= '9' {
+		digits++
+	}
+	if digits == 5 || digits == 6 {
+		n += digits
+	}
+	return n
+}
+
+func isWordChar(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// scanQuoted returns end offset and unescaped content of quoted text starting at start. Doubled quote is an escaped
+// quote. Backslash escapes next character if backslashEscapes is true.
+func scanQuoted(query string, start int, quote byte, backslashEscapes bool) (int, string) {
+	var b strings.Builder
+	i := start + 1
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case backslashEscapes && c == '\\' && i+1 < len(query):
+			b.WriteByte(query[i+1])
+			i += 2
+		case c == quote && i+1 < len(query) && query[i+1] == quote:
+			b.WriteByte(quote)
+			i += 2
+		case c == quote:
+			return i + 1, b.String()
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return len(query), b.String()
+}
+
+// dollarQuoteTag returns postgresql dollar quote tag($$ or $tag$) at the start of text or empty string.
+func dollarQuoteTag(text string) string {
+	for i := 1; i < len(text); i++ {
+		c := rune(text[i])
+		if c == '$' {
+			return text[:i+1]
+		}
+		if !(c == '_' || unicode.IsLetter(c) || (i > 1 && unicode.IsDigit(c))) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// statement is a single SQL statement of a query.
+type statement struct {
+	// text is original statement text without trailing semicolon
+	text   string
+	tokens []token
+}
+
+func (s statement) firstKeyword() string {
+	if len(s.tokens) == 0 || s.tokens[0].kind != tokenWord {
+		return ""
+	}
+	return strings.ToUpper(s.tokens[0].text)
+}
+
+// splitStatements splits query by semicolons. Semicolons inside of parentheses, BEGIN ... END and CASE ... END
+// blocks(i.e. firebird EXECUTE BLOCK, mysql procedure body) do not end a statement. BEGIN is a block only in a routine
+// definition(see isRoutine), elsewhere it is a transaction start or an identifier. Firebird routine declarations
+// between AS and the body end with semicolons, so they do not end a statement either.
+func splitStatements(query string, sqlType string) []statement {
+	tokens := tokenize(query, sqlType)
+
+	var statements []statement
+	depth := 0
+	parentheses := 0
+	var current []token
+	// routine is true if current statement is a routine definition
+	routine := false
+	// inRoutineHeader is true while firebird routine declarations between AS and the first BEGIN are read
+	inRoutineHeader := false
+	// previousEnd is an offset after the last statement separator
+	previousEnd := 0
+	// flush adds current statement, next is an offset of the following statement separator
+	flush := func(next int) {
+		if len(current) > 0 {
+			start, end := current[0].start, current[len(current)-1].end
+			// statement inside of executable comment keeps comment markers, so that server applies version condition
+			if current[0].executable {
+				if k := strings.LastIndex(query[previousEnd:start], "/*"); k >= 0 {
+					start = previousEnd + k
+				}
+			}
+			if current[len(current)-1].executable {
+				if k := strings.Index(query[end:next], "*/"); k >= 0 {
+					end += k + 2
+				}
+			}
+			statements = append(statements, statement{
+				text:   strings.TrimSpace(query[start:end]),
+				tokens: current,
+			})
+		}
+		current = nil
+		routine = false
+	}
+
+	for i, t := range tokens {
+		if !routine && depth == 0 && isRoutine(current, t) {
+			routine = true
+		}
+		switch {
+		case t.isPunctuation(";") && depth == 0 && parentheses == 0 && !inRoutineHeader:
+			flush(t.start)
+			previousEnd = t.end
+			continue
+		case t.isPunctuation("("):
+			parentheses++
+		case t.isPunctuation(")") && parentheses > 0:
+			parentheses--
+		case sqlType == "firebird" && routine && depth == 0 && parentheses == 0 && t.isKeyword("AS"):
+			inRoutineHeader = true
+		case (t.isKeyword("BEGIN") && routine) || t.isKeyword("CASE"):
+			inRoutineHeader = false
+			if !(t.isKeyword("CASE") && i > 0 && tokens[i-1].isKeyword("END")) {
+				depth++
+			}
+		case t.isKeyword("END") && depth > 0:
+			// END IF, END LOOP etc. close blocks, which are not counted
+			if i+1 < len(tokens) && tokens[i+1].kind == tokenWord && !tokens[i+1].isKeyword("CASE") &&
+				isCompoundEnd(tokens[i+1].text) {
+				break
+			}
+			depth--
+		}
+		current = append(current, t)
+	}
+	flush(len(query))
+	return statements
+}
+
+// IsCompleteQuery returns true if query ends with a semicolon, which ends the last statement(see splitStatements).
+// Semicolons inside of literals, comments and blocks do not complete query.
+func IsCompleteQuery(query string, sqlType string) bool {
+	tokens := tokenize(query, sqlType)
+	if len(tokens) == 0 || !tokens[len(tokens)-1].isPunctuation(";") {
+		return false
+	}
+	statements := splitStatements(query, sqlType)
+	if len(statements) == 0 {
+		return true
+	}
+	// semicolon, which ends a statement, is not a part of it
+	last := statements[len(statements)-1].tokens
+	return last[len(last)-1].end <= tokens[len(tokens)-1].start
+}
+
+// routineModifiers are words, which can be between CREATE and routine kind, i.e. CREATE OR ALTER PROCEDURE or mysql
+// CREATE DEFINER = CURRENT_USER TRIGGER.
+var routineModifiers = map[string]bool{
+	"OR": true, "ALTER": true, "REPLACE": true, "AGGREGATE": true, "DEFINER": true, "CURRENT_USER": true,
+}
+
+// isRoutine returns true if t makes statement an EXECUTE BLOCK or a procedure, function, trigger, event or package
+// definition.
+func isRoutine(current []token, t token) bool {
+	if len(current) == 0 || t.kind != tokenWord {
+		return false
+	}
+	switch strings.ToUpper(t.text) {
+	case "BLOCK":
+		return current[0].isKeyword("EXECUTE") && len(current) == 1
+	case "PROCEDURE", "FUNCTION", "TRIGGER", "EVENT", "PACKAGE":
+		if !current[0].isKeyword("CREATE") && !current[0].isKeyword("ALTER") && !current[0].isKeyword("RECREATE") {
+			return false
+		}
+		for i := 1; i < len(current); i++ {
+			c := current[i]
+			switch {
+			case c.kind == tokenWord && routineModifiers[strings.ToUpper(c.text)]:
+			case c.kind == tokenWord && current[i-1].isPunctuation("="):
+				// DEFINER = user@host
+			case c.kind == tokenWord && strings.HasPrefix(c.text, "@"):
+			case c.kind == tokenQuotedIdentifier || c.kind == tokenString:
+			case c.isPunctuation("=") || c.isPunctuation("(") || c.isPunctuation(")"):
+			default:
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func isCompoundEnd(word string) bool {
+	switch strings.ToUpper(word) {
+	case "IF", "LOOP", "WHILE", "REPEAT", "FOR":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		sqlType string
+		want    []string
+	}{
+		{
+			name:    "single statement",
+			query:   "select 1",
+			sqlType: "postgresql",
+			want:    []string{"select 1"},
+		},
+		{
+			name:    "multiple statements",
+			query:   "select 1; select 2;\n",
+			sqlType: "postgresql",
+			want:    []string{"select 1", "select 2"},
+		},
+		{
+			name:    "semicolons in literals and comments",
+			query:   "select ';' as \"a;b\" -- c;d\n; /* e;f */ select 'it''s;'",
+			sqlType: "postgresql",
+			want:    []string{"select ';' as \"a;b\"", "select 'it''s;'"},
+		},
+		{
+			name:    "postgresql dollar quote",
+			query:   "create function f() returns int as $body$ begin return 1; end; $body$ language plpgsql; select 1",
+			sqlType: "postgresql",
+			want: []string{
+				"create function f() returns int as $body$ begin return 1; end; $body$ language plpgsql",
+				"select 1",
+			},
+		},
+		{
+			name:    "mysql backslash escape and hash comment",
+			query:   "select 'a\\';b'; # c;d\nselect `x;y`",
+			sqlType: "mysql",
+			want:    []string{"select 'a\\';b'", "select `x;y`"},
+		},
+		{
+			name:    "postgresql escape string",
+			query:   "select E'it\\'s;', e'\\\\'; select 'a\\'; select 2",
+			sqlType: "postgresql",
+			want:    []string{"select E'it\\'s;', e'\\\\'", "select 'a\\'", "select 2"},
+		},
+		{
+			name:    "firebird execute block",
+			query:   "execute block as declare x int; begin x = 1; if (x = 1) then x = 2; end; select 1 from rdb$database",
+			sqlType: "firebird",
+			want: []string{
+				"execute block as declare x int; begin x = 1; if (x = 1) then x = 2; end",
+				"select 1 from rdb$database",
+			},
+		},
+		{
+			name:    "mysql procedure with compound statements",
+			query:   "create procedure p() begin if 1 = 1 then select case when 1 = 1 then 1 end; end if; end; select 1",
+			sqlType: "mysql",
+			want: []string{
+				"create procedure p() begin if 1 = 1 then select case when 1 = 1 then 1 end; end if; end",
+				"select 1",
+			},
+		},
+		{
+			name:    "transaction begin",
+			query:   "begin; update t set a = 1; commit",
+			sqlType: "postgresql",
+			want:    []string{"begin", "update t set a = 1", "commit"},
+		},
+		{
+			name:    "semicolons in parentheses",
+			query:   "create rule r as on insert to t do also (insert into a values (1); insert into b values (2)); select 1",
+			sqlType: "postgresql",
+			want: []string{
+				"create rule r as on insert to t do also (insert into a values (1); insert into b values (2))",
+				"select 1",
+			},
+		},
+		{
+			name:    "firebird routine statement without body",
+			query:   "alter trigger t inactive; select 1 from rdb$database",
+			sqlType: "firebird",
+			want:    []string{"alter trigger t inactive", "select 1 from rdb$database"},
+		},
+		{
+			name:    "firebird trigger",
+			query:   "create or alter trigger t for a active before insert as declare x int; begin x = 1; end; select 1",
+			sqlType: "firebird",
+			want: []string{
+				"create or alter trigger t for a active before insert as declare x int; begin x = 1; end",
+				"select 1",
+			},
+		},
+		{
+			name:    "begin identifier",
+			query:   "select begin from t; select 2",
+			sqlType: "postgresql",
+			want:    []string{"select begin from t", "select 2"},
+		},
+		{
+			name:    "begin identifier in case",
+			query:   "select case when begin = 1 then 2 end from t; select 2",
+			sqlType: "firebird",
+			want:    []string{"select case when begin = 1 then 2 end from t", "select 2"},
+		},
+		{
+			name:    "mysql trigger with definer",
+			query:   "create definer = `a`@`%` trigger t before insert on x for each row begin set new.a = 1; end; select 1",
+			sqlType: "mysql",
+			want: []string{
+				"create definer = `a`@`%` trigger t before insert on x for each row begin set new.a = 1; end",
+				"select 1",
+			},
+		},
+		{
+			name:    "view with routine word",
+			query:   "create view v as select trigger, begin from t; select 1",
+			sqlType: "firebird",
+			want:    []string{"create view v as select trigger, begin from t", "select 1"},
+		},
+		{
+			name:    "only comments",
+			query:   "-- select 1;",
+			sqlType: "postgresql",
+			want:    nil,
+		},
+		{
+			name:    "mysql executable comment",
+			query:   "/*!50000 DROP TABLE users */; select /*!40101 SQL_NO_CACHE */ 1",
+			sqlType: "mysql",
+			want:    []string{"/*!50000 DROP TABLE users */", "select /*!40101 SQL_NO_CACHE */ 1"},
+		},
+		{
+			name:    "mysql executable comment with semicolons",
+			query:   "select /*!1; drop table users; select */ 1",
+			sqlType: "mysql",
+			want:    []string{"select /*!1", "drop table users", "select */ 1"},
+		},
+		{
+			name:    "mysql optimizer hint",
+			query:   "select /*+ BKA(t) */ 1; /*+ drop table users */",
+			sqlType: "mysql",
+			want:    []string{"select /*+ BKA(t) */ 1", "/*+ drop table users */"},
+		},
+		{
+			name:    "executable comment of postgresql",
+			query:   "/*!50000 DROP TABLE users */",
+			sqlType: "postgresql",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range splitStatements(tt.query, tt.sqlType) {
+				got = append(got, s.text)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsCompleteQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		sqlType string
+		want    bool
+	}{
+		{query: "select 1;", sqlType: "postgresql", want: true},
+		{query: "select 1; select 2", sqlType: "postgresql", want: false},
+		{query: "select 1 -- a;", sqlType: "postgresql", want: false},
+		{query: "select 'a;\nb;", sqlType: "postgresql", want: false},
+		{query: "select 'a;\nb';", sqlType: "postgresql", want: true},
+		{query: "execute block as declare x int;", sqlType: "firebird", want: false},
+		{query: "execute block as begin\nx = 1;", sqlType: "firebird", want: false},
+		{query: "execute block as begin\nx = 1;\nend;", sqlType: "firebird", want: true},
+		{query: ";", sqlType: "mysql", want: true},
+		{query: "/*!50000 drop table t */;", sqlType: "mysql", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsCompleteQuery(tt.query, tt.sqlType))
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize(`select "My ""Col""", 'x' from s.t`, "postgresql")
+	kinds := make([]tokenKind, 0, len(tokens))
+	texts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		kinds = append(kinds, token.kind)
+		texts = append(texts, token.text)
+	}
+	assert.Equal(t, []string{"select", `My "Col"`, ",", "x", "from", "s", ".", "t"}, texts)
+	assert.Equal(t, []tokenKind{tokenWord, tokenQuotedIdentifier, tokenPunctuation, tokenString, tokenWord, tokenWord,
+		tokenPunctuation, tokenWord}, kinds)
+}
+
+func TestTokenize_postgresqlEscapeString(t *testing.T) {
+	tokens := tokenize(`select E'\'' ; delete from users; --'`, "postgresql")
+	require.Len(t, tokens, 7)
+	assert.Equal(t, token{kind: tokenString, text: "'", start: 7, end: 12}, tokens[1])
+	assert.True(t, tokens[3].isKeyword("delete"))
+}
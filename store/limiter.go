@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConcurrencyConfig limits how many queries are executed at the same time. Zero or negative value means no limit.
+type ConcurrencyConfig struct {
+	MaxConcurrentQueries        int
+	MaxConcurrentQueriesPerHost int
+}
+
+// queryLimiter is a global and per host(hostname:port) semaphore. Many databases can share the same server, so
+// limiting only the total count is not enough to protect a single server from too many connections.
+type queryLimiter struct {
+	global       chan struct{}
+	perHostLimit int
+
+	m     sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func newQueryLimiter(config ConcurrencyConfig) *queryLimiter {
+	l := &queryLimiter{
+		perHostLimit: config.MaxConcurrentQueriesPerHost,
+		hosts:        make(map[string]chan struct{}),
+	}
+	if config.MaxConcurrentQueries > 0 {
+		l.global = make(chan struct{}, config.MaxConcurrentQueries)
+	}
+	return l
+}
+
+func hostKey(c DatabaseConnConfig) string {
+	return fmt.Sprintf("%s:%d", c.Hostname, c.Port)
+}
+
+func (l *queryLimiter) hostSemaphore(host string) chan struct{} {
+	if l.perHostLimit <= 0 {
+		return nil
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.perHostLimit)
+		l.hosts[host] = sem
+	}
+	return sem
+}
+
+// acquire blocks until both host and global slots are available. Host slot is acquired first, so that global slot is
+// not held while waiting for a busy host. Returned wait time is zero if execution was not queued.
+func (l *queryLimiter) acquire(ctx context.Context, host string) (release func(), wait time.Duration, err error) {
+	start := time.Now()
+	queued := false
+
+	hostSem := l.hostSemaphore(host)
+	if hostSem != nil {
+		q, err := acquireSemaphore(ctx, hostSem)
+		if err != nil {
+			return nil, time.Since(start), err
+		}
+		queued = queued || q
+	}
+
+	if l.global != nil {
+		q, err := acquireSemaphore(ctx, l.global)
+		if err != nil {
+			releaseSemaphore(hostSem)
+			return nil, time.Since(start), err
+		}
+		queued = queued || q
+	}
+
+	if queued {
+		wait = time.Since(start)
+	}
+	return func() {
+		releaseSemaphore(l.global)
+		releaseSemaphore(hostSem)
+	}, wait, nil
+}
+
+// acquireSemaphore returns true if caller had to wait for a free slot.
+func acquireSemaphore(ctx context.Context, sem chan struct{}) (bool, error) {
+	select {
+	case sem <- struct{}{}:
+		return false, nil
+	default:
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return true, nil
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
+
+func releaseSemaphore(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLimiter_Acquire(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ConcurrencyConfig
+		hosts   []string
+		wantMax int32
+	}{
+		{
+			name:    "global limit",
+			config:  ConcurrencyConfig{MaxConcurrentQueries: 2},
+			hosts:   []string{"a:1", "b:1", "c:1", "d:1", "e:1", "f:1"},
+			wantMax: 2,
+		},
+		{
+			name:    "per host limit",
+			config:  ConcurrencyConfig{MaxConcurrentQueriesPerHost: 1},
+			hosts:   []string{"a:1", "a:1", "a:1", "a:1"},
+			wantMax: 1,
+		},
+		{
+			name:    "per host limit lower than global",
+			config:  ConcurrencyConfig{MaxConcurrentQueries: 4, MaxConcurrentQueriesPerHost: 1},
+			hosts:   []string{"a:1", "a:1", "a:1", "b:1", "b:1", "b:1"},
+			wantMax: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newQueryLimiter(tt.config)
+			var current, peak int32
+			var queued int32
+			var wg sync.WaitGroup
+			for _, host := range tt.hosts {
+				wg.Add(1)
+				go func(host string) {
+					defer wg.Done()
+					release, wait, err := l.acquire(context.Background(), host)
+					if !assert.NoError(t, err) {
+						return
+					}
+					defer release()
+					if wait > 0 {
+						atomic.AddInt32(&queued, 1)
+					}
+
+					n := atomic.AddInt32(&current, 1)
+					for {
+						m := atomic.LoadInt32(&peak)
+						if n <= m || atomic.CompareAndSwapInt32(&peak, m, n) {
+							break
+						}
+					}
+					time.Sleep(20 * time.Millisecond)
+					atomic.AddInt32(&current, -1)
+				}(host)
+			}
+			wg.Wait()
+			assert.Equal(t, tt.wantMax, peak)
+			assert.Positive(t, queued)
+		})
+	}
+}
+
+func TestQueryLimiter_AcquireCanceled(t *testing.T) {
+	l := newQueryLimiter(ConcurrencyConfig{MaxConcurrentQueries: 1})
+	release, wait, err := l.acquire(context.Background(), "a:1")
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = l.acquire(ctx, "a:1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release, _, err = l.acquire(context.Background(), "a:1")
+	assert.NoError(t, err)
+	release()
+}
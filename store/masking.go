@@ -0,0 +1,362 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Masking actions.
+const (
+	// MaskingActionMask replaces value with maskedValue
+	MaskingActionMask = "mask"
+	// MaskingActionPartial replaces all but KeepFirst and KeepLast characters with *
+	MaskingActionPartial = "partial"
+	// MaskingActionHash replaces value with hex of SHA-256(HMAC-SHA-256 if MaskingConfig.HashKey is set) of value
+	MaskingActionHash = "hash"
+	// MaskingActionNull replaces value with null
+	MaskingActionNull = "null"
+)
+
+const maskedValue = "****"
+
+type MaskingConfig struct {
+	// HashKey is a key of HMAC used by MaskingActionHash. Plain SHA-256 is used if not set
+	HashKey string
+	Rules   []MaskingRuleConfig
+}
+
+// MaskingRuleConfig masks values of result columns. Patterns are case-insensitive regular expressions, which must
+// match the whole name. Empty pattern matches everything. Column pattern matches result column name or a column
+// referenced by SELECT list expression of result column(i.e. SELECT email AS e). Expressions are found lexically, so
+// all result columns are masked if referencing expression can not be matched with result column(i.e. * over subquery)
+// or whole row of matching table is referenced. Value pattern is
+// matched with values itself, so it does not depend on query text.
+type MaskingRuleConfig struct {
+	// GroupType of masked databases. All if not set
+	GroupType string
+	// Table is a pattern of tables([schema.]table) referenced by statement, which results are masked
+	Table string
+	// Column is a pattern of masked column names
+	Column string
+	// Value is a pattern of masked value parts, i.e. emails in text. Whole value is masked if not set
+	Value string
+	// Action is MaskingActionMask, MaskingActionPartial, MaskingActionHash or MaskingActionNull
+	Action string
+	// KeepFirst and KeepLast are counts of characters kept by MaskingActionPartial. Default: 0 and 4
+	KeepFirst int
+	KeepLast  *int
+	// Roles are caller roles(see QueryOptions.Role) masked by rule. All roles, including empty role, if not set
+	Roles []string
+	// ExceptRoles are caller roles, which see unmasked values
+	ExceptRoles []string
+}
+
+type maskingRule struct {
+	config MaskingRuleConfig
+	table  *regexp.Regexp
+	column *regexp.Regexp
+	value  *regexp.Regexp
+	// keepLast is KeepLast with default applied
+	keepLast int
+}
+
+type masking struct {
+	hashKey []byte
+	rules   []maskingRule
+}
+
+// newMasking returns masking of config rules. Invalid rule is an error, skipping it would leave values unmasked.
+func newMasking(config MaskingConfig) (*masking, error) {
+	m := &masking{}
+	if config.HashKey != "" {
+		m.hashKey = []byte(config.HashKey)
+	}
+	for i, ruleConfig := range config.Rules {
+		rule, err := newMaskingRule(ruleConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid masking rule %d", i)
+		}
+		m.rules = append(m.rules, rule)
+	}
+	return m, nil
+}
+
+func newMaskingRule(config MaskingRuleConfig) (maskingRule, error) {
+	rule := maskingRule{config: config, keepLast: 4}
+	switch config.Action {
+	case MaskingActionMask, MaskingActionPartial, MaskingActionHash, MaskingActionNull:
+	default:
+		return rule, errors.Errorf("unknown masking action: %s", config.Action)
+	}
+	if config.KeepLast != nil {
+		rule.keepLast = *config.KeepLast
+	}
+	if config.KeepFirst < 0 || rule.keepLast < 0 {
+		return rule, errors.New("keepFirst and keepLast must not be negative")
+	}
+	var err error
+	rule.table, err = compileMaskingPattern(config.Table, true)
+	if err != nil {
+		return rule, errors.Wrap(err, "invalid table pattern")
+	}
+	rule.column, err = compileMaskingPattern(config.Column, true)
+	if err != nil {
+		return rule, errors.Wrap(err, "invalid column pattern")
+	}
+	rule.value, err = compileMaskingPattern(config.Value, false)
+	if err != nil {
+		return rule, errors.Wrap(err, "invalid value pattern")
+	}
+	return rule, nil
+}
+
+// compileMaskingPattern compiles case-insensitive pattern. It returns nil for empty pattern.
+func compileMaskingPattern(pattern string, full bool) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if full {
+		pattern = "^(?:" + pattern + ")$"
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// appliesTo returns true if rule masks results of group type for caller of options. All rules of group type apply if
+// options.MaskAll is set.
+func (r maskingRule) appliesTo(groupType string, options QueryOptions) bool {
+	if r.config.GroupType != "" && r.config.GroupType != groupType {
+		return false
+	}
+	if options.MaskAll {
+		return true
+	}
+	role := options.Role
+	if len(r.config.Roles) > 0 && !contains(r.config.Roles, role) {
+		return false
+	}
+	return !contains(r.config.ExceptRoles, role)
+}
+
+func (r maskingRule) matchesTables(tables []tableReference) bool {
+	if r.table == nil {
+		return true
+	}
+	for _, table := range tables {
+		if r.table.MatchString(table.name) ||
+			(table.schema != "" && r.table.MatchString(table.schema+"."+table.name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskedColumns returns true for every masked column of result of statement references and SELECT lists. Columns,
+// which can not be matched with SELECT list items, are masked: all columns of whole row reference of matching table
+// and all columns of * over subquery or common table expression with sensitive items.
+func (r maskingRule) maskedColumns(columns []Column, refs statementReferences, lists []selectList) []bool {
+	masked := make([]bool, len(columns))
+	for i, column := range columns {
+		masked[i] = r.column == nil || r.column.MatchString(column.Name)
+	}
+	if r.column == nil {
+		return masked
+	}
+	if r.referencesWholeRow(refs) {
+		return maskAll(masked)
+	}
+	sensitive := r.sensitiveItems(lists)
+	nested := false
+	for l, list := range lists {
+		for j := range list.items {
+			nested = nested || (list.depth != 0 && sensitive[l][j])
+		}
+	}
+	for l, list := range lists {
+		if list.depth != 0 {
+			continue
+		}
+		if nested && list.hasStar() {
+			// * might expand to sensitive items of nested lists
+			return maskAll(masked)
+		}
+		// items match columns by position unless * is expanded
+		positional := len(list.items) == len(columns) && !list.hasStar()
+		for j := range list.items {
+			if !sensitive[l][j] {
+				continue
+			}
+			if !positional {
+				return maskAll(masked)
+			}
+			masked[j] = true
+		}
+	}
+	return masked
+}
+
+// referencesWholeRow returns true if whole row of a table matching table pattern is referenced.
+func (r maskingRule) referencesWholeRow(refs statementReferences) bool {
+	for _, column := range refs.columns {
+		if !column.wholeRow {
+			continue
+		}
+		if table, ok := refs.table(column.qualifier); ok && r.matchesTables([]tableReference{table}) {
+			return true
+		}
+	}
+	return false
+}
+
+func maskAll(masked []bool) []bool {
+	for i := range masked {
+		masked[i] = true
+	}
+	return masked
+}
+
+// sensitiveItems returns true for every SELECT list item, which references a column matching column pattern or a
+// label of other sensitive item, i.e. e of SELECT e FROM (SELECT email AS e FROM users) x.
+func (r maskingRule) sensitiveItems(lists []selectList) [][]bool {
+	sensitive := make([][]bool, len(lists))
+	for l, list := range lists {
+		sensitive[l] = make([]bool, len(list.items))
+	}
+	labels := map[string]bool{}
+	for changed := true; changed; {
+		changed = false
+		for l, list := range lists {
+			for j, item := range list.items {
+				if sensitive[l][j] || !r.referencesColumn(item, labels) {
+					continue
+				}
+				sensitive[l][j] = true
+				changed = true
+				if item.label != "" {
+					labels[strings.ToLower(item.label)] = true
+				}
+			}
+		}
+	}
+	return sensitive
+}
+
+func (r maskingRule) referencesColumn(item selectItem, labels map[string]bool) bool {
+	for _, column := range item.columns {
+		if r.column.MatchString(column) || labels[strings.ToLower(column)] {
+			return true
+		}
+	}
+	return false
+}
+
+// apply masks results of query in place. Results are matched with statements by StatementIndex.
+func (m *masking) apply(group DatabaseGroup, sqlType string, query string, options QueryOptions,
+	results []*QueryData) {
+	var rules []maskingRule
+	for _, rule := range m.rules {
+		if rule.appliesTo(group.GroupType, options) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 || len(results) == 0 {
+		return
+	}
+
+	statements := splitStatements(query, sqlType)
+	for _, data := range results {
+		var refs statementReferences
+		var lists []selectList
+		if data.StatementIndex < len(statements) {
+			refs = statements[data.StatementIndex].references()
+			lists = statements[data.StatementIndex].selectLists()
+		}
+		for _, rule := range rules {
+			if !rule.matchesTables(refs.tables) {
+				continue
+			}
+			masked := rule.maskedColumns(data.Columns, refs, lists)
+			for i, column := range data.Columns {
+				if !masked[i] {
+					continue
+				}
+				for _, row := range data.Rows {
+					if value := row[column.FieldName]; value != nil {
+						row[column.FieldName] = m.maskValue(rule, value)
+					}
+				}
+			}
+		}
+	}
+}
+
+// maskValue returns masked value. Masked values are strings, except of MaskingActionNull.
+func (m *masking) maskValue(rule maskingRule, value any) any {
+	text := maskingText(value)
+	if rule.value != nil {
+		if !rule.value.MatchString(text) {
+			return value
+		}
+		if rule.config.Action == MaskingActionNull {
+			return nil
+		}
+		return rule.value.ReplaceAllStringFunc(text, func(s string) string {
+			return m.mask(rule, s)
+		})
+	}
+	if rule.config.Action == MaskingActionNull {
+		return nil
+	}
+	return m.mask(rule, text)
+}
+
+func (m *masking) mask(rule maskingRule, text string) string {
+	switch rule.config.Action {
+	case MaskingActionPartial:
+		return partialMask(text, rule.config.KeepFirst, rule.keepLast)
+	case MaskingActionHash:
+		if m.hashKey != nil {
+			h := hmac.New(sha256.New, m.hashKey)
+			h.Write([]byte(text))
+			return hex.EncodeToString(h.Sum(nil))
+		}
+		sum := sha256.Sum256([]byte(text))
+		return hex.EncodeToString(sum[:])
+	default:
+		return maskedValue
+	}
+}
+
+// partialMask replaces characters of text with * except of keepFirst and keepLast characters. Text is masked fully if
+// it is not longer than kept characters.
+func partialMask(text string, keepFirst int, keepLast int) string {
+	length := utf8.RuneCountInString(text)
+	if length <= keepFirst+keepLast {
+		return strings.Repeat("*", length)
+	}
+	runes := []rune(text)
+	for i := keepFirst; i < length-keepLast; i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+func maskingText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,335 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMasking_apply(t *testing.T) {
+	keepLast := 2
+	m, err := newMasking(MaskingConfig{Rules: []MaskingRuleConfig{
+		{GroupType: "crm", Table: "users", Column: "email", Action: MaskingActionPartial, KeepFirst: 1, KeepLast: &keepLast,
+			ExceptRoles: []string{"admin"}},
+		{GroupType: "crm", Column: "password.*", Action: MaskingActionNull},
+		{GroupType: "crm", Table: "public.notes", Column: "text", Value: `\d{4}-\d{4}`, Action: MaskingActionMask},
+		{GroupType: "crm", Column: "phone", Action: MaskingActionHash, Roles: []string{"support"}},
+	}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		groupType string
+		role      string
+		maskAll   bool
+		query     string
+		column    string
+		value     any
+		want      any
+	}{
+		{
+			name:   "partial",
+			query:  "select email from users",
+			column: "email",
+			value:  "john@example.com",
+			want:   "j*************om",
+		},
+		{
+			name:   "partial of short value",
+			query:  "select email from users",
+			column: "email",
+			value:  "ab",
+			want:   "**",
+		},
+		{
+			name:   "except role",
+			role:   "admin",
+			query:  "select email from users",
+			column: "email",
+			value:  "john@example.com",
+			want:   "john@example.com",
+		},
+		{
+			name:   "alias",
+			query:  "select email as e from users",
+			column: "e",
+			value:  "john@example.com",
+			want:   "j*************om",
+		},
+		{
+			name:   "alias without as",
+			query:  "select lower(u.email) e from users u",
+			column: "e",
+			value:  "john@example.com",
+			want:   "j*************om",
+		},
+		{
+			name:   "not aliased expression",
+			query:  "select email || '' from users",
+			column: "?column?",
+			value:  "john@example.com",
+			want:   "j*************om",
+		},
+		{
+			name:   "alias of subquery",
+			query:  "select x.e from (select email as e from users) x",
+			column: "e",
+			value:  "john@example.com",
+			want:   "j*************om",
+		},
+		{
+			name:   "alias of union branch",
+			query:  "select name from users union all select email from users",
+			column: "name",
+			value:  "john@example.com",
+			want:   "j*************om",
+		},
+		{
+			name:   "column referenced in where",
+			query:  "select name from users where email = 'john@example.com'",
+			column: "name",
+			value:  "john@example.com",
+			want:   "john@example.com",
+		},
+		{
+			name:    "except role of mask all",
+			role:    "admin",
+			maskAll: true,
+			query:   "select email from users",
+			column:  "email",
+			value:   "john@example.com",
+			want:    "j*************om",
+		},
+		{
+			name:   "other table",
+			query:  "select email from accounts",
+			column: "EMAIL",
+			value:  "john@example.com",
+			want:   "john@example.com",
+		},
+		{
+			name:   "null",
+			role:   "admin",
+			query:  "select password_hash from accounts",
+			column: "PASSWORD_HASH",
+			value:  []byte("secret"),
+			want:   nil,
+		},
+		{
+			name:   "value pattern",
+			query:  "select text from public.notes",
+			column: "text",
+			value:  "card 1234-5678, pin 1234",
+			want:   "card ****, pin 1234",
+		},
+		{
+			name:   "value pattern of other schema",
+			query:  "select text from private.notes",
+			column: "text",
+			value:  "card 1234-5678",
+			want:   "card 1234-5678",
+		},
+		{
+			name:   "hash",
+			role:   "support",
+			query:  "select phone from users",
+			column: "phone",
+			value:  12345,
+			want:   "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5",
+		},
+		{
+			name:   "not masked role",
+			query:  "select phone from users",
+			column: "phone",
+			value:  12345,
+			want:   12345,
+		},
+		{
+			name:    "not masked role of mask all",
+			maskAll: true,
+			query:   "select phone from users",
+			column:  "phone",
+			value:   12345,
+			want:    "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5",
+		},
+		{
+			name:      "other group type",
+			groupType: "billing",
+			query:     "select password from users",
+			column:    "password",
+			value:     "secret",
+			want:      "secret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groupType := tt.groupType
+			if groupType == "" {
+				groupType = "crm"
+			}
+			data := &QueryData{
+				Columns: []Column{{Name: tt.column, FieldName: "f0"}},
+				Rows:    []map[string]any{{"f0": tt.value}},
+			}
+			m.apply(DatabaseGroup{GroupName: "a", GroupType: groupType}, "postgresql", tt.query,
+				QueryOptions{Role: tt.role, MaskAll: tt.maskAll}, []*QueryData{data})
+			assert.Equal(t, tt.want, data.Rows[0]["f0"])
+		})
+	}
+}
+
+func TestMasking_starWithAlias(t *testing.T) {
+	m, err := newMasking(MaskingConfig{Rules: []MaskingRuleConfig{{Column: "email", Action: MaskingActionMask}}})
+	require.NoError(t, err)
+	data := &QueryData{
+		Columns: []Column{{Name: "id", FieldName: "f0"}, {Name: "e", FieldName: "f1"}},
+		Rows:    []map[string]any{{"f0": 1, "f1": "john@example.com"}},
+	}
+	// position of e is unknown because of *, so all columns are masked
+	m.apply(DatabaseGroup{GroupName: "a", GroupType: "t"}, "postgresql", "select *, email as e from users",
+		QueryOptions{}, []*QueryData{data})
+	assert.Equal(t, map[string]any{"f0": maskedValue, "f1": maskedValue}, data.Rows[0])
+}
+
+func TestMasking_unmatchedColumns(t *testing.T) {
+	m, err := newMasking(MaskingConfig{Rules: []MaskingRuleConfig{
+		{Table: "users", Column: "email", Action: MaskingActionMask},
+	}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		query string
+		want  map[string]any
+	}{
+		{
+			name:  "whole row function argument",
+			query: "select id, row_to_json(u) from users u",
+			want:  map[string]any{"f0": maskedValue, "f1": maskedValue},
+		},
+		{
+			name:  "whole row value",
+			query: "select id, u from users u",
+			want:  map[string]any{"f0": maskedValue, "f1": maskedValue},
+		},
+		{
+			name:  "whole row of other table",
+			query: "select u.id, to_json(a) from users u join accounts a on a.user_id = u.id",
+			want:  map[string]any{"f0": 1, "f1": "john@example.com"},
+		},
+		{
+			name:  "star of subquery",
+			query: "select * from (select id, email as e from users) x",
+			want:  map[string]any{"f0": maskedValue, "f1": maskedValue},
+		},
+		{
+			name:  "qualified star of subquery",
+			query: "select x.* from (select id, email as e from users) x",
+			want:  map[string]any{"f0": maskedValue, "f1": maskedValue},
+		},
+		{
+			name:  "star of common table expression",
+			query: "with x as (select id, email as e from users) select * from x",
+			want:  map[string]any{"f0": maskedValue, "f1": maskedValue},
+		},
+		{
+			name:  "star of subquery without sensitive items",
+			query: "select * from (select id, name as e from users) x",
+			want:  map[string]any{"f0": 1, "f1": "john@example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &QueryData{
+				Columns: []Column{{Name: "id", FieldName: "f0"}, {Name: "e", FieldName: "f1"}},
+				Rows:    []map[string]any{{"f0": 1, "f1": "john@example.com"}},
+			}
+			m.apply(DatabaseGroup{GroupName: "a", GroupType: "t"}, "postgresql", tt.query, QueryOptions{},
+				[]*QueryData{data})
+			assert.Equal(t, tt.want, data.Rows[0])
+		})
+	}
+}
+
+func TestNewMasking_invalidRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    MaskingRuleConfig
+		wantErr string
+	}{
+		{
+			name:    "unknown action",
+			rule:    MaskingRuleConfig{Column: "x", Action: "unknown"},
+			wantErr: "invalid masking rule 1: unknown masking action: unknown",
+		},
+		{
+			name:    "invalid column pattern",
+			rule:    MaskingRuleConfig{Column: "(", Action: MaskingActionMask},
+			wantErr: "invalid masking rule 1: invalid column pattern: error parsing regexp: missing closing ): `(?i)^(?:()$`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newMasking(MaskingConfig{Rules: []MaskingRuleConfig{{Action: MaskingActionMask}, tt.rule}})
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Nil(t, m)
+		})
+	}
+}
+
+func TestMasking_hashKey(t *testing.T) {
+	m, err := newMasking(MaskingConfig{HashKey: "key", Rules: []MaskingRuleConfig{{Action: MaskingActionHash}}})
+	require.NoError(t, err)
+	data := &QueryData{Columns: []Column{{Name: "a", FieldName: "f0"}}, Rows: []map[string]any{{"f0": "12345"}}}
+	m.apply(DatabaseGroup{GroupName: "a", GroupType: "t"}, "postgresql", "select a from t", QueryOptions{},
+		[]*QueryData{data})
+	assert.Equal(t, "ab99a81f96d56f3b99596e3168b1ade13e02ab0aae08898b8aa4e3377c9e29d1", data.Rows[0]["f0"])
+}
+
+func TestDatabaseStore_QueryDatabase_masking(t *testing.T) {
+	s := newSqliteTestStore(t)
+	var err error
+	s.masking, err = newMasking(MaskingConfig{Rules: []MaskingRuleConfig{
+		{Table: "fruit", Column: "name", Action: MaskingActionMask, ExceptRoles: []string{"admin"}},
+	}})
+	require.NoError(t, err)
+
+	result := s.QueryDatabase(context.Background(), "a", "t", "select id, name from fruit order by id; select 1 as name",
+		QueryOptions{})
+	require.Nil(t, result.Error)
+	require.Len(t, result.Results, 2)
+	assert.Equal(t, maskedValue, result.Results[0].Rows[0][result.Results[0].Columns[1].FieldName])
+	assert.EqualValues(t, 1, result.Results[0].Rows[0][result.Results[0].Columns[0].FieldName])
+	// statement without fruit table is not masked
+	assert.EqualValues(t, 1, result.Results[1].Rows[0][result.Results[1].Columns[0].FieldName])
+
+	result = s.QueryDatabase(context.Background(), "a", "t", "select name from fruit order by id",
+		QueryOptions{Role: "admin"})
+	require.Nil(t, result.Error)
+	assert.Equal(t, "lemon", result.Data.Rows[0][result.Data.Columns[0].FieldName])
+
+	result = s.QueryDatabase(context.Background(), "b", "t", "update fruit set name = 'plum' where id = 1",
+		QueryOptions{})
+	require.Nil(t, result.Error)
+	diff := s.DiffDatabases(context.Background(), "t", []string{"a", "b"}, "select id, name from fruit", []string{"id"},
+		"")
+	require.Nil(t, diff.Error)
+	assert.Empty(t, diff.Diffs[0].Changed)
+	diff = s.DiffDatabases(context.Background(), "t", []string{"a", "b"}, "select id, name from fruit", []string{"id"},
+		"admin")
+	require.Nil(t, diff.Error)
+	require.Len(t, diff.Diffs[0].Changed, 1)
+	assert.Equal(t, "plum", diff.Diffs[0].Changed[0].Row[diff.Columns[1].FieldName])
+
+	plan, err := s.PlanWrite(context.Background(), "t", []string{"a"}, "update fruit set name = 'plum' where id = 1",
+		"select name as n from fruit where id = 1", "")
+	require.NoError(t, err)
+	assert.Equal(t, maskedValue, plan.Databases[0].Preview.Rows[0][plan.Databases[0].Preview.Columns[0].FieldName])
+
+	plan, err = s.PlanWrite(context.Background(), "t", []string{"a"}, "update fruit set name = 'plum' where id = 1",
+		"select name from fruit where id = 1", "admin")
+	require.NoError(t, err)
+	assert.Equal(t, "plum", plan.Databases[0].Preview.Rows[0][plan.Databases[0].Preview.Columns[0].FieldName])
+}
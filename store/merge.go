@@ -0,0 +1,168 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+const groupNameColumn = "groupName"
+
+// MergedQueryResult is a single table made of multiple databases results.
+//
+// Merge policy:
+//   - leading groupName column is added. Conflicting result column is renamed(see getFieldNames)
+//   - columns are matched by FieldName and ordered by first appearance. Column type is taken from first appearance
+//   - if group result does not have a column, its value is null and column is listed in MissingColumns
+//   - if column values have different types in different groups, all values of that column are converted to
+//     strings and column is listed in ConvertedColumns
+//   - failed groups are listed in Errors and do not add any rows
+type MergedQueryResult struct {
+	Data             *QueryData     `json:"data"`
+	Errors           []GroupError   `json:"errors"`
+	MissingColumns   []GroupColumns `json:"missingColumns"`
+	ConvertedColumns []string       `json:"convertedColumns"`
+}
+
+type GroupError struct {
+	GroupName string      `json:"groupName"`
+	Error     *QueryError `json:"error"`
+}
+
+type GroupColumns struct {
+	GroupName string   `json:"groupName"`
+	Columns   []string `json:"columns"`
+}
+
+func MergeGroupQueryResults(results []GroupQueryResult) MergedQueryResult {
+	merged := MergedQueryResult{
+		Data:             &QueryData{Columns: []Column{}, Rows: []map[string]any{}},
+		Errors:           []GroupError{},
+		MissingColumns:   []GroupColumns{},
+		ConvertedColumns: []string{},
+	}
+
+	// union of columns by source field name
+	var sourceColumns []Column
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if result.Error != nil {
+			merged.Errors = append(merged.Errors, GroupError{GroupName: result.GroupName, Error: result.Error})
+		}
+		if result.Data == nil {
+			continue
+		}
+		for _, column := range result.Data.Columns {
+			if !seen[column.FieldName] {
+				seen[column.FieldName] = true
+				sourceColumns = append(sourceColumns, column)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(sourceColumns)+1)
+	names = append(names, groupNameColumn)
+	for _, column := range sourceColumns {
+		names = append(names, column.FieldName)
+	}
+	fieldNames := getFieldNames(names)
+	merged.Data.Columns = append(merged.Data.Columns, Column{Name: groupNameColumn, FieldName: groupNameColumn})
+
+	converted := findConflictingColumns(results)
+	for i, column := range sourceColumns {
+		mergedColumn := Column{Name: column.Name, FieldName: fieldNames[i+1], Type: column.Type}
+		if converted[column.FieldName] {
+			mergedColumn.Type = nil
+			merged.ConvertedColumns = append(merged.ConvertedColumns, column.FieldName)
+		}
+		merged.Data.Columns = append(merged.Data.Columns, mergedColumn)
+	}
+
+	for _, result := range results {
+		if result.Data == nil {
+			continue
+		}
+
+		present := make(map[string]bool, len(result.Data.Columns))
+		for _, column := range result.Data.Columns {
+			present[column.FieldName] = true
+		}
+		var missing []string
+		for _, column := range sourceColumns {
+			if !present[column.FieldName] {
+				missing = append(missing, column.FieldName)
+			}
+		}
+		if len(missing) > 0 {
+			merged.MissingColumns = append(merged.MissingColumns, GroupColumns{GroupName: result.GroupName, Columns: missing})
+		}
+
+		for _, row := range result.Data.Rows {
+			mergedRow := make(map[string]any, len(sourceColumns)+1)
+			mergedRow[groupNameColumn] = result.GroupName
+			for i, column := range sourceColumns {
+				value := row[column.FieldName]
+				if converted[column.FieldName] && value != nil {
+					value = stringValue(value)
+				}
+				mergedRow[fieldNames[i+1]] = value
+			}
+			merged.Data.Rows = append(merged.Data.Rows, mergedRow)
+		}
+	}
+	return merged
+}
+
+// findConflictingColumns returns field names of columns, which have different value kinds in different groups.
+func findConflictingColumns(results []GroupQueryResult) map[string]bool {
+	kinds := make(map[string]string)
+	conflicting := make(map[string]bool)
+	for _, result := range results {
+		if result.Data == nil {
+			continue
+		}
+		for _, row := range result.Data.Rows {
+			for fieldName, value := range row {
+				kind := valueKind(value)
+				if kind == "" {
+					continue
+				}
+				if existing, ok := kinds[fieldName]; !ok {
+					kinds[fieldName] = kind
+				} else if existing != kind {
+					conflicting[fieldName] = true
+				}
+			}
+		}
+	}
+	return conflicting
+}
+
+func valueKind(value any) string {
+	switch value.(type) {
+	case nil:
+		return ""
+	case bool:
+		return "boolean"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return "number"
+	case string, []byte:
+		return "string"
+	case time.Time:
+		return "time"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func stringValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
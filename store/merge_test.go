@@ -0,0 +1,64 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeGroupQueryResults(t *testing.T) {
+	results := []GroupQueryResult{
+		{
+			GroupName: "lemon",
+			Data: &QueryData{
+				Columns: []Column{{Name: "id", FieldName: "id"}, {Name: "groupName", FieldName: "groupName"}},
+				Rows: []map[string]any{
+					{"id": int64(1), "groupName": "x"},
+				},
+			},
+		},
+		{
+			GroupName: "pear",
+			Data: &QueryData{
+				Columns: []Column{{Name: "id", FieldName: "id"}, {Name: "name", FieldName: "name"}},
+				Rows: []map[string]any{
+					{"id": "2", "name": "b"},
+					{"id": nil, "name": "c"},
+				},
+			},
+		},
+		{
+			GroupName: "apple",
+			Error:     NewQueryError(errors.New("connection refused")),
+		},
+	}
+
+	got := MergeGroupQueryResults(results)
+
+	assert.Equal(t, []Column{
+		{Name: "groupName", FieldName: "groupName"},
+		{Name: "id", FieldName: "id"},
+		{Name: "groupName", FieldName: "groupName__1"},
+		{Name: "name", FieldName: "name"},
+	}, got.Data.Columns)
+	assert.Equal(t, []map[string]any{
+		{"groupName": "lemon", "id": "1", "groupName__1": "x", "name": nil},
+		{"groupName": "pear", "id": "2", "groupName__1": nil, "name": "b"},
+		{"groupName": "pear", "id": nil, "groupName__1": nil, "name": "c"},
+	}, got.Data.Rows)
+	assert.Equal(t, []GroupError{{GroupName: "apple", Error: &QueryError{Message: "connection refused"}}}, got.Errors)
+	assert.Equal(t, []GroupColumns{
+		{GroupName: "lemon", Columns: []string{"name"}},
+		{GroupName: "pear", Columns: []string{"groupName"}},
+	}, got.MissingColumns)
+	assert.Equal(t, []string{"id"}, got.ConvertedColumns)
+}
+
+func TestMergeGroupQueryResults_Empty(t *testing.T) {
+	got := MergeGroupQueryResults(nil)
+
+	assert.Equal(t, []Column{{Name: "groupName", FieldName: "groupName"}}, got.Data.Columns)
+	assert.Empty(t, got.Data.Rows)
+	assert.Empty(t, got.Errors)
+}
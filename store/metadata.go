@@ -0,0 +1,464 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	TableTypeTable = "table"
+	TableTypeView  = "view"
+)
+
+// TablesMetadata is tables metadata grouped by schema.
+type TablesMetadata struct {
+	Schemas []SchemaMetadata `json:"schemas"`
+	// Cached is true if metadata is returned from cache(see MetadataCacheConfig)
+	Cached bool `json:"cached"`
+	// AgeInMilliseconds is time passed since cached metadata was fetched. It is 0 if metadata is not cached
+	AgeInMilliseconds int64 `json:"ageInMilliseconds"`
+}
+
+type SchemaMetadata struct {
+	// Name is empty for databases without schemas(firebird)
+	Name   string          `json:"name"`
+	Tables []TableMetadata `json:"tables"`
+}
+
+type TableMetadata struct {
+	// Schema is empty for databases without schemas(firebird)
+	Schema      string               `json:"schema"`
+	Name        string               `json:"name"`
+	Type        string               `json:"type"`
+	Columns     []ColumnMetadata     `json:"columns"`
+	PrimaryKey  []string             `json:"primaryKey"`
+	ForeignKeys []ForeignKeyMetadata `json:"foreignKeys"`
+	Indexes     []IndexMetadata      `json:"indexes"`
+	// RowCountEstimate is taken from database statistics and is nil if statistics are not available
+	RowCountEstimate *int64 `json:"rowCountEstimate"`
+}
+
+type ColumnMetadata struct {
+	Name     string  `json:"name"`
+	DataType string  `json:"dataType"`
+	Nullable bool    `json:"nullable"`
+	Default  *string `json:"default"`
+}
+
+type ForeignKeyMetadata struct {
+	Name              string   `json:"name"`
+	Columns           []string `json:"columns"`
+	ReferencedSchema  string   `json:"referencedSchema"`
+	ReferencedTable   string   `json:"referencedTable"`
+	ReferencedColumns []string `json:"referencedColumns"`
+}
+
+type IndexMetadata struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	Primary bool     `json:"primary"`
+}
+
+// metadataQueries are catalog queries of a single dialect. Every query returns schema and table name as first columns.
+// schemasToken is replaced by schemas placeholders or defaultSchema expression if schemas are not set.
+type metadataQueries struct {
+	// defaultSchema is an expression of connection default schema. Empty if dialect does not support schemas
+	defaultSchema string
+	// tables returns schema, table name, table type(TableTypeTable or TableTypeView), row count estimate
+	tables string
+	// columns returns schema, table name, column name, data type, nullable, default value. Ordered by position
+	columns string
+	// indexes returns schema, table name, index name, unique, primary, column name. Ordered by position
+	indexes string
+	// foreignKeys returns schema, table name, constraint name, column name, referenced schema, referenced table name,
+	// referenced column name. Ordered by position
+	foreignKeys string
+}
+
+const schemasToken = "{schemas}"
+
+var pgMetadataQueries = metadataQueries{
+	defaultSchema: "current_schema()",
+	tables: `
+SELECT
+    n.nspname, c.relname,
+    CASE WHEN c.relkind IN ('v', 'm') THEN 'view' ELSE 'table' END,
+    CASE WHEN c.relkind IN ('r', 'p') AND c.reltuples >= 0 THEN c.reltuples::bigint END
+FROM
+    pg_catalog.pg_class AS c
+    INNER JOIN pg_catalog.pg_namespace AS n ON n.oid = c.relnamespace
+WHERE
+    n.nspname IN ({schemas}) AND c.relkind IN ('r', 'p', 'v', 'm');
+`,
+	columns: `
+SELECT
+    n.nspname, c.relname, a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull,
+    pg_get_expr(d.adbin, d.adrelid)
+FROM
+    pg_catalog.pg_attribute AS a
+    INNER JOIN pg_catalog.pg_class AS c ON c.oid = a.attrelid
+    INNER JOIN pg_catalog.pg_namespace AS n ON n.oid = c.relnamespace
+    LEFT JOIN pg_catalog.pg_attrdef AS d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE
+    n.nspname IN ({schemas}) AND c.relkind IN ('r', 'p', 'v', 'm') AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY
+    n.nspname, c.relname, a.attnum;
+`,
+	indexes: `
+SELECT
+    n.nspname, t.relname, i.relname, ix.indisunique, ix.indisprimary,
+    COALESCE(a.attname, pg_get_indexdef(ix.indexrelid, k.ord::int, true))
+FROM
+    pg_catalog.pg_index AS ix
+    INNER JOIN pg_catalog.pg_class AS t ON t.oid = ix.indrelid
+    INNER JOIN pg_catalog.pg_class AS i ON i.oid = ix.indexrelid
+    INNER JOIN pg_catalog.pg_namespace AS n ON n.oid = t.relnamespace
+    CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord)
+    LEFT JOIN pg_catalog.pg_attribute AS a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE
+    n.nspname IN ({schemas})
+ORDER BY
+    n.nspname, t.relname, i.relname, k.ord;
+`,
+	foreignKeys: `
+SELECT
+    n.nspname, c.relname, con.conname, a.attname, rn.nspname, rc.relname, ra.attname
+FROM
+    pg_catalog.pg_constraint AS con
+    INNER JOIN pg_catalog.pg_class AS c ON c.oid = con.conrelid
+    INNER JOIN pg_catalog.pg_namespace AS n ON n.oid = c.relnamespace
+    INNER JOIN pg_catalog.pg_class AS rc ON rc.oid = con.confrelid
+    INNER JOIN pg_catalog.pg_namespace AS rn ON rn.oid = rc.relnamespace
+    CROSS JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refattnum, ord)
+    INNER JOIN pg_catalog.pg_attribute AS a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+    INNER JOIN pg_catalog.pg_attribute AS ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refattnum
+WHERE
+    con.contype = 'f' AND n.nspname IN ({schemas})
+ORDER BY
+    n.nspname, c.relname, con.conname, k.ord;
+`,
+}
+
+var mySqlMetadataQueries = metadataQueries{
+	defaultSchema: "database()",
+	tables: `
+SELECT
+    table_schema, table_name, CASE WHEN table_type = 'VIEW' THEN 'view' ELSE 'table' END, table_rows
+FROM
+    information_schema.tables
+WHERE
+    table_schema IN ({schemas});
+`,
+	columns: `
+SELECT
+    table_schema, table_name, column_name, column_type, is_nullable = 'YES', column_default
+FROM
+    information_schema.columns
+WHERE
+    table_schema IN ({schemas})
+ORDER BY
+    table_schema, table_name, ordinal_position;
+`,
+	indexes: `
+SELECT
+    table_schema, table_name, index_name, non_unique = 0, index_name = 'PRIMARY', column_name
+FROM
+    information_schema.statistics
+WHERE
+    table_schema IN ({schemas})
+ORDER BY
+    table_schema, table_name, index_name, seq_in_index;
+`,
+	foreignKeys: `
+SELECT
+    table_schema, table_name, constraint_name, column_name, referenced_table_schema, referenced_table_name,
+    referenced_column_name
+FROM
+    information_schema.key_column_usage
+WHERE
+    table_schema IN ({schemas}) AND referenced_table_name IS NOT NULL
+ORDER BY
+    table_schema, table_name, constraint_name, ordinal_position;
+`,
+}
+
+// fbMetadataQueries use unique index statistics(1 / selectivity) as row count estimate, because firebird does not
+// keep row count statistics.
+var fbMetadataQueries = metadataQueries{
+	tables: `
+SELECT
+    '', trim(r.rdb$relation_name),
+    CASE WHEN r.rdb$view_blr IS NULL THEN 'table' ELSE 'view' END,
+    (
+        SELECT CAST(1 / MIN(i.rdb$statistics) AS BIGINT)
+        FROM rdb$indices AS i
+        WHERE i.rdb$relation_name = r.rdb$relation_name AND i.rdb$unique_flag = 1 AND i.rdb$statistics > 0
+    )
+FROM
+    rdb$relations AS r
+WHERE
+    r.rdb$system_flag IS NULL OR r.rdb$system_flag = 0;
+`,
+	columns: `
+SELECT
+    '', trim(rf.rdb$relation_name), trim(rf.rdb$field_name),
+    CASE
+        WHEN f.rdb$field_type IN (7, 8, 16) AND f.rdb$field_sub_type = 1
+            THEN 'NUMERIC(' || f.rdb$field_precision || ',' || (-f.rdb$field_scale) || ')'
+        WHEN f.rdb$field_type IN (7, 8, 16) AND f.rdb$field_sub_type = 2
+            THEN 'DECIMAL(' || f.rdb$field_precision || ',' || (-f.rdb$field_scale) || ')'
+        WHEN f.rdb$field_type = 7 THEN 'SMALLINT'
+        WHEN f.rdb$field_type = 8 THEN 'INTEGER'
+        WHEN f.rdb$field_type = 10 THEN 'FLOAT'
+        WHEN f.rdb$field_type = 12 THEN 'DATE'
+        WHEN f.rdb$field_type = 13 THEN 'TIME'
+        WHEN f.rdb$field_type = 14 THEN 'CHAR(' || f.rdb$character_length || ')'
+        WHEN f.rdb$field_type = 16 THEN 'BIGINT'
+        WHEN f.rdb$field_type = 23 THEN 'BOOLEAN'
+        WHEN f.rdb$field_type = 27 THEN 'DOUBLE PRECISION'
+        WHEN f.rdb$field_type = 35 THEN 'TIMESTAMP'
+        WHEN f.rdb$field_type = 37 THEN 'VARCHAR(' || f.rdb$character_length || ')'
+        WHEN f.rdb$field_type = 261 THEN 'BLOB SUB_TYPE ' || f.rdb$field_sub_type
+        ELSE 'UNKNOWN(' || f.rdb$field_type || ')'
+    END,
+    CASE WHEN rf.rdb$null_flag IS NULL AND f.rdb$null_flag IS NULL THEN 1 ELSE 0 END,
+    COALESCE(rf.rdb$default_source, f.rdb$default_source)
+FROM
+    rdb$relation_fields AS rf
+    JOIN rdb$relations AS r ON
+            rf.rdb$relation_name = r.rdb$relation_name
+            AND (r.rdb$system_flag IS NULL OR r.rdb$system_flag = 0)
+    JOIN rdb$fields AS f ON f.rdb$field_name = rf.rdb$field_source
+ORDER BY
+    2, rf.rdb$field_position;
+`,
+	indexes: `
+SELECT
+    '', trim(i.rdb$relation_name), trim(i.rdb$index_name),
+    CASE WHEN i.rdb$unique_flag = 1 THEN 1 ELSE 0 END,
+    CASE WHEN rc.rdb$constraint_type = 'PRIMARY KEY' THEN 1 ELSE 0 END,
+    trim(s.rdb$field_name)
+FROM
+    rdb$indices AS i
+    LEFT JOIN rdb$index_segments AS s ON s.rdb$index_name = i.rdb$index_name
+    LEFT JOIN rdb$relation_constraints AS rc ON rc.rdb$index_name = i.rdb$index_name
+WHERE
+    i.rdb$system_flag IS NULL OR i.rdb$system_flag = 0
+ORDER BY
+    2, 3, s.rdb$field_position;
+`,
+	foreignKeys: `
+SELECT
+    '', trim(rc.rdb$relation_name), trim(rc.rdb$constraint_name), trim(s.rdb$field_name),
+    '', trim(ri.rdb$relation_name), trim(rs.rdb$field_name)
+FROM
+    rdb$relation_constraints AS rc
+    JOIN rdb$indices AS i ON i.rdb$index_name = rc.rdb$index_name
+    JOIN rdb$index_segments AS s ON s.rdb$index_name = i.rdb$index_name
+    JOIN rdb$indices AS ri ON ri.rdb$index_name = i.rdb$foreign_key
+    JOIN rdb$index_segments AS rs ON
+            rs.rdb$index_name = ri.rdb$index_name AND rs.rdb$field_position = s.rdb$field_position
+WHERE
+    rc.rdb$constraint_type = 'FOREIGN KEY'
+ORDER BY
+    2, 3, s.rdb$field_position;
+`,
+}
+
+func getMetadataQueries(sqlType string) (metadataQueries, error) {
+	switch sqlType {
+	case "postgresql":
+		return pgMetadataQueries, nil
+	case "firebird":
+		return fbMetadataQueries, nil
+	case "mysql":
+		return mySqlMetadataQueries, nil
+	default:
+		return metadataQueries{}, errors.Errorf("unknown type: %s", sqlType)
+	}
+}
+
+type tableKey struct {
+	schema string
+	name   string
+}
+
+// queryTablesMetadata executes catalog queries and returns tables of given schemas sorted by schema and name.
+// Default schema is used if schemas are not set.
+func queryTablesMetadata(ctx context.Context, db *sqlx.DB, sqlType string, schemas []string) (*TablesMetadata, error) {
+	queries, err := getMetadataQueries(sqlType)
+	if err != nil {
+		return nil, err
+	}
+	if queries.defaultSchema == "" && len(schemas) > 0 {
+		return nil, errors.Errorf("schemas are not supported by type: %s", sqlType)
+	}
+
+	schemasExpression := queries.defaultSchema
+	var args []any
+	if len(schemas) > 0 {
+		schemasExpression = strings.TrimSuffix(strings.Repeat("?, ", len(schemas)), ", ")
+		for _, schema := range schemas {
+			args = append(args, schema)
+		}
+	}
+	bind := func(query string) string {
+		return db.Rebind(strings.ReplaceAll(query, schemasToken, schemasExpression))
+	}
+	queries.tables = bind(queries.tables)
+	queries.columns = bind(queries.columns)
+	queries.indexes = bind(queries.indexes)
+	queries.foreignKeys = bind(queries.foreignKeys)
+
+	tables := make(map[tableKey]*TableMetadata)
+	getTable := func(key tableKey) *TableMetadata {
+		table, ok := tables[key]
+		if !ok {
+			table = &TableMetadata{
+				Schema:      key.schema,
+				Name:        key.name,
+				Type:        TableTypeTable,
+				Columns:     []ColumnMetadata{},
+				PrimaryKey:  []string{},
+				ForeignKeys: []ForeignKeyMetadata{},
+				Indexes:     []IndexMetadata{},
+			}
+			tables[key] = table
+		}
+		return table
+	}
+
+	err = queryMetadataRows(ctx, db, queries.tables, args, func(rows *sql.Rows) error {
+		var key tableKey
+		var tableType string
+		var rowCount sql.NullInt64
+		err := rows.Scan(&key.schema, &key.name, &tableType, &rowCount)
+		if err != nil {
+			return err
+		}
+		table := getTable(key)
+		table.Type = tableType
+		if rowCount.Valid {
+			table.RowCountEstimate = &rowCount.Int64
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tables")
+	}
+
+	err = queryMetadataRows(ctx, db, queries.columns, args, func(rows *sql.Rows) error {
+		var key tableKey
+		var column ColumnMetadata
+		var defaultValue sql.NullString
+		err := rows.Scan(&key.schema, &key.name, &column.Name, &column.DataType, &column.Nullable, &defaultValue)
+		if err != nil {
+			return err
+		}
+		if defaultValue.Valid {
+			value := strings.TrimSpace(defaultValue.String)
+			// firebird default source contains DEFAULT keyword
+			if len(value) >= 8 && strings.EqualFold(value[:8], "default ") {
+				value = strings.TrimSpace(value[8:])
+			}
+			column.Default = &value
+		}
+		table := getTable(key)
+		table.Columns = append(table.Columns, column)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query columns")
+	}
+
+	err = queryMetadataRows(ctx, db, queries.indexes, args, func(rows *sql.Rows) error {
+		var key tableKey
+		var index IndexMetadata
+		var columnName sql.NullString
+		err := rows.Scan(&key.schema, &key.name, &index.Name, &index.Unique, &index.Primary, &columnName)
+		if err != nil {
+			return err
+		}
+		table := getTable(key)
+		if n := len(table.Indexes); n == 0 || table.Indexes[n-1].Name != index.Name {
+			index.Columns = []string{}
+			table.Indexes = append(table.Indexes, index)
+		}
+		if columnName.Valid {
+			last := &table.Indexes[len(table.Indexes)-1]
+			last.Columns = append(last.Columns, columnName.String)
+			if last.Primary {
+				table.PrimaryKey = append(table.PrimaryKey, columnName.String)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query indexes")
+	}
+
+	err = queryMetadataRows(ctx, db, queries.foreignKeys, args, func(rows *sql.Rows) error {
+		var key tableKey
+		var foreignKey ForeignKeyMetadata
+		var columnName, referencedColumnName string
+		err := rows.Scan(&key.schema, &key.name, &foreignKey.Name, &columnName, &foreignKey.ReferencedSchema,
+			&foreignKey.ReferencedTable, &referencedColumnName)
+		if err != nil {
+			return err
+		}
+		table := getTable(key)
+		if n := len(table.ForeignKeys); n == 0 || table.ForeignKeys[n-1].Name != foreignKey.Name {
+			table.ForeignKeys = append(table.ForeignKeys, foreignKey)
+		}
+		last := &table.ForeignKeys[len(table.ForeignKeys)-1]
+		last.Columns = append(last.Columns, columnName)
+		last.ReferencedColumns = append(last.ReferencedColumns, referencedColumnName)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query foreign keys")
+	}
+
+	sorted := make([]*TableMetadata, 0, len(tables))
+	for _, table := range tables {
+		sorted = append(sorted, table)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Schema != sorted[j].Schema {
+			return sorted[i].Schema < sorted[j].Schema
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	metadata := &TablesMetadata{Schemas: []SchemaMetadata{}}
+	for _, table := range sorted {
+		if n := len(metadata.Schemas); n == 0 || metadata.Schemas[n-1].Name != table.Schema {
+			metadata.Schemas = append(metadata.Schemas, SchemaMetadata{Name: table.Schema, Tables: []TableMetadata{}})
+		}
+		last := &metadata.Schemas[len(metadata.Schemas)-1]
+		last.Tables = append(last.Tables, *table)
+	}
+	return metadata, nil
+}
+
+func queryMetadataRows(ctx context.Context, db *sqlx.DB, query string, args []any, scan func(rows *sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
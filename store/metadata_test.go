@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minlau/mdb-tool/internal/utils/closer"
+)
+
+const metadataPrepareSchema = `
+CREATE TABLE metadata_customers (
+    id integer NOT NULL,
+    email character varying(100),
+    CONSTRAINT metadata_customers_pk PRIMARY KEY (id)
+);
+CREATE TABLE metadata_orders (
+    id integer NOT NULL,
+    customer_id integer NOT NULL,
+    status text NOT NULL DEFAULT 'new',
+    CONSTRAINT metadata_orders_pk PRIMARY KEY (id),
+    CONSTRAINT metadata_orders_customer_fk FOREIGN KEY (customer_id) REFERENCES metadata_customers (id)
+);
+CREATE INDEX metadata_orders_status_idx ON metadata_orders (status, customer_id);
+CREATE VIEW metadata_orders_view AS SELECT id, status FROM metadata_orders;
+`
+const metadataClearSchema = `
+DROP VIEW IF EXISTS metadata_orders_view;
+DROP TABLE IF EXISTS metadata_orders;
+DROP TABLE IF EXISTS metadata_customers;
+`
+
+func findTableMetadata(metadata *TablesMetadata, name string) *TableMetadata {
+	for i := range metadata.Schemas {
+		for j := range metadata.Schemas[i].Tables {
+			if metadata.Schemas[i].Tables[j].Name == name {
+				return &metadata.Schemas[i].Tables[j]
+			}
+		}
+	}
+	return nil
+}
+
+func TestQueryTablesMetadata(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	config, err := readConfig("testdata/integration_config.json")
+	if err != nil {
+		t.Fatalf("failed to parse config file. Error: %v", err)
+	}
+	db, err := OpenDatabase(config.DatabaseConfigs[0].DatabaseConnConfig)
+	if err != nil {
+		t.Fatalf("failed to open database. Error: %v", err)
+	}
+	defer closer.Handle(db, "database")
+
+	_, err = db.Exec(metadataClearSchema + metadataPrepareSchema)
+	if err != nil {
+		t.Fatalf("failed to prepare schema. Error: %v", err)
+	}
+	defer db.Exec(metadataClearSchema) //nolint:errcheck
+
+	metadata, err := queryTablesMetadata(context.Background(), db, "postgresql", nil)
+	assert.NoError(t, err)
+
+	orders := findTableMetadata(metadata, "metadata_orders")
+	if assert.NotNil(t, orders) {
+		status := "'new'::text"
+		assert.Equal(t, TableTypeTable, orders.Type)
+		assert.Equal(t, []ColumnMetadata{
+			{Name: "id", DataType: "integer", Nullable: false},
+			{Name: "customer_id", DataType: "integer", Nullable: false},
+			{Name: "status", DataType: "text", Nullable: false, Default: &status},
+		}, orders.Columns)
+		assert.Equal(t, []string{"id"}, orders.PrimaryKey)
+		assert.Equal(t, []ForeignKeyMetadata{{
+			Name:              "metadata_orders_customer_fk",
+			Columns:           []string{"customer_id"},
+			ReferencedSchema:  orders.Schema,
+			ReferencedTable:   "metadata_customers",
+			ReferencedColumns: []string{"id"},
+		}}, orders.ForeignKeys)
+		assert.Equal(t, []IndexMetadata{
+			{Name: "metadata_orders_pk", Columns: []string{"id"}, Unique: true, Primary: true},
+			{Name: "metadata_orders_status_idx", Columns: []string{"status", "customer_id"}},
+		}, orders.Indexes)
+	}
+
+	view := findTableMetadata(metadata, "metadata_orders_view")
+	if assert.NotNil(t, view) {
+		assert.Equal(t, TableTypeView, view.Type)
+		assert.Len(t, view.Columns, 2)
+		assert.Nil(t, view.RowCountEstimate)
+	}
+
+	metadata, err = queryTablesMetadata(context.Background(), db, "postgresql", []string{"pg_catalog"})
+	assert.NoError(t, err)
+	assert.Len(t, metadata.Schemas, 1)
+	assert.Equal(t, "pg_catalog", metadata.Schemas[0].Name)
+	assert.Nil(t, findTableMetadata(metadata, "metadata_orders"))
+	assert.NotNil(t, findTableMetadata(metadata, "pg_class"))
+}
@@ -0,0 +1,508 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/minlau/mdb-tool/internal/utils/closer"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	MigrationDirectionUp   = "up"
+	MigrationDirectionDown = "down"
+
+	MigrationStatusApplied  = "applied"
+	MigrationStatusUpToDate = "upToDate"
+	MigrationStatusFailed   = "failed"
+	// MigrationStatusSkipped is a status of database, which was not migrated, because migration of other database failed
+	MigrationStatusSkipped = "skipped"
+)
+
+const migrationsTable = "schema_migrations"
+
+// migrationFileName matches <version>_<name>.up.sql and <version>_<name>.down.sql.
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type MigrationsConfig struct {
+	// Directory contains a directory of migration files per group type: <Directory>/<groupType>/<version>_<name>.up.sql
+	// and optional <version>_<name>.down.sql
+	Directory string
+	// Concurrency is a count of databases migrated at the same time. Default: 1
+	Concurrency int
+}
+
+type Migration struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	Down    string `json:"-"`
+	HasDown bool   `json:"hasDown"`
+}
+
+type AppliedMigration struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+// MigrationOptions are per request migration options.
+type MigrationOptions struct {
+	// GroupNames limits migrated databases. All databases of group type are migrated if not set
+	GroupNames []string
+	// Steps is a count of applied(up) or reverted(down) migrations. Default: all pending migrations for up, 1 for down
+	Steps int
+}
+
+type MigrationStatus struct {
+	GroupType  string                    `json:"groupType"`
+	Migrations []Migration               `json:"migrations"`
+	Databases  []DatabaseMigrationStatus `json:"databases"`
+}
+
+type DatabaseMigrationStatus struct {
+	GroupName string `json:"groupName"`
+	// CurrentVersion is the latest applied version, nil if no migrations are applied
+	CurrentVersion *int64             `json:"currentVersion"`
+	Applied        []AppliedMigration `json:"applied"`
+	Pending        []int64            `json:"pending"`
+	// Unknown are applied versions, which do not have migration files
+	Unknown []int64     `json:"unknown"`
+	Error   *QueryError `json:"error"`
+}
+
+type MigrationResult struct {
+	GroupType string                    `json:"groupType"`
+	Direction string                    `json:"direction"`
+	Databases []DatabaseMigrationResult `json:"databases"`
+}
+
+type DatabaseMigrationResult struct {
+	GroupName string `json:"groupName"`
+	Status    string `json:"status"`
+	// Versions are successfully applied or reverted versions in execution order
+	Versions []int64     `json:"versions"`
+	Error    *QueryError `json:"error"`
+}
+
+// Failed returns true if migration of any database failed or was skipped.
+func (r MigrationResult) Failed() bool {
+	for _, database := range r.Databases {
+		if database.Status == MigrationStatusFailed || database.Status == MigrationStatusSkipped {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadMigrations reads migrations of group type ordered by version.
+func LoadMigrations(directory string, groupType string) ([]Migration, error) {
+	if directory == "" {
+		return nil, errors.New("migrations directory is not configured")
+	}
+	groupDirectory := filepath.Join(directory, groupType)
+	entries, err := os.ReadDir(groupDirectory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read migrations directory. path=%s", groupDirectory)
+	}
+
+	migrations := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			log.Warn().Str("file", entry.Name()).Msg("skipping file with invalid migration name")
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid migration version. file=%s", entry.Name())
+		}
+		content, err := os.ReadFile(filepath.Join(groupDirectory, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read migration. file=%s", entry.Name())
+		}
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrations[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, errors.Errorf("migration version %d has multiple names: %s, %s", version, migration.Name,
+				match[2])
+		}
+		if match[3] == MigrationDirectionUp {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+			migration.HasDown = true
+		}
+	}
+
+	sorted := make([]Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		if migration.Up == "" {
+			return nil, errors.Errorf("migration %d_%s does not have up file", migration.Version, migration.Name)
+		}
+		sorted = append(sorted, *migration)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return sorted, nil
+}
+
+// GetMigrationStatus returns applied and pending migrations of every database of group type.
+func (s *DatabaseStore) GetMigrationStatus(ctx context.Context, groupType string, groupNames []string) (MigrationStatus,
+	error) {
+	migrations, err := LoadMigrations(s.migrations.Directory, groupType)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	instances, err := s.groupDatabaseInstances(groupType, groupNames)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	status := MigrationStatus{
+		GroupType:  groupType,
+		Migrations: migrations,
+		Databases:  make([]DatabaseMigrationStatus, len(instances)),
+	}
+	s.forEachMigrationDatabase(instances, func(i int) bool {
+		status.Databases[i] = s.databaseMigrationStatus(ctx, instances[i], migrations)
+		return true
+	})
+	return status, nil
+}
+
+func (s *DatabaseStore) databaseMigrationStatus(ctx context.Context, instance DatabaseInstance,
+	migrations []Migration) DatabaseMigrationStatus {
+	status := DatabaseMigrationStatus{
+		GroupName: instance.Config.GroupName,
+		Applied:   []AppliedMigration{},
+		Pending:   []int64{},
+		Unknown:   []int64{},
+	}
+	applied, err := readAppliedMigrations(ctx, instance.DB, instance.Config.Type)
+	if err != nil {
+		status.Error = NewQueryError(err)
+		return status
+	}
+	status.Applied = applied
+
+	appliedVersions := make(map[int64]bool, len(applied))
+	for _, migration := range applied {
+		appliedVersions[migration.Version] = true
+	}
+	known := make(map[int64]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		if !appliedVersions[migration.Version] {
+			status.Pending = append(status.Pending, migration.Version)
+		}
+	}
+	for _, migration := range applied {
+		if !known[migration.Version] {
+			status.Unknown = append(status.Unknown, migration.Version)
+		}
+	}
+	if len(applied) > 0 {
+		status.CurrentVersion = &applied[len(applied)-1].Version
+	}
+	return status
+}
+
+// Migrate applies pending migrations(up) or reverts applied migrations(down) in every database of group type. Every
+// migration is executed in a separate transaction together with schema_migrations update. Migration of database stops
+// on first failed migration, databases, which were not started yet, are skipped.
+//
+// Some databases(i.e. mysql) commit DDL implicitly, so failed migration can be applied partially.
+func (s *DatabaseStore) Migrate(ctx context.Context, groupType string, direction string,
+	options MigrationOptions) (MigrationResult, error) {
+	if direction != MigrationDirectionUp && direction != MigrationDirectionDown {
+		return MigrationResult{}, errors.Errorf("unknown migration direction: %s", direction)
+	}
+	if options.Steps < 0 {
+		return MigrationResult{}, errors.New("steps must not be negative")
+	}
+	migrations, err := LoadMigrations(s.migrations.Directory, groupType)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	instances, err := s.groupDatabaseInstances(groupType, options.GroupNames)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	lock := s.migrationLock(groupType)
+	lock.Lock()
+	defer lock.Unlock()
+
+	result := MigrationResult{
+		GroupType: groupType,
+		Direction: direction,
+		Databases: make([]DatabaseMigrationResult, len(instances)),
+	}
+	for i, instance := range instances {
+		result.Databases[i] = DatabaseMigrationResult{
+			GroupName: instance.Config.GroupName,
+			Status:    MigrationStatusSkipped,
+			Versions:  []int64{},
+		}
+	}
+	s.forEachMigrationDatabase(instances, func(i int) bool {
+		s.migrateDatabase(ctx, instances[i], migrations, direction, options.Steps, &result.Databases[i])
+		return result.Databases[i].Status != MigrationStatusFailed
+	})
+	return result, nil
+}
+
+// forEachMigrationDatabase calls fn for every database with MigrationsConfig.Concurrency. Databases, which were not
+// started yet, are not processed after fn returns false.
+func (s *DatabaseStore) forEachMigrationDatabase(instances []DatabaseInstance, fn func(i int) bool) {
+	concurrency := s.migrations.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var stopped atomic.Bool
+	var wg sync.WaitGroup
+	for i := range instances {
+		sem <- struct{}{}
+		if stopped.Load() {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if !fn(i) {
+				stopped.Store(true)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// migrationLock returns mutex of group type, which prevents concurrent migrations from applying the same migration
+// twice. Migrations are serialized only within this process.
+func (s *DatabaseStore) migrationLock(groupType string) *sync.Mutex {
+	s.m.Lock()
+	defer s.m.Unlock()
+	lock, ok := s.migrationLocks[groupType]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.migrationLocks[groupType] = lock
+	}
+	return lock
+}
+
+func (s *DatabaseStore) migrateDatabase(ctx context.Context, instance DatabaseInstance, migrations []Migration,
+	direction string, steps int, result *DatabaseMigrationResult) {
+	release, _, err := s.limiter.acquire(ctx, hostKey(instance.Config.DatabaseConnConfig))
+	if err != nil {
+		result.Status = MigrationStatusFailed
+		result.Error = NewQueryError(errors.Wrap(err, "failed to wait for free query slot"))
+		return
+	}
+	defer release()
+
+	pending, err := s.migrationsToRun(ctx, instance, migrations, direction, steps)
+	if err != nil {
+		result.Status = MigrationStatusFailed
+		result.Error = NewQueryError(err)
+		return
+	}
+	if len(pending) == 0 {
+		result.Status = MigrationStatusUpToDate
+		return
+	}
+
+	defer s.cache.invalidate(instance.Config.DatabaseGroup)
+	for _, migration := range pending {
+		err = runMigration(ctx, instance.DB, instance.Config.Type, migration, direction)
+		if err != nil {
+			result.Status = MigrationStatusFailed
+			result.Error = NewQueryError(errors.Wrapf(err, "migration %d_%s failed", migration.Version,
+				migration.Name))
+			return
+		}
+		result.Versions = append(result.Versions, migration.Version)
+	}
+	result.Status = MigrationStatusApplied
+}
+
+// migrationsToRun returns pending migrations in ascending order for up and applied migrations in descending order for
+// down, limited by steps.
+func (s *DatabaseStore) migrationsToRun(ctx context.Context, instance DatabaseInstance, migrations []Migration,
+	direction string, steps int) ([]Migration, error) {
+	err := createMigrationsTable(ctx, instance.DB, instance.Config.Type)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := readAppliedMigrations(ctx, instance.DB, instance.Config.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Migration
+	if direction == MigrationDirectionUp {
+		appliedVersions := make(map[int64]bool, len(applied))
+		for _, migration := range applied {
+			appliedVersions[migration.Version] = true
+		}
+		for _, migration := range migrations {
+			if !appliedVersions[migration.Version] {
+				result = append(result, migration)
+			}
+		}
+	} else {
+		if steps == 0 {
+			steps = 1
+		}
+		byVersion := make(map[int64]Migration, len(migrations))
+		for _, migration := range migrations {
+			byVersion[migration.Version] = migration
+		}
+		for i := len(applied) - 1; i >= 0; i-- {
+			migration, ok := byVersion[applied[i].Version]
+			if !ok {
+				return nil, errors.Errorf("migration %d does not have migration files", applied[i].Version)
+			}
+			if !migration.HasDown {
+				return nil, errors.Errorf("migration %d_%s does not have down file", migration.Version, migration.Name)
+			}
+			result = append(result, migration)
+			if len(result) == steps {
+				break
+			}
+		}
+	}
+	if steps > 0 && len(result) > steps {
+		result = result[:steps]
+	}
+	return result, nil
+}
+
+// runMigration executes migration and updates schema_migrations in a transaction.
+func runMigration(ctx context.Context, db *sqlx.DB, sqlType string, migration Migration, direction string) (err error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+				log.Error().Err(rollbackErr).Msg("failed to rollback transaction")
+			}
+		}
+	}()
+
+	query := migration.Up
+	if direction == MigrationDirectionDown {
+		query = migration.Down
+	}
+	// migration can consist of comments only(i.e. irreversible migration placeholder)
+	if len(splitStatements(query, sqlType)) > 0 {
+		_, err = executeStatements(ctx, tx, sqlType, query)
+		if err != nil {
+			return err
+		}
+	}
+
+	if direction == MigrationDirectionUp {
+		_, err = tx.ExecContext(ctx, tx.Rebind("INSERT INTO "+migrationsTable+" (version, name, applied_at) VALUES (?, ?, ?)"),
+			migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		_, err = tx.ExecContext(ctx, tx.Rebind("DELETE FROM "+migrationsTable+" WHERE version = ?"), migration.Version)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update "+migrationsTable)
+	}
+	return tx.Commit()
+}
+
+// migrationsTableExistsQueries are queries of schema_migrations table count in current database or schema.
+var migrationsTableExistsQueries = map[string]string{
+	"postgresql": `SELECT count(*) FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`,
+	"mysql": `SELECT count(*) FROM information_schema.tables
+		WHERE table_schema = database() AND table_name = 'schema_migrations'`,
+	"firebird": `SELECT count(*) FROM rdb$relations WHERE rdb$relation_name = 'SCHEMA_MIGRATIONS'`,
+	"sqlite":   `SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+}
+
+func migrationsTableExists(ctx context.Context, db *sqlx.DB, sqlType string) (bool, error) {
+	query, ok := migrationsTableExistsQueries[sqlType]
+	if !ok {
+		return false, errors.Errorf("migrations are not supported by database type: %s", sqlType)
+	}
+	var count int
+	err := db.GetContext(ctx, &count, query)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check "+migrationsTable+" table")
+	}
+	return count > 0, nil
+}
+
+func createMigrationsTable(ctx context.Context, db *sqlx.DB, sqlType string) error {
+	exists, err := migrationsTableExists(ctx, db, sqlType)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "CREATE TABLE "+migrationsTable+` (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return errors.Wrap(err, "failed to create "+migrationsTable+" table")
+	}
+	return nil
+}
+
+// readAppliedMigrations returns applied migrations ordered by version. Empty list is returned if schema_migrations
+// table does not exist.
+func readAppliedMigrations(ctx context.Context, db *sqlx.DB, sqlType string) ([]AppliedMigration, error) {
+	exists, err := migrationsTableExists(ctx, db, sqlType)
+	if err != nil || !exists {
+		return []AppliedMigration{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, name, applied_at FROM "+migrationsTable+" ORDER BY version")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read "+migrationsTable)
+	}
+	defer closer.Handle(rows, "rows")
+
+	applied := []AppliedMigration{}
+	for rows.Next() {
+		var migration AppliedMigration
+		var appliedAt any
+		err = rows.Scan(&migration.Version, &migration.Name, &appliedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read "+migrationsTable)
+		}
+		if b, ok := appliedAt.([]byte); ok {
+			appliedAt = string(b)
+		}
+		if t, ok := parseTimestamp(appliedAt); ok {
+			migration.AppliedAt = &t
+		}
+		applied = append(applied, migration)
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeMigrationFiles(t *testing.T, files map[string]string) string {
+	directory := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(directory, "t"), 0o755))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(directory, "t", name), []byte(content), 0o644))
+	}
+	return directory
+}
+
+func TestLoadMigrations(t *testing.T) {
+	directory := writeMigrationFiles(t, map[string]string{
+		"2_add_color.up.sql":       "alter table fruit add color text",
+		"1_create_basket.up.sql":   "create table basket (id integer)",
+		"1_create_basket.down.sql": "drop table basket",
+		"readme.md":                "not a migration",
+	})
+	migrations, err := LoadMigrations(directory, "t")
+	require.NoError(t, err)
+	assert.Equal(t, []Migration{
+		{Version: 1, Name: "create_basket", Up: "create table basket (id integer)", Down: "drop table basket", HasDown: true},
+		{Version: 2, Name: "add_color", Up: "alter table fruit add color text"},
+	}, migrations)
+
+	_, err = LoadMigrations("", "t")
+	assert.EqualError(t, err, "migrations directory is not configured")
+
+	directory = writeMigrationFiles(t, map[string]string{"1_a.down.sql": "select 1"})
+	_, err = LoadMigrations(directory, "t")
+	assert.EqualError(t, err, "migration 1_a does not have up file")
+
+	directory = writeMigrationFiles(t, map[string]string{"1_a.up.sql": "select 1", "1_b.up.sql": "select 1"})
+	_, err = LoadMigrations(directory, "t")
+	assert.ErrorContains(t, err, "migration version 1 has multiple names")
+}
+
+func TestDatabaseStore_Migrate(t *testing.T) {
+	ctx := context.Background()
+	s := newSqliteTestStore(t)
+	s.migrations.Directory = writeMigrationFiles(t, map[string]string{
+		"1_create_basket.up.sql":   "create table basket (id integer); insert into basket values (1)",
+		"1_create_basket.down.sql": "drop table basket",
+		"2_add_color.up.sql":       "alter table fruit add color text",
+		"2_add_color.down.sql":     "alter table fruit drop column color",
+	})
+
+	status, err := s.GetMigrationStatus(ctx, "t", nil)
+	require.NoError(t, err)
+	require.Len(t, status.Databases, 2)
+	assert.Nil(t, status.Databases[0].CurrentVersion)
+	assert.Equal(t, []int64{1, 2}, status.Databases[0].Pending)
+
+	result, err := s.Migrate(ctx, "t", MigrationDirectionUp, MigrationOptions{GroupNames: []string{"a"}, Steps: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []DatabaseMigrationResult{{GroupName: "a", Status: MigrationStatusApplied, Versions: []int64{1}}},
+		result.Databases)
+
+	result, err = s.Migrate(ctx, "t", MigrationDirectionUp, MigrationOptions{})
+	require.NoError(t, err)
+	assert.False(t, result.Failed())
+	assert.Equal(t, []int64{2}, result.Databases[0].Versions)
+	assert.Equal(t, []int64{1, 2}, result.Databases[1].Versions)
+
+	status, err = s.GetMigrationStatus(ctx, "t", nil)
+	require.NoError(t, err)
+	for _, database := range status.Databases {
+		assert.Nil(t, database.Error)
+		assert.Equal(t, int64(2), *database.CurrentVersion)
+		assert.Empty(t, database.Pending)
+		require.Len(t, database.Applied, 2)
+		assert.NotNil(t, database.Applied[0].AppliedAt)
+	}
+
+	result, err = s.Migrate(ctx, "t", MigrationDirectionUp, MigrationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, MigrationStatusUpToDate, result.Databases[0].Status)
+
+	result, err = s.Migrate(ctx, "t", MigrationDirectionDown, MigrationOptions{Steps: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, result.Databases[0].Versions)
+	assert.Equal(t, []int64{2, 1}, result.Databases[1].Versions)
+
+	status, err = s.GetMigrationStatus(ctx, "t", []string{"b"})
+	require.NoError(t, err)
+	assert.Empty(t, status.Databases[0].Applied)
+}
+
+func TestDatabaseStore_Migrate_StopOnFailure(t *testing.T) {
+	ctx := context.Background()
+	s := newSqliteTestStore(t)
+	s.migrations.Directory = writeMigrationFiles(t, map[string]string{
+		"1_create_basket.up.sql": "create table basket (id integer)",
+		"2_broken.up.sql":        "insert into missing values (1)",
+	})
+
+	result, err := s.Migrate(ctx, "t", MigrationDirectionUp, MigrationOptions{})
+	require.NoError(t, err)
+	assert.True(t, result.Failed())
+	assert.Equal(t, MigrationStatusFailed, result.Databases[0].Status)
+	assert.Equal(t, []int64{1}, result.Databases[0].Versions)
+	assert.Contains(t, result.Databases[0].Error.Message, "migration 2_broken failed")
+	assert.Equal(t, MigrationStatusSkipped, result.Databases[1].Status)
+
+	status, err := s.GetMigrationStatus(ctx, "t", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *status.Databases[0].CurrentVersion, "failed migration is rolled back")
+	assert.Nil(t, status.Databases[1].CurrentVersion)
+}
+
+func TestDatabaseStore_Migrate_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s := newSqliteTestStore(t)
+	s.migrations.Directory = writeMigrationFiles(t, map[string]string{
+		"1_create_basket.up.sql": "create table basket (id integer)",
+		"2_add_basket.up.sql":    "insert into basket values (1)",
+	})
+
+	var wg sync.WaitGroup
+	results := make([]MigrationResult, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			results[i], err = s.Migrate(ctx, "t", MigrationDirectionUp, MigrationOptions{})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	applied := 0
+	for _, result := range results {
+		assert.False(t, result.Failed())
+		for _, database := range result.Databases {
+			applied += len(database.Versions)
+		}
+	}
+	assert.Equal(t, 4, applied, "every migration is applied once in every database")
+}
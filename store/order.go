@@ -0,0 +1,76 @@
+package store
+
+import (
+	"sort"
+)
+
+const (
+	OrderByGroupName = "groupName"
+	OrderByConfig    = "config"
+	OrderByLabel     = "label"
+)
+
+// ResultOrder defines order of multiple databases results.
+type ResultOrder struct {
+	// By is a sort key: OrderByGroupName, OrderByConfig or OrderByLabel. Store default is used if empty
+	By string
+	// GroupNames are placed first in given order. Remaining results are sorted by By key
+	GroupNames []string
+}
+
+func IsValidOrderBy(orderBy string) bool {
+	switch orderBy {
+	case "", OrderByGroupName, OrderByConfig, OrderByLabel:
+		return true
+	default:
+		return false
+	}
+}
+
+// lessDatabaseInstance compares databases by given key. GroupName and config order are used to break ties, so that
+// order is always deterministic.
+func lessDatabaseInstance(a, b DatabaseInstance, orderBy string) bool {
+	switch orderBy {
+	case OrderByConfig:
+		if a.order != b.order {
+			return a.order < b.order
+		}
+	case OrderByLabel:
+		if a.Config.Label != b.Config.Label {
+			return a.Config.Label < b.Config.Label
+		}
+	}
+	if a.Config.GroupName != b.Config.GroupName {
+		return a.Config.GroupName < b.Config.GroupName
+	}
+	if a.Config.GroupType != b.Config.GroupType {
+		return a.Config.GroupType < b.Config.GroupType
+	}
+	return a.order < b.order
+}
+
+// sortDatabaseInstances sorts databases by explicit group names order first and by orderBy key after that.
+func sortDatabaseInstances(instances []DatabaseInstance, order ResultOrder) {
+	explicit := make(map[string]int, len(order.GroupNames))
+	for i, groupName := range order.GroupNames {
+		if _, ok := explicit[groupName]; !ok {
+			explicit[groupName] = i
+		}
+	}
+
+	sort.SliceStable(instances, func(i, j int) bool {
+		iPos, iOk := explicit[instances[i].Config.GroupName]
+		jPos, jOk := explicit[instances[j].Config.GroupName]
+		switch {
+		case iOk && jOk:
+			if iPos != jPos {
+				return iPos < jPos
+			}
+		case iOk:
+			return true
+		case jOk:
+			return false
+		}
+		return lessDatabaseInstance(instances[i], instances[j], order.By)
+	})
+}
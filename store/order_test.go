@@ -0,0 +1,65 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDatabaseInstance(groupName string, label string, order int) DatabaseInstance {
+	return DatabaseInstance{
+		Config: DatabaseConfig{
+			DatabaseGroup: DatabaseGroup{GroupName: groupName, GroupType: "test"},
+			Label:         label,
+		},
+		order: order,
+	}
+}
+
+func Test_sortDatabaseInstances(t *testing.T) {
+	instances := []DatabaseInstance{
+		newTestDatabaseInstance("pear", "b", 0),
+		newTestDatabaseInstance("apple", "c", 1),
+		newTestDatabaseInstance("lemon", "a", 2),
+		newTestDatabaseInstance("blueberry", "a", 3),
+	}
+	tests := []struct {
+		name  string
+		order ResultOrder
+		want  []string
+	}{
+		{
+			name:  "by group name",
+			order: ResultOrder{By: OrderByGroupName},
+			want:  []string{"apple", "blueberry", "lemon", "pear"},
+		},
+		{
+			name:  "by config",
+			order: ResultOrder{By: OrderByConfig},
+			want:  []string{"pear", "apple", "lemon", "blueberry"},
+		},
+		{
+			name:  "by label with group name ties",
+			order: ResultOrder{By: OrderByLabel},
+			want:  []string{"blueberry", "lemon", "pear", "apple"},
+		},
+		{
+			name:  "explicit group names first",
+			order: ResultOrder{By: OrderByGroupName, GroupNames: []string{"pear", "missing", "lemon"}},
+			want:  []string{"pear", "lemon", "apple", "blueberry"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := make([]DatabaseInstance, len(instances))
+			copy(sorted, instances)
+			sortDatabaseInstances(sorted, tt.order)
+
+			got := make([]string, 0, len(sorted))
+			for _, instance := range sorted {
+				got = append(got, instance.Config.GroupName)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Policy violation reasons.
+const (
+	PolicyReasonStatementNotAllowed     = "statementNotAllowed"
+	PolicyReasonTableNotAllowed         = "tableNotAllowed"
+	PolicyReasonTableDenied             = "tableDenied"
+	PolicyReasonColumnDenied            = "columnDenied"
+	PolicyReasonSelectStar              = "selectStar"
+	PolicyReasonDestructiveNotConfirmed = "destructiveNotConfirmed"
+	PolicyReasonDestructiveRejected     = "destructiveRejected"
+)
+
+// PolicyConfig restricts queries of databases. Statements are checked before execution. Table and column references
+// are found lexically, so checks are conservative: an unqualified column or * is treated as a reference of every
+// table of statement.
+type PolicyConfig struct {
+	// Name is used in violation errors
+	Name string
+	// GroupType of restricted databases. Required
+	GroupType string
+	// GroupNames limit restricted databases. All databases of group type are restricted if not set
+	GroupNames []string
+	// AllowedStatementKinds are statement kinds(see StatementKindSelect etc.), which can be executed. All if not set
+	AllowedStatementKinds []string
+	// AllowedTables are tables([schema.]table), which can be referenced. All if not set
+	AllowedTables []string
+	Deny          []PolicyDenyConfig
+	// NoSelectStarTables are tables, which columns can not be selected with *
+	NoSelectStarTables []string
+	// DestructiveStatements is DestructiveStatementsConfirm or DestructiveStatementsReject. Destructive statements(see
+	// DestructiveDdl etc.) are executed without confirmation if not set
+	DestructiveStatements string
+}
+
+// PolicyDenyConfig denies references of table or its columns. Denied columns can not be selected with * either.
+type PolicyDenyConfig struct {
+	// StatementKinds are denied statement kinds(see StatementKindSelect etc.). All if not set
+	StatementKinds []string
+	// Table is [schema.]table. Table without schema matches table of any schema
+	Table string
+	// Columns are denied columns. Whole table is denied if not set
+	Columns []string
+}
+
+// PolicyViolation is returned as QueryError.Err when query violates policy.
+type PolicyViolation struct {
+	Policy string `json:"policy"`
+	// Statement is a 1-based index of violating statement
+	Statement     int    `json:"statement"`
+	StatementKind string `json:"statementKind"`
+	// Reason is PolicyReasonStatementNotAllowed etc.
+	Reason string `json:"reason"`
+	Table  string `json:"table,omitempty"`
+	Column string `json:"column,omitempty"`
+	// Destructive is a destructive kind of statement(see DestructiveDdl etc.)
+	Destructive string `json:"destructive,omitempty"`
+}
+
+func (v *PolicyViolation) Error() string {
+	var detail string
+	switch v.Reason {
+	case PolicyReasonStatementNotAllowed:
+		detail = fmt.Sprintf("%s statements are not allowed", v.StatementKind)
+	case PolicyReasonTableNotAllowed:
+		detail = fmt.Sprintf("table %s is not allowed", v.Table)
+	case PolicyReasonTableDenied:
+		detail = fmt.Sprintf("%s of table %s is denied", v.StatementKind, v.Table)
+	case PolicyReasonColumnDenied:
+		if v.Column == "*" {
+			detail = fmt.Sprintf("select * of table %s with denied columns is denied", v.Table)
+			break
+		}
+		detail = fmt.Sprintf("%s of column %s.%s is denied", v.StatementKind, v.Table, v.Column)
+	case PolicyReasonSelectStar:
+		detail = fmt.Sprintf("select * of table %s is denied", v.Table)
+	case PolicyReasonDestructiveNotConfirmed:
+		detail = fmt.Sprintf("%s must be confirmed", destructiveDescription(v.Destructive))
+	case PolicyReasonDestructiveRejected:
+		detail = fmt.Sprintf("%s is rejected", destructiveDescription(v.Destructive))
+	default:
+		detail = v.Reason
+	}
+	return fmt.Sprintf("query violates policy %s: statement %d: %s", v.Policy, v.Statement, detail)
+}
+
+func destructiveDescription(destructive string) string {
+	switch destructive {
+	case DestructiveDdl:
+		return "DDL statement"
+	case DestructiveTruncate:
+		return "TRUNCATE statement"
+	case DestructiveDeleteWithoutWhere:
+		return "DELETE without WHERE"
+	case DestructiveUpdateWithoutWhere:
+		return "UPDATE without WHERE"
+	case DestructiveUnknown:
+		return "statement of unknown effect"
+	default:
+		return destructive
+	}
+}
+
+// tableName is a [schema.]table name of policy.
+type tableName struct {
+	schema string
+	name   string
+}
+
+func parseTableName(value string) tableName {
+	i := strings.LastIndexByte(value, '.')
+	if i == -1 {
+		return tableName{name: value}
+	}
+	return tableName{schema: value[:i], name: value[i+1:]}
+}
+
+func (n tableName) String() string {
+	if n.schema == "" {
+		return n.name
+	}
+	return n.schema + "." + n.name
+}
+
+// matches returns true if reference can be a table of n. Reference without schema matches table of any schema,
+// because default schema is not known.
+func (n tableName) matches(ref tableReference) bool {
+	return strings.EqualFold(n.name, ref.name) &&
+		(n.schema == "" || ref.schema == "" || strings.EqualFold(n.schema, ref.schema))
+}
+
+type policyDeny struct {
+	statementKinds []string
+	table          tableName
+	columns        []string
+}
+
+type policy struct {
+	name                  string
+	groupType             string
+	groupNames            []string
+	allowedStatementKinds []string
+	allowedTables         []tableName
+	deny                  []policyDeny
+	noSelectStarTables    []tableName
+	destructiveStatements string
+}
+
+// newPolicies returns policies of configs. Invalid policy is an error, skipping it would silently drop a restriction.
+func newPolicies(configs []PolicyConfig) ([]policy, error) {
+	var policies []policy
+	for i, config := range configs {
+		p, err := newPolicy(config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid policy %d(%s)", i, config.Name)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func newPolicy(config PolicyConfig) (policy, error) {
+	if config.GroupType == "" {
+		return policy{}, errors.New("groupType is required")
+	}
+	p := policy{
+		name:                  config.Name,
+		groupType:             config.GroupType,
+		groupNames:            config.GroupNames,
+		allowedStatementKinds: config.AllowedStatementKinds,
+		destructiveStatements: config.DestructiveStatements,
+	}
+	if p.name == "" {
+		p.name = config.GroupType
+	}
+	for _, kind := range config.AllowedStatementKinds {
+		if !isValidStatementKind(kind) {
+			return policy{}, errors.Errorf("unknown statement kind: %s", kind)
+		}
+	}
+	for _, table := range config.AllowedTables {
+		p.allowedTables = append(p.allowedTables, parseTableName(table))
+	}
+	for _, deny := range config.Deny {
+		if deny.Table == "" {
+			return policy{}, errors.New("deny table is required")
+		}
+		for _, kind := range deny.StatementKinds {
+			if !isValidStatementKind(kind) {
+				return policy{}, errors.Errorf("unknown statement kind: %s", kind)
+			}
+		}
+		p.deny = append(p.deny, policyDeny{
+			statementKinds: deny.StatementKinds,
+			table:          parseTableName(deny.Table),
+			columns:        deny.Columns,
+		})
+	}
+	for _, table := range config.NoSelectStarTables {
+		p.noSelectStarTables = append(p.noSelectStarTables, parseTableName(table))
+	}
+	switch config.DestructiveStatements {
+	case "", DestructiveStatementsConfirm, DestructiveStatementsReject:
+	default:
+		return policy{}, errors.Errorf("unknown destructiveStatements: %s", config.DestructiveStatements)
+	}
+	return p, nil
+}
+
+func isValidStatementKind(kind string) bool {
+	switch kind {
+	case StatementKindSelect, StatementKindInsert, StatementKindUpdate, StatementKindDelete, StatementKindMerge,
+		StatementKindDdl, StatementKindOther:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p policy) appliesTo(group DatabaseGroup) bool {
+	return p.groupType == group.GroupType && (len(p.groupNames) == 0 || contains(p.groupNames, group.GroupName))
+}
+
+// checkPolicies checks every statement of query against policies of database and returns the first violation.
+// confirmed allows destructive statements of DestructiveStatementsConfirm policies.
+func checkPolicies(policies []policy, group DatabaseGroup, sqlType string, query string, confirmed bool) *PolicyViolation {
+	var applied []policy
+	for _, p := range policies {
+		if p.appliesTo(group) {
+			applied = append(applied, p)
+		}
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+	for i, s := range splitStatements(query, sqlType) {
+		kind := s.kind()
+		destructive := s.destructive()
+		refs := s.references()
+		for _, p := range applied {
+			violation := p.check(kind, destructive, refs, confirmed)
+			if violation != nil {
+				violation.Policy = p.name
+				violation.Statement = i + 1
+				violation.StatementKind = kind
+				return violation
+			}
+		}
+	}
+	return nil
+}
+
+func (p policy) check(kind string, destructive string, refs statementReferences, confirmed bool) *PolicyViolation {
+	if len(p.allowedStatementKinds) > 0 && !contains(p.allowedStatementKinds, kind) {
+		return &PolicyViolation{Reason: PolicyReasonStatementNotAllowed}
+	}
+	if destructive != "" {
+		switch {
+		case p.destructiveStatements == DestructiveStatementsReject:
+			return &PolicyViolation{Reason: PolicyReasonDestructiveRejected, Destructive: destructive}
+		case p.destructiveStatements == DestructiveStatementsConfirm && !confirmed:
+			return &PolicyViolation{Reason: PolicyReasonDestructiveNotConfirmed, Destructive: destructive}
+		}
+	}
+	if len(p.allowedTables) > 0 {
+		for _, ref := range refs.tables {
+			if !anyTableMatches(p.allowedTables, ref) {
+				return &PolicyViolation{Reason: PolicyReasonTableNotAllowed, Table: formatTableReference(ref)}
+			}
+		}
+	}
+	for _, deny := range p.deny {
+		if len(deny.statementKinds) > 0 && !contains(deny.statementKinds, kind) {
+			continue
+		}
+		for _, ref := range refs.tables {
+			if !deny.table.matches(ref) {
+				continue
+			}
+			if len(deny.columns) == 0 {
+				return &PolicyViolation{Reason: PolicyReasonTableDenied, Table: deny.table.String()}
+			}
+			column, ok := referencedColumn(refs, ref, deny.columns)
+			if ok {
+				return &PolicyViolation{Reason: PolicyReasonColumnDenied, Table: deny.table.String(), Column: column}
+			}
+		}
+	}
+	for _, table := range p.noSelectStarTables {
+		for _, ref := range refs.tables {
+			if !table.matches(ref) {
+				continue
+			}
+			if _, ok := referencedColumn(refs, ref, []string{"*"}); ok {
+				return &PolicyViolation{Reason: PolicyReasonSelectStar, Table: table.String()}
+			}
+		}
+	}
+	return nil
+}
+
+// referencedColumn returns first of columns, which statement references in table. Unqualified columns are expected to
+// be columns of every table of statement. * references all columns.
+func referencedColumn(refs statementReferences, table tableReference, columns []string) (string, bool) {
+	for _, column := range refs.columns {
+		if column.qualifier != "" && !strings.EqualFold(column.qualifier, table.name) &&
+			!strings.EqualFold(column.qualifier, table.alias) {
+			continue
+		}
+		if column.name == "*" {
+			return "*", true
+		}
+		for _, name := range columns {
+			if strings.EqualFold(column.name, name) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func anyTableMatches(tables []tableName, ref tableReference) bool {
+	for _, table := range tables {
+		if table.matches(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatTableReference(ref tableReference) string {
+	return tableName{schema: ref.schema, name: ref.name}.String()
+}
@@ -0,0 +1,365 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPolicies(t *testing.T) {
+	policies, err := newPolicies([]PolicyConfig{
+		{
+			Name:      "pii",
+			GroupType: "crm",
+			Deny: []PolicyDenyConfig{
+				{StatementKinds: []string{StatementKindSelect}, Table: "users", Columns: []string{"password_hash"}},
+				{Table: "audit.secrets"},
+			},
+			NoSelectStarTables: []string{"customers"},
+		},
+		{
+			Name:                  "read-only",
+			GroupType:             "crm",
+			GroupNames:            []string{"prod"},
+			AllowedStatementKinds: []string{StatementKindSelect},
+			AllowedTables:         []string{"users", "customers", "public.orders"},
+		},
+		{Name: "confirm", GroupType: "crm", GroupNames: []string{"staging"}, DestructiveStatements: DestructiveStatementsConfirm},
+		{Name: "reject", GroupType: "crm", GroupNames: []string{"live"}, DestructiveStatements: DestructiveStatementsReject},
+	})
+	require.NoError(t, err)
+	require.Len(t, policies, 4)
+
+	tests := []struct {
+		name      string
+		groupName string
+		groupType string
+		query     string
+		confirmed bool
+		want      *PolicyViolation
+	}{
+		{
+			name:  "allowed columns",
+			query: "select id, email from users where id = 1",
+		},
+		{
+			name:  "denied column",
+			query: "select id, password_hash from users",
+			want: &PolicyViolation{Policy: "pii", Statement: 1, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonColumnDenied, Table: "users", Column: "password_hash"},
+		},
+		{
+			name:  "denied qualified column",
+			query: "select u.PASSWORD_HASH from public.users u",
+			want: &PolicyViolation{Policy: "pii", Statement: 1, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonColumnDenied, Table: "users", Column: "password_hash"},
+		},
+		{
+			name:  "column of other table",
+			query: "select a.password_hash from users u join accounts a on a.user_id = u.id",
+		},
+		{
+			name:  "column name in string",
+			query: "select 'password_hash' from users",
+		},
+		{
+			name:  "star of table with denied columns",
+			query: "select 1; select u.* from users u",
+			want: &PolicyViolation{Policy: "pii", Statement: 2, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonColumnDenied, Table: "users", Column: "*"},
+		},
+		{
+			name:  "whole row of alias",
+			query: "select to_json(u) from users u",
+			want: &PolicyViolation{Policy: "pii", Statement: 1, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonColumnDenied, Table: "users", Column: "*"},
+		},
+		{
+			name:  "whole row of table",
+			query: "select users from users",
+			want: &PolicyViolation{Policy: "pii", Statement: 1, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonColumnDenied, Table: "users", Column: "*"},
+		},
+		{
+			name:  "table statement",
+			query: "TABLE users",
+			want: &PolicyViolation{Policy: "pii", Statement: 1, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonColumnDenied, Table: "users", Column: "*"},
+		},
+		{
+			name:  "table statement of select star table",
+			query: "select id from users union table customers",
+			want: &PolicyViolation{Policy: "pii", Statement: 1, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonSelectStar, Table: "customers"},
+		},
+		{
+			name:  "denied column of not denied statement kind",
+			query: "update users set password_hash = null where id = 1",
+		},
+		{
+			name:  "denied table",
+			query: "delete from audit.secrets",
+			want: &PolicyViolation{Policy: "pii", Statement: 1, StatementKind: StatementKindDelete,
+				Reason: PolicyReasonTableDenied, Table: "audit.secrets"},
+		},
+		{
+			name:  "table of other schema",
+			query: "select * from public.secrets",
+		},
+		{
+			name:  "select star",
+			query: "select * from customers",
+			want: &PolicyViolation{Policy: "pii", Statement: 1, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonSelectStar, Table: "customers"},
+		},
+		{
+			name:  "count star",
+			query: "select count(*) from customers",
+		},
+		{
+			name:      "statement not allowed",
+			groupName: "prod",
+			query:     "update customers set name = 'x'",
+			want: &PolicyViolation{Policy: "read-only", Statement: 1, StatementKind: StatementKindUpdate,
+				Reason: PolicyReasonStatementNotAllowed},
+		},
+		{
+			name:      "table not allowed",
+			groupName: "prod",
+			query:     "select id from customers c join sales.orders o on o.customer_id = c.id",
+			want: &PolicyViolation{Policy: "read-only", Statement: 1, StatementKind: StatementKindSelect,
+				Reason: PolicyReasonTableNotAllowed, Table: "sales.orders"},
+		},
+		{
+			name:      "allowed tables",
+			groupName: "prod",
+			query:     "with o as (select customer_id from orders) select id from customers where id in (select customer_id from o)",
+		},
+		{
+			name:      "destructive not confirmed",
+			groupName: "staging",
+			query:     "select 1; delete from customers",
+			want: &PolicyViolation{Policy: "confirm", Statement: 2, StatementKind: StatementKindDelete,
+				Reason: PolicyReasonDestructiveNotConfirmed, Destructive: DestructiveDeleteWithoutWhere},
+		},
+		{
+			name:      "destructive confirmed",
+			groupName: "staging",
+			query:     "drop table customers",
+			confirmed: true,
+		},
+		{
+			name:      "not destructive",
+			groupName: "staging",
+			query:     "delete from customers where id = 1",
+		},
+		{
+			name:      "destructive rejected",
+			groupName: "live",
+			query:     "truncate table customers",
+			confirmed: true,
+			want: &PolicyViolation{Policy: "reject", Statement: 1, StatementKind: StatementKindDdl,
+				Reason: PolicyReasonDestructiveRejected, Destructive: DestructiveTruncate},
+		},
+		{
+			name:      "unknown effect not confirmed",
+			groupName: "staging",
+			query:     "do $$ begin drop table customers; end $$",
+			want: &PolicyViolation{Policy: "confirm", Statement: 1, StatementKind: StatementKindOther,
+				Reason: PolicyReasonDestructiveNotConfirmed, Destructive: DestructiveUnknown},
+		},
+		{
+			name:      "unknown effect rejected",
+			groupName: "live",
+			query:     "call purge_customers()",
+			confirmed: true,
+			want: &PolicyViolation{Policy: "reject", Statement: 1, StatementKind: StatementKindOther,
+				Reason: PolicyReasonDestructiveRejected, Destructive: DestructiveUnknown},
+		},
+		{
+			name:      "other group type",
+			groupType: "billing",
+			query:     "select password_hash from users",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := DatabaseGroup{GroupName: tt.groupName, GroupType: tt.groupType}
+			if group.GroupName == "" {
+				group.GroupName = "test"
+			}
+			if group.GroupType == "" {
+				group.GroupType = "crm"
+			}
+			assert.Equal(t, tt.want, checkPolicies(policies, group, "postgresql", tt.query, tt.confirmed))
+		})
+	}
+}
+
+func TestCheckPolicies_hiddenStatements(t *testing.T) {
+	policies := mustNewPolicies(t, []PolicyConfig{
+		{Name: "reject", GroupType: "crm", DestructiveStatements: DestructiveStatementsReject},
+	})
+	tests := []struct {
+		name      string
+		sqlType   string
+		query     string
+		statement int
+		want      string
+	}{
+		{
+			name:      "postgresql escape string",
+			sqlType:   "postgresql",
+			query:     `select E'\'' ; delete from users; --'`,
+			statement: 2,
+			want:      DestructiveDeleteWithoutWhere,
+		},
+		{
+			name:      "mysql executable comment",
+			sqlType:   "mysql",
+			query:     "/*!50000 DROP TABLE users */",
+			statement: 1,
+			want:      DestructiveDdl,
+		},
+		{
+			name:      "mysql executable comment with semicolons",
+			sqlType:   "mysql",
+			query:     "select /*!1; drop table users; select */ 1",
+			statement: 2,
+			want:      DestructiveDdl,
+		},
+		{
+			name:      "begin identifier",
+			sqlType:   "postgresql",
+			query:     "select begin from t; delete from users",
+			statement: 2,
+			want:      DestructiveDeleteWithoutWhere,
+		},
+		{
+			name:      "firebird execute block",
+			sqlType:   "firebird",
+			query:     "select 1 from rdb$database; execute block as begin delete from users; end",
+			statement: 2,
+			want:      DestructiveUnknown,
+		},
+		{
+			name:      "mysql prepared statement",
+			sqlType:   "mysql",
+			query:     "set @q = 'drop table users'; prepare s from @q; execute s",
+			statement: 2,
+			want:      DestructiveUnknown,
+		},
+		{
+			name:      "postgresql do",
+			sqlType:   "postgresql",
+			query:     "do $$ begin delete from users; end $$",
+			statement: 1,
+			want:      DestructiveUnknown,
+		},
+		{
+			name:      "mysql call",
+			sqlType:   "mysql",
+			query:     "call purge_users()",
+			statement: 1,
+			want:      DestructiveUnknown,
+		},
+		{
+			name:      "mysql optimizer hint",
+			sqlType:   "mysql",
+			query:     "select /*+ a */ 1; /*+ truncate users */",
+			statement: 2,
+			want:      DestructiveTruncate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violation := checkPolicies(policies, DatabaseGroup{GroupName: "a", GroupType: "crm"}, tt.sqlType, tt.query,
+				true)
+			require.NotNil(t, violation)
+			assert.Equal(t, PolicyReasonDestructiveRejected, violation.Reason)
+			assert.Equal(t, tt.statement, violation.Statement)
+			assert.Equal(t, tt.want, violation.Destructive)
+		})
+	}
+}
+
+func TestNewPolicies_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  PolicyConfig
+		wantErr string
+	}{
+		{
+			name:    "no group type",
+			config:  PolicyConfig{Name: "a", Deny: []PolicyDenyConfig{{Table: "users"}}},
+			wantErr: "invalid policy 1(a): groupType is required",
+		},
+		{
+			name:    "unknown destructive statements",
+			config:  PolicyConfig{Name: "a", GroupType: "crm", DestructiveStatements: "ask"},
+			wantErr: "invalid policy 1(a): unknown destructiveStatements: ask",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := newPolicies([]PolicyConfig{{Name: "valid", GroupType: "crm"}, tt.config})
+			require.Error(t, err)
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Nil(t, policies)
+		})
+	}
+}
+
+func mustNewPolicies(t *testing.T, configs []PolicyConfig) []policy {
+	policies, err := newPolicies(configs)
+	require.NoError(t, err)
+	return policies
+}
+
+func TestDatabaseStore_QueryDatabase_policyViolation(t *testing.T) {
+	s := newSqliteTestStore(t)
+	s.policies = mustNewPolicies(t, []PolicyConfig{{
+		Name:      "fruit",
+		GroupType: "t",
+		Deny:      []PolicyDenyConfig{{Table: "fruit", Columns: []string{"name"}}},
+	}})
+
+	result := s.QueryDatabase(context.Background(), "a", "t", "select id, name from fruit", QueryOptions{})
+	require.NotNil(t, result.Error)
+	assert.Equal(t, QueryErrorCodePolicyViolation, result.Error.Code)
+	assert.Equal(t, "query violates policy fruit: statement 1: select of column fruit.name is denied",
+		result.Error.Message)
+	var violation *PolicyViolation
+	require.True(t, errors.As(result.Error.Err, &violation))
+	assert.Equal(t, PolicyReasonColumnDenied, violation.Reason)
+	assert.Nil(t, result.Data)
+
+	result = s.QueryDatabase(context.Background(), "a", "t", "select id from fruit", QueryOptions{})
+	require.Nil(t, result.Error)
+	assert.Len(t, result.Data.Rows, 3)
+
+	s.policies = mustNewPolicies(t, []PolicyConfig{{Name: "t", GroupType: "t", DestructiveStatements: DestructiveStatementsConfirm}})
+	result = s.QueryDatabase(context.Background(), "a", "t", "update fruit set name = 'x'", QueryOptions{})
+	require.NotNil(t, result.Error)
+	assert.Equal(t, "query violates policy t: statement 1: UPDATE without WHERE must be confirmed", result.Error.Message)
+	assert.Equal(t, 0, countFruits(t, s, "a", "x"))
+
+	result = s.QueryDatabase(context.Background(), "a", "t", "update fruit set name = 'x'", QueryOptions{Confirm: true})
+	require.Nil(t, result.Error)
+	assert.Equal(t, 3, countFruits(t, s, "a", "x"))
+
+	s.policies = mustNewPolicies(t, []PolicyConfig{{
+		Name:      "fruit",
+		GroupType: "t",
+		Deny:      []PolicyDenyConfig{{Table: "fruit", Columns: []string{"name"}}},
+	}})
+	plan, err := s.PlanWrite(context.Background(), "t", nil, "update fruit set id = id + 1",
+		"select name from fruit", "")
+	require.NoError(t, err)
+	assert.Empty(t, plan.Token)
+	require.NotNil(t, plan.Databases[0].Error)
+	assert.Equal(t, QueryErrorCodePolicyViolation, plan.Databases[0].Error.Code)
+}
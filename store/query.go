@@ -1,18 +1,36 @@
 package store
 
 import (
+	"github.com/pkg/errors"
 	"github.com/segmentio/encoding/json"
 )
 
 type GroupQueryResult struct {
-	GroupName string      `json:"groupName"`
-	Data      *QueryData  `json:"data"`
-	Error     *QueryError `json:"error"`
+	GroupName string `json:"groupName"`
+	// Data is a primary result of query: last result with columns(i.e. last SELECT) or last result
+	Data *QueryData `json:"data"`
+	// Results are results of every query statement, every result set of statement is a separate result
+	Results []*QueryData `json:"results"`
+	Error   *QueryError  `json:"error"`
+	// StatementKind is a kind of Data statement(see StatementKindSelect etc.)
+	StatementKind string `json:"statementKind"`
+	// RowsAffected is a sum of rows affected by all statements. It is nil if no statement reported it
+	RowsAffected *int64 `json:"rowsAffected"`
+	// WaitTimeInMilliseconds is time spent waiting for a free query slot. It is zero if execution was not queued
+	WaitTimeInMilliseconds int64 `json:"waitTimeInMilliseconds"`
+	// ExecutionTimeInMilliseconds is time spent executing query without waiting for a free query slot
+	ExecutionTimeInMilliseconds int64 `json:"executionTimeInMilliseconds"`
 }
 
 type QueryData struct {
 	Columns []Column         `json:"columns"`
 	Rows    []map[string]any `json:"rows"`
+	// StatementIndex is an index of query statement, which returned this result
+	StatementIndex int `json:"statementIndex"`
+	// StatementKind is a kind of query statement, which returned this result(see StatementKindSelect etc.)
+	StatementKind string `json:"statementKind"`
+	// RowsAffected is a count of rows affected by data modification statement. It is nil for row returning statements
+	RowsAffected *int64 `json:"rowsAffected"`
 }
 
 // Column is used to store original column name(Name) and custom name(FieldName) for json response.
@@ -20,17 +38,28 @@ type QueryData struct {
 type Column struct {
 	Name      string `json:"name"`
 	FieldName string `json:"fieldName"`
+	// Type is nil if column type is unknown(i.e. merged columns with different types)
+	Type *ColumnType `json:"type"`
 }
 
+// QueryErrorCodePolicyViolation is a code of error, which Err is *PolicyViolation.
+const QueryErrorCodePolicyViolation = "policyViolation"
+
 type QueryError struct {
 	Message string `json:"message"`
-	Err     error  `json:"err"`
+	// Code is set for errors, which Err has known structure(see QueryErrorCodePolicyViolation)
+	Code string `json:"code,omitempty"`
+	Err  error  `json:"err"`
 }
 
 func NewQueryError(err error) *QueryError {
 	if err == nil {
 		return nil
 	}
+	var violation *PolicyViolation
+	if errors.As(err, &violation) {
+		return &QueryError{Message: err.Error(), Code: QueryErrorCodePolicyViolation, Err: violation}
+	}
 	if errJson, _ := json.Marshal(err); string(errJson) == "{}" {
 		return &QueryError{Message: err.Error(), Err: nil}
 	}
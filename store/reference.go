@@ -0,0 +1,439 @@
+package store
+
+import (
+	"strings"
+)
+
+// tableReference is a table referenced by statement, i.e. FROM public.users u.
+type tableReference struct {
+	schema string
+	name   string
+	alias  string
+}
+
+// columnReference is an identifier, which might be a column. qualifier is a table name or alias before the column
+// name(empty if column is not qualified). Star is a column reference with name "*".
+type columnReference struct {
+	qualifier string
+	name      string
+	// wholeRow is true for table name or alias used as a value, i.e. u of SELECT to_json(u) FROM users u
+	wholeRow bool
+}
+
+// statementReferences are tables and column-like identifiers of statement.
+type statementReferences struct {
+	tables  []tableReference
+	columns []columnReference
+}
+
+// notAliasKeywords are keywords, which can follow table name and are not table aliases.
+var notAliasKeywords = map[string]bool{
+	"WHERE": true, "JOIN": true, "LEFT": true, "RIGHT": true, "INNER": true, "OUTER": true, "FULL": true,
+	"CROSS": true, "NATURAL": true, "STRAIGHT_JOIN": true, "ON": true, "USING": true, "SET": true, "GROUP": true,
+	"ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true, "HAVING": true, "WINDOW": true, "UNION": true,
+	"EXCEPT": true, "INTERSECT": true, "MINUS": true, "VALUES": true, "SELECT": true, "FOR": true,
+	"RETURNING": true, "WITH": true, "DEFAULT": true, "PARTITION": true, "ROWS": true, "FIRST": true, "SKIP": true,
+	"PLAN": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true, "ADD": true, "DROP": true, "ALTER": true,
+	"RENAME": true, "MODIFY": true, "CHANGE": true, "TO": true, "CASCADE": true, "RESTRICT": true, "IF": true,
+	"LOCK": true, "IN": true, "SHARE": true, "NOWAIT": true, "INTO": true, "DO": true, "ROW": true, "OR": true,
+	"OF": true,
+}
+
+// notFunctionKeywords are keywords, which can be followed by parenthesis, which is not a function call.
+var notFunctionKeywords = map[string]bool{
+	"IN": true, "EXISTS": true, "AS": true, "FROM": true, "JOIN": true, "ANY": true, "SOME": true, "ALL": true,
+	"ON": true, "WHERE": true, "AND": true, "OR": true, "NOT": true, "SELECT": true, "VALUES": true,
+	"LATERAL": true, "UNION": true, "EXCEPT": true, "INTERSECT": true, "USING": true, "THEN": true, "ELSE": true,
+	"WHEN": true, "RETURNING": true, "SET": true, "BY": true, "HAVING": true, "INTO": true, "TABLE": true,
+	"DISTINCT": true, "CASE": true,
+}
+
+// references returns tables and column-like identifiers of statement. It is a lexical approximation, not a parser:
+// a name after FROM, JOIN, UPDATE, INTO, TABLE, TRUNCATE or USING is a table(common table expressions and function
+// calls excluded) and every other identifier is a possible column. Whole row references, i.e. TABLE users statement
+// or table name or alias used as a value(SELECT to_json(u) FROM users u), are star references of the table.
+func (s statement) references() statementReferences {
+	var refs statementReferences
+	ctes := s.cteNames()
+	tokens := s.tokens
+	// functionCall is a stack of open parentheses, true if parenthesis belongs to a function call
+	var functionCall []bool
+	// tableName marks tokens, which are part of table reference
+	tableName := make([]bool, len(tokens))
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.isPunctuation("("):
+			call := i > 0 && isIdentifier(tokens[i-1]) &&
+				!(tokens[i-1].kind == tokenWord && notFunctionKeywords[strings.ToUpper(tokens[i-1].text)])
+			functionCall = append(functionCall, call)
+		case t.isPunctuation(")"):
+			if len(functionCall) > 0 {
+				functionCall = functionCall[:len(functionCall)-1]
+			}
+		case t.kind == tokenWord:
+			keyword := strings.ToUpper(t.text)
+			introducesTable := false
+			switch keyword {
+			case "FROM":
+				// EXTRACT(YEAR FROM x), SUBSTRING(x FROM 2) etc.
+				introducesTable = len(functionCall) == 0 || !functionCall[len(functionCall)-1]
+			case "JOIN", "STRAIGHT_JOIN", "INTO", "TABLE", "USING":
+				introducesTable = true
+			case "UPDATE":
+				// FOR UPDATE and mysql ON DUPLICATE KEY UPDATE
+				introducesTable = i == 0 || !(tokens[i-1].isKeyword("FOR") || tokens[i-1].isKeyword("KEY"))
+			case "TRUNCATE":
+				introducesTable = i+1 < len(tokens) && !tokens[i+1].isKeyword("TABLE")
+			}
+			if !introducesTable {
+				continue
+			}
+			// comma separated lists of FROM and mysql multiple table UPDATE
+			list := keyword == "FROM" || keyword == "UPDATE"
+			j := i + 1
+			for {
+				j = skipKeywords(tokens, j, "ONLY", "LATERAL", "IF", "NOT", "EXISTS")
+				ref, end, ok := readTableReference(tokens, j)
+				if !ok {
+					break
+				}
+				for k := j; k < end; k++ {
+					tableName[k] = true
+				}
+				if ref.schema != "" || !ctes[strings.ToLower(ref.name)] {
+					refs.tables = append(refs.tables, ref)
+					if keyword == "TABLE" && isTableStatement(tokens, i) {
+						refs.columns = append(refs.columns, columnReference{qualifier: ref.name, name: "*"})
+					}
+				}
+				j = end
+				if !list || j >= len(tokens) || !tokens[j].isPunctuation(",") {
+					break
+				}
+				j++
+			}
+		}
+	}
+
+	for i, t := range tokens {
+		if tableName[i] {
+			continue
+		}
+		isStar := t.isPunctuation("*") && i > 0 && (tokens[i-1].isPunctuation(".") ||
+			tokens[i-1].isPunctuation(",") || tokens[i-1].isKeyword("SELECT") ||
+			tokens[i-1].isKeyword("DISTINCT") || tokens[i-1].isKeyword("ALL"))
+		if !isStar && (!isIdentifier(t) || (i+1 < len(tokens) && tokens[i+1].isPunctuation("."))) {
+			// qualifiers are not columns
+			continue
+		}
+		ref := columnReference{name: t.text}
+		if isStar {
+			ref.name = "*"
+		}
+		if i > 1 && tokens[i-1].isPunctuation(".") && isIdentifier(tokens[i-2]) {
+			ref.qualifier = tokens[i-2].text
+		} else if !isStar && !(i+1 < len(tokens) && tokens[i+1].isPunctuation("(")) && refs.isTable(t.text) {
+			// whole row reference
+			ref = columnReference{qualifier: t.text, name: "*", wholeRow: true}
+		}
+		refs.columns = append(refs.columns, ref)
+	}
+	return refs
+}
+
+// isTable returns true if name is a name or alias of referenced table.
+func (r statementReferences) isTable(name string) bool {
+	_, ok := r.table(name)
+	return ok
+}
+
+// table returns referenced table of name or alias.
+func (r statementReferences) table(name string) (tableReference, bool) {
+	for _, table := range r.tables {
+		if strings.EqualFold(table.name, name) || strings.EqualFold(table.alias, name) {
+			return table, true
+		}
+	}
+	return tableReference{}, false
+}
+
+// isTableStatement returns true if TABLE keyword at i is a TABLE statement(TABLE users), not a part of DDL or
+// TRUNCATE TABLE.
+func isTableStatement(tokens []token, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := tokens[i-1]
+	return prev.isPunctuation("(") || prev.isKeyword("UNION") || prev.isKeyword("EXCEPT") ||
+		prev.isKeyword("INTERSECT") || prev.isKeyword("ALL") || prev.isKeyword("DISTINCT")
+}
+
+// cteNames returns lower case names of common table expressions of statement.
+func (s statement) cteNames() map[string]bool {
+	names := map[string]bool{}
+	tokens := s.tokens
+	for i, t := range tokens {
+		if !t.isKeyword("WITH") {
+			continue
+		}
+		j := skipKeywords(tokens, i+1, "RECURSIVE")
+		for j < len(tokens) && isIdentifier(tokens[j]) {
+			name := tokens[j].text
+			j++
+			if j < len(tokens) && tokens[j].isPunctuation("(") {
+				j = skipParentheses(tokens, j)
+			}
+			if j >= len(tokens) || !tokens[j].isKeyword("AS") {
+				break
+			}
+			names[strings.ToLower(name)] = true
+			j = skipKeywords(tokens, j+1, "NOT", "MATERIALIZED")
+			if j >= len(tokens) || !tokens[j].isPunctuation("(") {
+				break
+			}
+			j = skipParentheses(tokens, j)
+			if j >= len(tokens) || !tokens[j].isPunctuation(",") {
+				break
+			}
+			j++
+		}
+	}
+	return names
+}
+
+// readTableReference reads [schema.]name [[AS] alias] at i and returns offset after it. ok is false if there is no
+// table name at i or name is a function call.
+func readTableReference(tokens []token, i int) (tableReference, int, bool) {
+	var parts []string
+	for i < len(tokens) && isIdentifier(tokens[i]) {
+		parts = append(parts, tokens[i].text)
+		i++
+		if i+1 < len(tokens) && tokens[i].isPunctuation(".") && isIdentifier(tokens[i+1]) {
+			i++
+			continue
+		}
+		break
+	}
+	if len(parts) == 0 {
+		return tableReference{}, i, false
+	}
+	if len(parts) == 1 && tokens[i-1].kind == tokenWord && notAliasKeywords[strings.ToUpper(parts[0])] {
+		return tableReference{}, i, false
+	}
+	if i < len(tokens) && tokens[i].isPunctuation("(") {
+		// function call, i.e. FROM generate_series(1, 10), or column list of INSERT INTO t (a, b)
+		if !isColumnList(tokens, i) {
+			return tableReference{}, i, false
+		}
+	}
+
+	ref := tableReference{name: parts[len(parts)-1]}
+	if len(parts) > 1 {
+		ref.schema = parts[len(parts)-2]
+	}
+	if i < len(tokens) && tokens[i].isKeyword("AS") {
+		i++
+	}
+	if i < len(tokens) && isIdentifier(tokens[i]) &&
+		!(tokens[i].kind == tokenWord && (notAliasKeywords[strings.ToUpper(tokens[i].text)] ||
+			notFunctionKeywords[strings.ToUpper(tokens[i].text)])) {
+		ref.alias = tokens[i].text
+		i++
+	}
+	return ref, i, true
+}
+
+// isColumnList returns true if parenthesis at i is followed by VALUES, SELECT or end of statement, i.e. column list
+// of INSERT INTO t (a, b) VALUES.
+func isColumnList(tokens []token, i int) bool {
+	end := skipParentheses(tokens, i)
+	return end >= len(tokens) || tokens[end].isKeyword("VALUES") || tokens[end].isKeyword("SELECT") ||
+		tokens[end].isKeyword("WITH") || tokens[end].isKeyword("DEFAULT") || tokens[end].isKeyword("OVERRIDING")
+}
+
+// skipParentheses returns offset after parenthesis group starting at i.
+func skipParentheses(tokens []token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		if tokens[i].isPunctuation("(") {
+			depth++
+		} else if tokens[i].isPunctuation(")") {
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+func skipKeywords(tokens []token, i int, keywords ...string) int {
+	for i < len(tokens) {
+		skipped := false
+		for _, keyword := range keywords {
+			if tokens[i].isKeyword(keyword) {
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+func isIdentifier(t token) bool {
+	if t.kind == tokenQuotedIdentifier {
+		return true
+	}
+	if t.kind != tokenWord || t.text == "" {
+		return false
+	}
+	c := t.text[0]
+	// numbers and parameters(?, $1, @p, :p) are not identifiers
+	return !(c >= '0' && c <= '9') && c != '$' && c != '@'
+}
+
+// selectList is a SELECT list of statement. depth is a parentheses depth of SELECT, lists of depth 0(including UNION
+// branches) make result columns.
+type selectList struct {
+	depth int
+	items []selectItem
+}
+
+// selectItem is an expression of SELECT list.
+type selectItem struct {
+	// label is an alias or a column name of item. It is empty for not aliased expressions
+	label string
+	// columns are identifiers referenced by expression
+	columns []string
+	// star is true for * and table.*
+	star bool
+}
+
+// selectListEndKeywords are keywords, which end SELECT list.
+var selectListEndKeywords = map[string]bool{
+	"FROM": true, "INTO": true, "WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true,
+	"OFFSET": true, "FETCH": true, "UNION": true, "EXCEPT": true, "INTERSECT": true, "MINUS": true, "WINDOW": true,
+	"FOR": true,
+}
+
+// selectLists returns SELECT lists of statement, including lists of subqueries and common table expressions.
+func (s statement) selectLists() []selectList {
+	var lists []selectList
+	depth := 0
+	for i, t := range s.tokens {
+		switch {
+		case t.isPunctuation("("):
+			depth++
+		case t.isPunctuation(")"):
+			depth--
+		case t.isKeyword("SELECT"):
+			lists = append(lists, selectList{depth: depth, items: readSelectItems(s.tokens, i+1)})
+		}
+	}
+	return lists
+}
+
+func (l selectList) hasStar() bool {
+	for _, item := range l.items {
+		if item.star {
+			return true
+		}
+	}
+	return false
+}
+
+// readSelectItems reads SELECT list starting at i.
+func readSelectItems(tokens []token, i int) []selectItem {
+	i = skipKeywords(tokens, i, "DISTINCT", "ALL", "DISTINCTROW")
+	if i < len(tokens) && tokens[i].isKeyword("ON") {
+		// postgresql DISTINCT ON (expression)
+		i = skipParentheses(tokens, i+1)
+	}
+	// firebird FIRST n SKIP m
+	for i+1 < len(tokens) && (tokens[i].isKeyword("FIRST") || tokens[i].isKeyword("SKIP")) {
+		if tokens[i+1].isPunctuation("(") {
+			i = skipParentheses(tokens, i+1)
+		} else {
+			i += 2
+		}
+	}
+
+	var items []selectItem
+	start := i
+	depth := 0
+loop:
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.isPunctuation("("):
+			depth++
+		case t.isPunctuation(")"):
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case depth > 0:
+		case t.isPunctuation(","):
+			items = append(items, newSelectItem(tokens[start:i]))
+			start = i + 1
+		case t.kind == tokenWord && selectListEndKeywords[strings.ToUpper(t.text)]:
+			break loop
+		}
+	}
+	if i > start {
+		items = append(items, newSelectItem(tokens[start:i]))
+	}
+	return items
+}
+
+func newSelectItem(tokens []token) selectItem {
+	var item selectItem
+	n := len(tokens)
+	if n == 0 {
+		return item
+	}
+	last := tokens[n-1]
+	if last.isPunctuation("*") && (n == 1 || tokens[n-2].isPunctuation(".")) {
+		item.star = true
+		return item
+	}
+
+	expression := tokens
+	switch {
+	case n > 2 && tokens[n-2].isKeyword("AS") && isIdentifier(last):
+		item.label = last.text
+		expression = tokens[:n-2]
+	case n > 1 && isIdentifier(last) && !tokens[n-2].isPunctuation(".") && isAliasable(tokens[n-2]) &&
+		!(last.kind == tokenWord && notAliasKeywords[strings.ToUpper(last.text)]):
+		// alias without AS
+		item.label = last.text
+		expression = tokens[:n-1]
+	case isIdentifier(last) && (n == 1 || (n == 3 && tokens[1].isPunctuation("."))):
+		// [table.]column
+		item.label = last.text
+	}
+	for _, t := range expression {
+		if isIdentifier(t) {
+			item.columns = append(item.columns, t.text)
+		}
+	}
+	return item
+}
+
+// isAliasable returns true if token can end an expression followed by alias, i.e. email e or lower(email) e.
+func isAliasable(t token) bool {
+	switch t.kind {
+	case tokenQuotedIdentifier, tokenString:
+		return true
+	case tokenPunctuation:
+		return t.text == ")"
+	default:
+		return !notFunctionKeywords[strings.ToUpper(t.text)] && !notAliasKeywords[strings.ToUpper(t.text)]
+	}
+}
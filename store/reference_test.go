@@ -0,0 +1,125 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatement_references(t *testing.T) {
+	tests := []struct {
+		query       string
+		sqlType     string
+		wantTables  []tableReference
+		wantColumns []string
+	}{
+		{
+			query:       "select u.id, email from public.users u where id = 1",
+			sqlType:     "postgresql",
+			wantTables:  []tableReference{{schema: "public", name: "users", alias: "u"}},
+			wantColumns: []string{"select", "u.id", "email", "from", "where", "id"},
+		},
+		{
+			query:   "select * from a, b as x join c on c.id = x.id left join (select id from d) e using (id)",
+			sqlType: "postgresql",
+			wantTables: []tableReference{
+				{name: "a"}, {name: "b", alias: "x"}, {name: "c"}, {name: "d"},
+			},
+		},
+		{
+			query:      "with recent as (select id from orders) select extract(year from created_at) from recent",
+			sqlType:    "postgresql",
+			wantTables: []tableReference{{name: "orders"}},
+		},
+		{
+			query:       "insert into `users` (email, password_hash) values ('a', 'b')",
+			sqlType:     "mysql",
+			wantTables:  []tableReference{{name: "users"}},
+			wantColumns: []string{"insert", "into", "email", "password_hash", "values"},
+		},
+		{
+			query:      "update users u, accounts a set u.name = 'x' where u.id = a.user_id",
+			sqlType:    "mysql",
+			wantTables: []tableReference{{name: "users", alias: "u"}, {name: "accounts", alias: "a"}},
+		},
+		{
+			query:      "insert into t (a) values (1) on duplicate key update a = 2",
+			sqlType:    "mysql",
+			wantTables: []tableReference{{name: "t"}},
+		},
+		{
+			query:      "select id from t for update",
+			sqlType:    "postgresql",
+			wantTables: []tableReference{{name: "t"}},
+		},
+		{
+			query:      "select x from generate_series(1, 3) x",
+			sqlType:    "postgresql",
+			wantTables: nil,
+		},
+		{
+			query:      "truncate logs",
+			sqlType:    "postgresql",
+			wantTables: []tableReference{{name: "logs"}},
+		},
+		{
+			query:      `drop table if exists "Users"`,
+			sqlType:    "firebird",
+			wantTables: []tableReference{{name: "Users"}},
+		},
+		{
+			query:       "select to_json(u), users.id from users u",
+			sqlType:     "postgresql",
+			wantTables:  []tableReference{{name: "users", alias: "u"}},
+			wantColumns: []string{"select", "to_json", "u.*", "users.id", "from"},
+		},
+		{
+			query:       "select users from users",
+			sqlType:     "postgresql",
+			wantTables:  []tableReference{{name: "users"}},
+			wantColumns: []string{"select", "users.*", "from"},
+		},
+		{
+			query:       "TABLE users",
+			sqlType:     "postgresql",
+			wantTables:  []tableReference{{name: "users"}},
+			wantColumns: []string{"users.*", "TABLE"},
+		},
+		{
+			query:       "create table users (id int)",
+			sqlType:     "postgresql",
+			wantTables:  []tableReference{{name: "users"}},
+			wantColumns: []string{"create", "table", "id", "int"},
+		},
+		{
+			query:      "merge into t using s on t.id = s.id when matched then delete",
+			sqlType:    "firebird",
+			wantTables: []tableReference{{name: "t"}, {name: "s"}},
+		},
+		{
+			query:      "update or insert into t (id) values (1) matching (id)",
+			sqlType:    "firebird",
+			wantTables: []tableReference{{name: "t"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			statements := splitStatements(tt.query, tt.sqlType)
+			require.Len(t, statements, 1)
+			refs := statements[0].references()
+			assert.Equal(t, tt.wantTables, refs.tables)
+			if tt.wantColumns != nil {
+				var columns []string
+				for _, column := range refs.columns {
+					if column.qualifier != "" {
+						columns = append(columns, column.qualifier+"."+column.name)
+					} else {
+						columns = append(columns, column.name)
+					}
+				}
+				assert.Equal(t, tt.wantColumns, columns)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/minlau/mdb-tool/internal/utils/closer"
+)
+
+// StringList is a list of strings, which can be scanned from comma separated string(i.e. data source query column).
+type StringList []string
+
+func (l *StringList) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+	case string:
+		*l = splitStringList(v)
+	case []byte:
+		*l = splitStringList(string(v))
+	default:
+		return errors.Errorf("unsupported string list type: %T", src)
+	}
+	return nil
+}
+
+func splitStringList(value string) StringList {
+	var list StringList
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getSchemaStatements returns statements, which change default schema of connection and restore it to default one.
+func getSchemaStatements(c DatabaseConnConfig, schema string) (set string, reset string, err error) {
+	switch c.Type {
+	case "postgresql":
+		return "SET search_path TO " + quoteIdentifier(schema), "RESET search_path", nil
+	case "mysql":
+		return "USE " + quoteMySqlIdentifier(schema), "USE " + quoteMySqlIdentifier(c.Name), nil
+	default:
+		return "", "", errors.Errorf("schema is not supported by type: %s", c.Type)
+	}
+}
+
+func quoteMySqlIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// executeQueryInSchema executes query with given default schema. Connection schema is restored after execution or
+// connection is discarded if it fails, so that pooled connections always use default schema.
+func executeQueryInSchema(ctx context.Context, db *sqlx.DB, c DatabaseConnConfig, query string, schema string) ([]*QueryData, error) {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Handle(conn, "database connection")
+
+	if schema == "" {
+		return executeQuery(ctx, conn, c.Type, query)
+	}
+
+	set, reset, err := getSchemaStatements(c, schema)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.ExecContext(ctx, set)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set schema: %s", schema)
+	}
+	defer resetSchema(conn, reset)
+
+	return executeQuery(ctx, conn, c.Type, query)
+}
+
+func resetSchema(conn *sqlx.Conn, reset string) {
+	// request context can be already canceled, but connection still must be reset before returning it to the pool
+	_, err := conn.ExecContext(context.Background(), reset)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to reset schema, discarding connection")
+		_ = conn.Raw(func(any) error {
+			return driver.ErrBadConn
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringList_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    StringList
+		wantErr bool
+	}{
+		{"nil", nil, nil, false},
+		{"string", "public, audit,,", StringList{"public", "audit"}, false},
+		{"bytes", []byte("public"), StringList{"public"}, false},
+		{"unsupported", 1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got StringList
+			err := got.Scan(tt.src)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetSchemaStatements(t *testing.T) {
+	tests := []struct {
+		config    DatabaseConnConfig
+		schema    string
+		wantSet   string
+		wantReset string
+		wantErr   bool
+	}{
+		{DatabaseConnConfig{Type: "postgresql", Name: "db"}, `audit"x`, `SET search_path TO "audit""x"`,
+			"RESET search_path", false},
+		{DatabaseConnConfig{Type: "mysql", Name: "db"}, "audit`x", "USE `audit``x`", "USE `db`", false},
+		{DatabaseConnConfig{Type: "firebird", Name: "db"}, "audit", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.config.Type, func(t *testing.T) {
+			set, reset, err := getSchemaStatements(tt.config, tt.schema)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantSet, set)
+			assert.Equal(t, tt.wantReset, reset)
+		})
+	}
+}
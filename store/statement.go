@@ -0,0 +1,84 @@
+package store
+
+import (
+	"strings"
+)
+
+// Statement kinds.
+const (
+	StatementKindSelect = "select"
+	StatementKindInsert = "insert"
+	StatementKindUpdate = "update"
+	StatementKindDelete = "delete"
+	StatementKindMerge  = "merge"
+	StatementKindDdl    = "ddl"
+	StatementKindOther  = "other"
+)
+
+// mainKeyword returns first keyword of statement after top level WITH clause(common table expressions).
+// I.e. DELETE for: WITH old AS (SELECT ...) DELETE FROM ...
+func (s statement) mainKeyword() string {
+	first := s.firstKeyword()
+	if first != "WITH" {
+		return first
+	}
+	depth := 0
+	for _, t := range s.tokens[1:] {
+		switch {
+		case t.isPunctuation("("):
+			depth++
+		case t.isPunctuation(")"):
+			depth--
+		case depth == 0 && t.kind == tokenWord:
+			switch keyword := strings.ToUpper(t.text); keyword {
+			case "SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "VALUES", "TABLE":
+				return keyword
+			}
+		}
+	}
+	return first
+}
+
+func (s statement) hasKeyword(keyword string) bool {
+	for _, t := range s.tokens {
+		if t.isKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// returnsRows returns false for data modification statements without RETURNING clause and DDL statements. Such
+// statements are executed without reading rows to get rows affected count. Unknown statements are expected to return
+// rows.
+func (s statement) returnsRows() bool {
+	switch s.mainKeyword() {
+	case "INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE":
+		return s.hasKeyword("RETURNING")
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "RECREATE", "COMMENT", "GRANT", "REVOKE":
+		return false
+	default:
+		return true
+	}
+}
+
+// kind returns statement kind(see StatementKindSelect etc.). Reading statements(i.e. SHOW, EXPLAIN) are
+// StatementKindSelect.
+func (s statement) kind() string {
+	switch s.mainKeyword() {
+	case "SELECT", "VALUES", "TABLE", "SHOW", "EXPLAIN", "DESCRIBE", "DESC":
+		return StatementKindSelect
+	case "INSERT", "REPLACE":
+		return StatementKindInsert
+	case "UPDATE":
+		return StatementKindUpdate
+	case "DELETE":
+		return StatementKindDelete
+	case "MERGE":
+		return StatementKindMerge
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "RECREATE", "COMMENT":
+		return StatementKindDdl
+	default:
+		return StatementKindOther
+	}
+}
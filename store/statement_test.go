@@ -0,0 +1,55 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatement_returnsRows(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "select 1", want: true},
+		{query: "insert into t values (1)", want: false},
+		{query: "insert into t values (1) returning id", want: true},
+		{query: "with old as (select id from t) delete from t where id in (select id from old)", want: false},
+		{query: "with x as (delete from t returning id) select * from x", want: true},
+		{query: "create table t (id int)", want: false},
+		{query: "show tables", want: true},
+		{query: "call p()", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			statements := splitStatements(tt.query, "postgresql")
+			assert.Len(t, statements, 1)
+			assert.Equal(t, tt.want, statements[0].returnsRows())
+		})
+	}
+}
+
+func TestStatement_kind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "select 1", want: StatementKindSelect},
+		{query: "explain select 1", want: StatementKindSelect},
+		{query: "insert into t values (1)", want: StatementKindInsert},
+		{query: "replace into t values (1)", want: StatementKindInsert},
+		{query: "with old as (select id from t) update t set a = 1", want: StatementKindUpdate},
+		{query: "delete from t", want: StatementKindDelete},
+		{query: "merge into t using s on t.id = s.id when matched then delete", want: StatementKindMerge},
+		{query: "alter table t add c int", want: StatementKindDdl},
+		{query: "grant select on t to u", want: StatementKindOther},
+		{query: "execute block as begin end", want: StatementKindOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			statements := splitStatements(tt.query, "firebird")
+			assert.Len(t, statements, 1)
+			assert.Equal(t, tt.want, statements[0].kind())
+		})
+	}
+}
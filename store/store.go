@@ -7,6 +7,7 @@ import (
 	"github.com/minlau/mdb-tool/internal/utils/closer"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -15,42 +16,135 @@ import (
 type DatabaseStoreI interface {
 	AddDatabases(databases []DatabaseConfig)
 	AddDatabase(config DatabaseConfig) error
-	GetTablesMetadata(groupName string, groupType string) (map[string][]string, error)
-	QueryDatabase(ctx context.Context, groupName string, groupType string, query string) GroupQueryResult
-	QueryMultipleDatabases(ctx context.Context, groupType string, query string) []GroupQueryResult
+	GetTablesMetadata(ctx context.Context, groupName string, groupType string, schemas []string) (*TablesMetadata, error)
+	InvalidateTablesMetadata(groupName string, groupType string) error
+	QueryDatabase(ctx context.Context, groupName string, groupType string, query string, options QueryOptions) GroupQueryResult
+	QueryMultipleDatabases(ctx context.Context, groupType string, query string, options QueryOptions) []GroupQueryResult
 	GetDatabaseItems() []DatabaseItem
+	DiffDatabases(ctx context.Context, groupType string, groupNames []string, query string, keyColumns []string, role string) DiffResult
+	GetSchemaDrift(ctx context.Context, groupType string, baseGroupName string) (SchemaDriftReport, error)
+	PlanWrite(ctx context.Context, groupType string, groupNames []string, query string, previewQuery string, role string) (WritePlan, error)
+	ConfirmWrite(ctx context.Context, token string, tolerancePercent float64) (WriteResult, error)
+	GetMigrationStatus(ctx context.Context, groupType string, groupNames []string) (MigrationStatus, error)
+	Migrate(ctx context.Context, groupType string, direction string, options MigrationOptions) (MigrationResult, error)
+	ExplainQuery(ctx context.Context, groupType string, groupNames []string, query string) ([]ExplainResult, error)
+}
+
+// QueryOptions are per request query options.
+type QueryOptions struct {
+	// Order is used only by multiple databases query
+	Order ResultOrder
+	// Schema is a default schema of query execution(search_path for postgresql, USE for mysql)
+	Schema string
+	// GroupNames limits databases of multiple databases query. All databases of group type are queried if not set
+	GroupNames []string
+	// Role is a caller role used by masking rules(see MaskingRuleConfig)
+	Role string
+	// Confirm allows destructive statements of policies, which require confirmation(see DestructiveStatementsConfirm)
+	Confirm bool
+	// MaskAll applies all masking rules regardless of Role, i.e. to results stored for any caller
+	MaskAll bool
 }
 
 type DatabaseInstance struct {
 	Config DatabaseConfig
 	DB     *sqlx.DB
+	// order is a position of database in config. It is used to sort databases by OrderByConfig
+	order int
+}
+
+type Config struct {
+	Concurrency ConcurrencyConfig
+	// OrderBy is a default sort key of multiple databases results. Default: OrderByGroupName
+	OrderBy       string
+	MetadataCache MetadataCacheConfig
+	Write         WriteConfig
+	Migrations    MigrationsConfig
+	// Policies restrict queries of databases(see PolicyConfig)
+	Policies []PolicyConfig
+	// Masking masks sensitive values of query results
+	Masking MaskingConfig
 }
 
 type DatabaseStore struct {
 	m         *sync.Mutex
 	databases map[DatabaseGroup]DatabaseInstance
+	limiter   *queryLimiter
+	orderBy   string
+	cache     *metadataCache
+	// invalidateOnDdl is MetadataCacheConfig.InvalidateOnDdl
+	invalidateOnDdl bool
+	writePlans      *writePlans
+	migrations      MigrationsConfig
+	policies        []policy
+	masking         *masking
+	// migrationLocks serialize migrations of group type
+	migrationLocks map[string]*sync.Mutex
+	// added is count of databases passed to AddDatabase(s), used to keep config order
+	added int
 }
 
-func NewDatabaseStore() *DatabaseStore {
-	return &DatabaseStore{&sync.Mutex{}, make(map[DatabaseGroup]DatabaseInstance)}
+func NewDatabaseStore(config Config) (*DatabaseStore, error) {
+	orderBy := config.OrderBy
+	if orderBy == "" || !IsValidOrderBy(orderBy) {
+		if orderBy != "" {
+			log.Warn().Str("orderBy", orderBy).Msg("unknown orderBy, using groupName")
+		}
+		orderBy = OrderByGroupName
+	}
+	policies, err := newPolicies(config.Policies)
+	if err != nil {
+		return nil, err
+	}
+	masking, err := newMasking(config.Masking)
+	if err != nil {
+		return nil, err
+	}
+	return &DatabaseStore{
+		m:               &sync.Mutex{},
+		databases:       make(map[DatabaseGroup]DatabaseInstance),
+		limiter:         newQueryLimiter(config.Concurrency),
+		orderBy:         orderBy,
+		cache:           newMetadataCache(config.MetadataCache),
+		invalidateOnDdl: config.MetadataCache.InvalidateOnDdl,
+		writePlans:      newWritePlans(config.Write),
+		migrations:      config.Migrations,
+		policies:        policies,
+		masking:         masking,
+		migrationLocks:  make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// reserveOrder reserves config order positions for count of databases.
+func (s *DatabaseStore) reserveOrder(count int) int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	order := s.added
+	s.added += count
+	return order
 }
 
 func (s *DatabaseStore) AddDatabases(databases []DatabaseConfig) {
+	order := s.reserveOrder(len(databases))
 	var wg sync.WaitGroup
-	for _, item := range databases {
+	for i, item := range databases {
 		wg.Add(1)
-		go func(config DatabaseConfig) {
+		go func(config DatabaseConfig, order int) {
 			defer wg.Done()
-			err := s.AddDatabase(config)
+			err := s.addDatabase(config, order)
 			if err != nil {
 				log.Warn().Err(err).Msg("failed to add database")
 			}
-		}(item)
+		}(item, order+i)
 	}
 	wg.Wait()
 }
 
 func (s *DatabaseStore) AddDatabase(config DatabaseConfig) error {
+	return s.addDatabase(config, s.reserveOrder(1))
+}
+
+func (s *DatabaseStore) addDatabase(config DatabaseConfig, order int) error {
 	db, err := OpenDatabase(config.DatabaseConnConfig)
 	if err != nil {
 		return errors.Wrap(err, "failed to open database")
@@ -67,86 +161,46 @@ func (s *DatabaseStore) AddDatabase(config DatabaseConfig) error {
 		return errors.Errorf("database is already added with groupName=%v, groupType=%v", config.GroupName,
 			config.GroupType)
 	}
-	s.databases[config.DatabaseGroup] = DatabaseInstance{config, db}
+	s.databases[config.DatabaseGroup] = DatabaseInstance{Config: config, DB: db, order: order}
 	return nil
 }
 
-const selectPgTablesMetadata = `
-SELECT
-    t.table_name, c.column_name
-FROM
-    information_schema.tables AS t
-    INNER JOIN information_schema.columns AS c ON t.table_name = c.table_name
-WHERE
-    t.table_schema = current_schema() AND t.table_type = 'BASE TABLE';
-`
-const selectFbTablesMetadata = `
-SELECT
-    trim(f.rdb$relation_name) AS table_name, trim(f.rdb$field_name) AS column_name
-FROM
-    rdb$relation_fields AS f
-    JOIN rdb$relations AS r ON
-            f.rdb$relation_name = r.rdb$relation_name
-            AND r.rdb$view_blr IS NULL
-            AND (r.rdb$system_flag IS NULL OR r.rdb$system_flag = 0)
-ORDER BY
-    1, f.rdb$field_position;
-`
-const selectMySqlTablesMetadata = `
-SELECT
-    table_name, column_name
-FROM
-    information_schema.columns
-WHERE
-    table_schema = database()
-ORDER BY
-    table_name, ordinal_position;
-`
-
-func getTablesMetadataSql(sqlType string) string {
-	switch sqlType {
-	case "postgresql":
-		return selectPgTablesMetadata
-	case "firebird":
-		return selectFbTablesMetadata
-	case "mysql":
-		return selectMySqlTablesMetadata
-	default:
-		return ""
+// GetTablesMetadata returns metadata of given schemas. Database config schemas are used if schemas are not set.
+// Metadata is cached if cache is enabled(see MetadataCacheConfig).
+func (s *DatabaseStore) GetTablesMetadata(ctx context.Context, groupName string, groupType string, schemas []string) (*TablesMetadata, error) {
+	group := DatabaseGroup{groupName, groupType}
+	databaseInstance, ok := s.databases[group]
+	if !ok {
+		return nil, errors.Errorf("no database registered with groupName: %s, groupType: %s", groupName, groupType)
 	}
-}
 
-func queryTablesMetadata(db *sqlx.DB, query string) (map[string][]string, error) {
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, err
+	if len(schemas) == 0 {
+		schemas = databaseInstance.Config.Schemas
+	}
+	if metadata, ok := s.cache.get(group, schemas); ok {
+		return metadata, nil
 	}
-	data := make(map[string][]string)
-	for rows.Next() {
-		var tableName string
-		var columnName string
-		err := rows.Scan(&tableName, &columnName)
-		if err != nil {
-			return nil, err
-		}
 
-		data[tableName] = append(data[tableName], columnName)
+	generation := s.cache.generation(group)
+	metadata, err := queryTablesMetadata(ctx, databaseInstance.DB, databaseInstance.Config.Type, schemas)
+	if err != nil {
+		return nil, err
 	}
-	return data, nil
+	s.cache.set(group, schemas, generation, metadata)
+	return metadata, nil
 }
 
-func (s *DatabaseStore) GetTablesMetadata(groupName string, groupType string) (map[string][]string, error) {
-	databaseInstance, ok := s.databases[DatabaseGroup{groupName, groupType}]
-	if !ok {
-		return nil, errors.Errorf("no database registered with groupName: %s, groupType: %s", groupName, groupType)
+// InvalidateTablesMetadata removes cached tables metadata of database, so that next GetTablesMetadata fetches it.
+func (s *DatabaseStore) InvalidateTablesMetadata(groupName string, groupType string) error {
+	group := DatabaseGroup{groupName, groupType}
+	if _, ok := s.databases[group]; !ok {
+		return errors.Errorf("no database registered with groupName: %s, groupType: %s", groupName, groupType)
 	}
-
-	query := getTablesMetadataSql(databaseInstance.Config.Type)
-	data, err := queryTablesMetadata(databaseInstance.DB, query)
-	return data, err
+	s.cache.invalidate(group)
+	return nil
 }
 
-func (s *DatabaseStore) QueryDatabase(ctx context.Context, groupName string, groupType string, query string) GroupQueryResult {
+func (s *DatabaseStore) QueryDatabase(ctx context.Context, groupName string, groupType string, query string, options QueryOptions) GroupQueryResult {
 	databaseInstance, ok := s.databases[DatabaseGroup{groupName, groupType}]
 	if !ok {
 		return GroupQueryResult{
@@ -156,60 +210,157 @@ func (s *DatabaseStore) QueryDatabase(ctx context.Context, groupName string, gro
 		}
 	}
 
-	data, err := executeQuery(ctx, databaseInstance.DB, query)
-	return GroupQueryResult{GroupName: groupName, Data: data, Error: NewQueryError(err)}
+	return s.queryDatabaseInstance(ctx, databaseInstance, query, options)
 }
 
-func (s *DatabaseStore) QueryMultipleDatabases(ctx context.Context, groupType string, query string) []GroupQueryResult {
-	var results []GroupQueryResult
-	var mutex = &sync.Mutex{}
-	var filteredDatabases = make(map[string]*sqlx.DB)
-
+func (s *DatabaseStore) QueryMultipleDatabases(ctx context.Context, groupType string, query string, options QueryOptions) []GroupQueryResult {
+	var groupNames map[string]bool
+	if len(options.GroupNames) > 0 {
+		groupNames = make(map[string]bool, len(options.GroupNames))
+		for _, groupName := range options.GroupNames {
+			groupNames[groupName] = true
+		}
+	}
+	var filteredDatabases []DatabaseInstance
 	for key, value := range s.databases {
-		if key.GroupType == groupType {
-			filteredDatabases[key.GroupName] = value.DB
+		if key.GroupType == groupType && (groupNames == nil || groupNames[key.GroupName]) {
+			filteredDatabases = append(filteredDatabases, value)
 		}
 	}
+	if options.Order.By == "" {
+		options.Order.By = s.orderBy
+	}
+	sortDatabaseInstances(filteredDatabases, options.Order)
 
+	results := make([]GroupQueryResult, len(filteredDatabases))
 	var wg sync.WaitGroup
-	for groupName, db := range filteredDatabases {
+	for i, databaseInstance := range filteredDatabases {
 		wg.Add(1)
-		go func(groupName string, db *sqlx.DB) {
+		go func(i int, databaseInstance DatabaseInstance) {
 			defer wg.Done()
-
-			data, err := executeQuery(ctx, db, query)
-			var groupQueryResult = GroupQueryResult{
-				groupName,
-				data,
-				NewQueryError(err),
-			}
-			mutex.Lock()
-			defer mutex.Unlock()
-			results = append(results, groupQueryResult)
-		}(groupName, db)
+			results[i] = s.queryDatabaseInstance(ctx, databaseInstance, query, options)
+		}(i, databaseInstance)
 	}
 	wg.Wait()
 	return results
 }
 
+// groupDatabaseInstances returns databases of group type. All databases are returned if groupNames is empty.
+func (s *DatabaseStore) groupDatabaseInstances(groupType string, groupNames []string) ([]DatabaseInstance, error) {
+	var instances []DatabaseInstance
+	if len(groupNames) == 0 {
+		for key, value := range s.databases {
+			if key.GroupType == groupType {
+				instances = append(instances, value)
+			}
+		}
+	} else {
+		for _, groupName := range groupNames {
+			instance, ok := s.databases[DatabaseGroup{groupName, groupType}]
+			if !ok {
+				return nil, errors.Errorf("no database registered with groupName: %s, groupType: %s", groupName,
+					groupType)
+			}
+			instances = append(instances, instance)
+		}
+	}
+	if len(instances) == 0 {
+		return nil, errors.Errorf("no databases registered with groupType: %s", groupType)
+	}
+	sortDatabaseInstances(instances, ResultOrder{By: s.orderBy})
+	return instances, nil
+}
+
+// queryDatabaseInstance waits for a free query slot(see ConcurrencyConfig) and executes query.
+func (s *DatabaseStore) queryDatabaseInstance(ctx context.Context, databaseInstance DatabaseInstance, query string, options QueryOptions) GroupQueryResult {
+	groupName := databaseInstance.Config.GroupName
+	violation := checkPolicies(s.policies, databaseInstance.Config.DatabaseGroup, databaseInstance.Config.Type, query,
+		options.Confirm)
+	if violation != nil {
+		return GroupQueryResult{GroupName: groupName, Error: NewQueryError(violation)}
+	}
+	release, wait, err := s.limiter.acquire(ctx, hostKey(databaseInstance.Config.DatabaseConnConfig))
+	if err != nil {
+		return GroupQueryResult{
+			GroupName:              groupName,
+			Error:                  NewQueryError(errors.Wrap(err, "failed to wait for free query slot")),
+			WaitTimeInMilliseconds: wait.Milliseconds(),
+		}
+	}
+	defer release()
+
+	start := time.Now()
+	results, err := executeQueryInSchema(ctx, databaseInstance.DB, databaseInstance.Config.DatabaseConnConfig, query,
+		options.Schema)
+	executionTime := time.Since(start)
+	s.masking.apply(databaseInstance.Config.DatabaseGroup, databaseInstance.Config.Type, query, options, results)
+	// DDL can be applied even if query fails(i.e. mysql implicit commit), so cache is invalidated regardless of error
+	if s.invalidateOnDdl && containsDdl(query, databaseInstance.Config.Type) {
+		s.cache.invalidate(databaseInstance.Config.DatabaseGroup)
+	}
+	data := primaryResult(results)
+	result := GroupQueryResult{
+		GroupName:                   groupName,
+		Data:                        data,
+		Results:                     results,
+		Error:                       NewQueryError(err),
+		RowsAffected:                totalRowsAffected(results),
+		WaitTimeInMilliseconds:      wait.Milliseconds(),
+		ExecutionTimeInMilliseconds: executionTime.Milliseconds(),
+	}
+	if data != nil {
+		result.StatementKind = data.StatementKind
+	}
+	return result
+}
+
+// totalRowsAffected returns sum of rows affected by all statements or nil if no statement reported it.
+func totalRowsAffected(results []*QueryData) *int64 {
+	var total *int64
+	for _, data := range results {
+		if data.RowsAffected != nil {
+			if total == nil {
+				total = new(int64)
+			}
+			*total += *data.RowsAffected
+		}
+	}
+	return total
+}
+
 type DatabaseItem struct {
 	DatabaseGroup
-	Type string `json:"type"`
+	Type  string `json:"type"`
+	Label string `json:"label,omitempty"`
 }
 
+// GetDatabaseItems returns databases sorted by group type and store default sort key.
 func (s *DatabaseStore) GetDatabaseItems() []DatabaseItem {
-	arr := make([]DatabaseItem, 0, len(s.databases))
+	instances := make([]DatabaseInstance, 0, len(s.databases))
 	for _, value := range s.databases {
+		instances = append(instances, value)
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		if instances[i].Config.GroupType != instances[j].Config.GroupType {
+			return instances[i].Config.GroupType < instances[j].Config.GroupType
+		}
+		return lessDatabaseInstance(instances[i], instances[j], s.orderBy)
+	})
+
+	arr := make([]DatabaseItem, 0, len(instances))
+	for _, value := range instances {
 		arr = append(arr, DatabaseItem{
 			DatabaseGroup: value.Config.DatabaseGroup,
 			Type:          value.Config.Type,
+			Label:         value.Config.Label,
 		})
 	}
 	return arr
 }
 
-func executeQuery(ctx context.Context, db *sqlx.DB, query string) (*QueryData, error) {
-	tx, err := db.BeginTx(ctx, nil)
+// executeQuery executes query statements in a transaction(see executeStatements) and commits it.
+func executeQuery(ctx context.Context, conn *sqlx.Conn, sqlType string, query string) ([]*QueryData, error) {
+	tx, err := conn.BeginTxx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -223,43 +374,140 @@ func executeQuery(ctx context.Context, db *sqlx.DB, query string) (*QueryData, e
 		}
 	}()
 
-	rows, err := tx.QueryContext(ctx, query)
+	results, err := executeStatements(ctx, tx, sqlType, query)
 	if err != nil {
-		return nil, err
+		return results, err
 	}
-	var data QueryData
-	fieldNames := make([]string, 0)
-	for rows.Next() {
-		if len(data.Columns) == 0 {
-			columnNames, err := rows.Columns()
-			if err != nil {
-				return &data, err
-			}
 
-			fieldNames = getFieldNames(columnNames)
+	err = tx.Commit()
+	if err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// executeStatements executes query statements(see splitStatements) one by one in a transaction. Every result set of
+// row returning statements and rows affected count of other statements are returned in statements order. Results of
+// already executed statements are returned on error.
+func executeStatements(ctx context.Context, tx *sqlx.Tx, sqlType string, query string) ([]*QueryData, error) {
+	var err error
+	statements := splitStatements(query, sqlType)
+	if len(statements) == 0 {
+		// query is not sent as is, policies were checked against its statements
+		return nil, errors.New("query contains no statements")
+	}
 
-			columns := make([]Column, 0, len(columnNames))
-			for i := range columnNames {
-				columns = append(columns, Column{
-					Name:      columnNames[i],
-					FieldName: fieldNames[i],
-				})
+	var results []*QueryData
+	for i, s := range statements {
+		var statementResults []*QueryData
+		if len(s.tokens) == 0 || s.returnsRows() {
+			statementResults, err = queryStatement(ctx, tx, s.text)
+		} else {
+			statementResults, err = execStatement(ctx, tx, s.text)
+		}
+		for _, data := range statementResults {
+			data.StatementIndex = i
+			data.StatementKind = s.kind()
+			switch data.StatementKind {
+			case StatementKindInsert, StatementKindUpdate, StatementKindDelete:
+				// RETURNING returns a row per affected row
+				if data.RowsAffected == nil {
+					rowsAffected := int64(len(data.Rows))
+					data.RowsAffected = &rowsAffected
+				}
+			}
+		}
+		results = append(results, statementResults...)
+		if err != nil {
+			if len(statements) > 1 {
+				err = errors.Wrapf(err, "statement %d failed", i+1)
 			}
+			return results, err
+		}
+	}
 
-			data.Columns = columns
+	return results, nil
+}
+
+func execStatement(ctx context.Context, tx *sqlx.Tx, statement string) ([]*QueryData, error) {
+	res, err := tx.ExecContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	data := &QueryData{Columns: []Column{}, Rows: []map[string]any{}}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil {
+		data.RowsAffected = &rowsAffected
+	}
+	return []*QueryData{data}, nil
+}
+
+// queryStatement reads every result set of statement(i.e. mysql CALL can return multiple result sets).
+func queryStatement(ctx context.Context, tx *sqlx.Tx, statement string) ([]*QueryData, error) {
+	rows, err := tx.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Handle(rows, "rows")
+
+	var results []*QueryData
+	for {
+		data, err := readResultSet(rows)
+		if data != nil {
+			results = append(results, data)
+		}
+		if err != nil {
+			return results, err
+		}
+		if !rows.NextResultSet() {
+			break
 		}
+	}
+	return results, rows.Err()
+}
 
-		row, err := customMapScan(rows, fieldNames)
+func readResultSet(rows *sql.Rows) (*QueryData, error) {
+	columnNames, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	fieldNames := getFieldNames(columnNames)
+	data := &QueryData{Columns: make([]Column, 0, len(columnNames)), Rows: []map[string]any{}}
+	for i := range columnNames {
+		data.Columns = append(data.Columns, Column{
+			Name:      columnNames[i],
+			FieldName: fieldNames[i],
+			Type:      NewColumnType(columnTypes[i]),
+		})
+	}
+
+	for rows.Next() {
+		row, err := customMapScan(rows, data.Columns)
 		if err != nil {
-			return &data, err
+			return data, err
 		}
 		data.Rows = append(data.Rows, row)
 	}
-	err = tx.Commit()
-	if err != nil {
-		return &data, err
+	return data, rows.Err()
+}
+
+// primaryResult returns last result with columns or last result if there are no results with columns. This is a
+// result, which is shown, if client does not support multiple results.
+func primaryResult(results []*QueryData) *QueryData {
+	for i := len(results) - 1; i >= 0; i-- {
+		if len(results[i].Columns) > 0 {
+			return results[i]
+		}
 	}
-	return &data, nil
+	if len(results) > 0 {
+		return results[len(results)-1]
+	}
+	return nil
 }
 
 // getFieldNames creates a unique list of columns names while renaming duplicate column names if needed.
@@ -290,7 +538,9 @@ func contains(arr []string, value string) bool {
 	return false
 }
 
-func customMapScan(r sqlx.ColScanner, columns []string) (map[string]any, error) {
+// customMapScan scans row into a map by column field names. []byte values are converted to strings, except of binary
+// columns values(see LogicalTypeBinary).
+func customMapScan(r sqlx.ColScanner, columns []Column) (map[string]any, error) {
 	// ignore r.started, since we needn't use reflect for anything.
 	valuesArr := make([]any, len(columns))
 	for i := range valuesArr {
@@ -305,11 +555,15 @@ func customMapScan(r sqlx.ColScanner, columns []string) (map[string]any, error)
 	}
 
 	for i, column := range columns {
-		switch (*(valuesArr[i].(*any))).(type) {
+		switch value := (*(valuesArr[i].(*any))).(type) {
 		case []byte: //needed for mysql, some unsupported data types to convert byte array to string
-			valuesMap[column] = string((*(valuesArr[i].(*any))).([]byte))
+			if column.Type != nil && column.Type.LogicalType == LogicalTypeBinary {
+				valuesMap[column.FieldName] = value
+			} else {
+				valuesMap[column.FieldName] = string(value)
+			}
 		default:
-			valuesMap[column] = *(valuesArr[i].(*any))
+			valuesMap[column.FieldName] = value
 		}
 	}
 
@@ -5,12 +5,20 @@ import (
 )
 
 type DatabaseStoreMock struct {
-	AddDatabasesFunc           func(databases []DatabaseConfig)
-	AddDatabaseFunc            func(config DatabaseConfig) error
-	GetTablesMetadataFunc      func(groupName string, groupType string) (map[string][]string, error)
-	QueryDatabaseFunc          func(ctx context.Context, groupName string, groupType string, query string) GroupQueryResult
-	QueryMultipleDatabasesFunc func(ctx context.Context, groupType string, query string) []GroupQueryResult
-	GetDatabaseItemsFunc       func() []DatabaseItem
+	AddDatabasesFunc             func(databases []DatabaseConfig)
+	AddDatabaseFunc              func(config DatabaseConfig) error
+	GetTablesMetadataFunc        func(ctx context.Context, groupName string, groupType string, schemas []string) (*TablesMetadata, error)
+	InvalidateTablesMetadataFunc func(groupName string, groupType string) error
+	QueryDatabaseFunc            func(ctx context.Context, groupName string, groupType string, query string, options QueryOptions) GroupQueryResult
+	QueryMultipleDatabasesFunc   func(ctx context.Context, groupType string, query string, options QueryOptions) []GroupQueryResult
+	GetDatabaseItemsFunc         func() []DatabaseItem
+	DiffDatabasesFunc            func(ctx context.Context, groupType string, groupNames []string, query string, keyColumns []string, role string) DiffResult
+	GetSchemaDriftFunc           func(ctx context.Context, groupType string, baseGroupName string) (SchemaDriftReport, error)
+	PlanWriteFunc                func(ctx context.Context, groupType string, groupNames []string, query string, previewQuery string, role string) (WritePlan, error)
+	ConfirmWriteFunc             func(ctx context.Context, token string, tolerancePercent float64) (WriteResult, error)
+	GetMigrationStatusFunc       func(ctx context.Context, groupType string, groupNames []string) (MigrationStatus, error)
+	MigrateFunc                  func(ctx context.Context, groupType string, direction string, options MigrationOptions) (MigrationResult, error)
+	ExplainQueryFunc             func(ctx context.Context, groupType string, groupNames []string, query string) ([]ExplainResult, error)
 }
 
 func (d DatabaseStoreMock) AddDatabases(databases []DatabaseConfig) {
@@ -21,18 +29,50 @@ func (d DatabaseStoreMock) AddDatabase(config DatabaseConfig) error {
 	return d.AddDatabaseFunc(config)
 }
 
-func (d DatabaseStoreMock) GetTablesMetadata(groupName string, groupType string) (map[string][]string, error) {
-	return d.GetTablesMetadataFunc(groupName, groupType)
+func (d DatabaseStoreMock) GetTablesMetadata(ctx context.Context, groupName string, groupType string, schemas []string) (*TablesMetadata, error) {
+	return d.GetTablesMetadataFunc(ctx, groupName, groupType, schemas)
 }
 
-func (d DatabaseStoreMock) QueryDatabase(ctx context.Context, groupName string, groupType string, query string) GroupQueryResult {
-	return d.QueryDatabaseFunc(ctx, groupName, groupType, query)
+func (d DatabaseStoreMock) InvalidateTablesMetadata(groupName string, groupType string) error {
+	return d.InvalidateTablesMetadataFunc(groupName, groupType)
 }
 
-func (d DatabaseStoreMock) QueryMultipleDatabases(ctx context.Context, groupType string, query string) []GroupQueryResult {
-	return d.QueryMultipleDatabasesFunc(ctx, groupType, query)
+func (d DatabaseStoreMock) QueryDatabase(ctx context.Context, groupName string, groupType string, query string, options QueryOptions) GroupQueryResult {
+	return d.QueryDatabaseFunc(ctx, groupName, groupType, query, options)
+}
+
+func (d DatabaseStoreMock) QueryMultipleDatabases(ctx context.Context, groupType string, query string, options QueryOptions) []GroupQueryResult {
+	return d.QueryMultipleDatabasesFunc(ctx, groupType, query, options)
 }
 
 func (d DatabaseStoreMock) GetDatabaseItems() []DatabaseItem {
 	return d.GetDatabaseItemsFunc()
 }
+
+func (d DatabaseStoreMock) DiffDatabases(ctx context.Context, groupType string, groupNames []string, query string, keyColumns []string, role string) DiffResult {
+	return d.DiffDatabasesFunc(ctx, groupType, groupNames, query, keyColumns, role)
+}
+
+func (d DatabaseStoreMock) GetSchemaDrift(ctx context.Context, groupType string, baseGroupName string) (SchemaDriftReport, error) {
+	return d.GetSchemaDriftFunc(ctx, groupType, baseGroupName)
+}
+
+func (d DatabaseStoreMock) PlanWrite(ctx context.Context, groupType string, groupNames []string, query string, previewQuery string, role string) (WritePlan, error) {
+	return d.PlanWriteFunc(ctx, groupType, groupNames, query, previewQuery, role)
+}
+
+func (d DatabaseStoreMock) ConfirmWrite(ctx context.Context, token string, tolerancePercent float64) (WriteResult, error) {
+	return d.ConfirmWriteFunc(ctx, token, tolerancePercent)
+}
+
+func (d DatabaseStoreMock) GetMigrationStatus(ctx context.Context, groupType string, groupNames []string) (MigrationStatus, error) {
+	return d.GetMigrationStatusFunc(ctx, groupType, groupNames)
+}
+
+func (d DatabaseStoreMock) Migrate(ctx context.Context, groupType string, direction string, options MigrationOptions) (MigrationResult, error) {
+	return d.MigrateFunc(ctx, groupType, direction, options)
+}
+
+func (d DatabaseStoreMock) ExplainQuery(ctx context.Context, groupType string, groupNames []string, query string) ([]ExplainResult, error) {
+	return d.ExplainQueryFunc(ctx, groupType, groupNames, query)
+}
@@ -5,9 +5,11 @@ import (
 	stdjson "encoding/json"
 	"fmt"
 	goJson "github.com/goccy/go-json"
+	"github.com/jmoiron/sqlx"
 	iterJson "github.com/json-iterator/go"
 	"github.com/segmentio/encoding/json"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -38,7 +40,10 @@ func initDatabaseStore() *DatabaseStore {
 		panic(fmt.Sprintf("failed to read cfg. %v", err))
 	}
 
-	databaseStore := NewDatabaseStore()
+	databaseStore, err := NewDatabaseStore(Config{})
+	if err != nil {
+		panic(fmt.Sprintf("failed to create database store. %v", err))
+	}
 	databaseStore.AddDatabases(cfg.DatabaseConfigs)
 	databaseConfigs, errs := GetDatabaseConfigsFromDataSources(cfg.DataSources)
 	if len(errs) > 0 {
@@ -49,12 +54,12 @@ func initDatabaseStore() *DatabaseStore {
 }
 
 func initData(dataStore *DatabaseStore, groupType string) {
-	dataStore.QueryMultipleDatabases(context.Background(), groupType, benchPrepareSchema)
-	dataStore.QueryMultipleDatabases(context.Background(), groupType, benchGenerateData)
+	dataStore.QueryMultipleDatabases(context.Background(), groupType, benchPrepareSchema, QueryOptions{})
+	dataStore.QueryMultipleDatabases(context.Background(), groupType, benchGenerateData, QueryOptions{})
 }
 
 func clearData(dataStore *DatabaseStore, groupType string) {
-	dataStore.QueryMultipleDatabases(context.Background(), groupType, benchClearSchema)
+	dataStore.QueryMultipleDatabases(context.Background(), groupType, benchClearSchema, QueryOptions{})
 }
 
 func BenchmarkEncodeJson(b *testing.B) {
@@ -62,7 +67,7 @@ func BenchmarkEncodeJson(b *testing.B) {
 	initData(databaseStore, benchGroupType)
 	defer clearData(databaseStore, benchGroupType)
 
-	data := databaseStore.QueryMultipleDatabases(context.Background(), benchGroupType, benchQuery)
+	data := databaseStore.QueryMultipleDatabases(context.Background(), benchGroupType, benchQuery, QueryOptions{})
 	b.ResetTimer()
 	b.Run("segmentio/encoding/json", func(b *testing.B) {
 		b.ResetTimer()
@@ -105,7 +110,7 @@ func BenchmarkQuery(b *testing.B) {
 
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
-		result := databaseStore.QueryMultipleDatabases(context.Background(), benchGroupType, benchQuery)
+		result := databaseStore.QueryMultipleDatabases(context.Background(), benchGroupType, benchQuery, QueryOptions{})
 		if len(result) > -1 {
 			continue
 		}
@@ -163,3 +168,74 @@ func Test_getFieldNames(t *testing.T) {
 		})
 	}
 }
+
+func Test_executeQuery(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlx.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close() //nolint:errcheck
+	conn, err := db.Connx(ctx)
+	require.NoError(t, err)
+	defer conn.Close() //nolint:errcheck
+
+	results, err := executeQuery(ctx, conn, "sqlite", `
+		create table fruit (id integer, name text);
+		insert into fruit values (1, 'lemon'), (2, 'pear');
+		select name from fruit order by id;
+		update fruit set name = 'apple' where id = 2;
+		select count(*) as cnt from fruit`)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	var statementIndexes []int
+	for _, result := range results {
+		statementIndexes = append(statementIndexes, result.StatementIndex)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, statementIndexes)
+	assert.Equal(t, int64(2), *results[1].RowsAffected)
+	assert.Nil(t, results[2].RowsAffected)
+	assert.Equal(t, []map[string]any{{"name": "lemon"}, {"name": "pear"}}, results[2].Rows)
+	assert.Equal(t, int64(1), *results[3].RowsAffected)
+	assert.Equal(t, StatementKindUpdate, results[3].StatementKind)
+	assert.Equal(t, results[4], primaryResult(results))
+
+	results, err = executeQuery(ctx, conn, "sqlite", "select 1 as a; select * from missing; select 2 as b")
+	assert.ErrorContains(t, err, "statement 2 failed")
+	require.Len(t, results, 1)
+	assert.Equal(t, []map[string]any{{"a": int64(1)}}, results[0].Rows)
+
+	results, err = executeQuery(ctx, conn, "sqlite", "update fruit set name = 'plum' returning id")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Len(t, results[0].Rows, 2)
+	require.NotNil(t, results[0].RowsAffected)
+	assert.Equal(t, int64(2), *results[0].RowsAffected)
+
+	// query of comments only is not sent as is
+	results, err = executeQuery(ctx, conn, "sqlite", "-- select 1")
+	assert.EqualError(t, err, "query contains no statements")
+	assert.Empty(t, results)
+}
+
+func Test_primaryResult(t *testing.T) {
+	selected := &QueryData{Columns: []Column{{Name: "a", FieldName: "a"}}}
+	updated := &QueryData{Columns: []Column{}}
+	assert.Equal(t, selected, primaryResult([]*QueryData{selected, updated}))
+	assert.Equal(t, updated, primaryResult([]*QueryData{updated, updated}))
+	assert.Nil(t, primaryResult(nil))
+}
+
+func Test_totalRowsAffected(t *testing.T) {
+	one, two := int64(1), int64(2)
+	assert.Nil(t, totalRowsAffected([]*QueryData{{}}))
+	assert.Equal(t, int64(3), *totalRowsAffected([]*QueryData{{RowsAffected: &one}, {}, {RowsAffected: &two}}))
+}
+
+func TestDatabaseStore_QueryMultipleDatabases_GroupNames(t *testing.T) {
+	s := newSqliteTestStore(t)
+	results := s.QueryMultipleDatabases(context.Background(), "t", "select count(*) as cnt from fruit",
+		QueryOptions{GroupNames: []string{"b"}})
+	require.Len(t, results, 1)
+	assert.Equal(t, "b", results[0].GroupName)
+	assert.Nil(t, results[0].Error)
+}
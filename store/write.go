@@ -0,0 +1,385 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"github.com/jmoiron/sqlx"
+	"github.com/minlau/mdb-tool/internal/utils/closer"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultWritePlanTTL is used if WriteConfig.PlanTTLInSeconds is not set.
+const defaultWritePlanTTL = 10 * time.Minute
+
+type WriteConfig struct {
+	// PlanTTLInSeconds is a time, during which write plan can be confirmed. Default: 600
+	PlanTTLInSeconds int
+}
+
+// WritePlan is a result of write dry run. Write is executed in every database and rolled back. Plan can be confirmed
+// by Token, if write succeeded in every database.
+type WritePlan struct {
+	// Token is empty if write failed in any database
+	Token        string              `json:"token"`
+	GroupType    string              `json:"groupType"`
+	Query        string              `json:"query"`
+	PreviewQuery string              `json:"previewQuery"`
+	Databases    []WritePlanDatabase `json:"databases"`
+	ExpiresAt    time.Time           `json:"expiresAt"`
+}
+
+type WritePlanDatabase struct {
+	GroupName string `json:"groupName"`
+	// RowsAffected is a sum of rows affected by all write statements
+	RowsAffected *int64 `json:"rowsAffected"`
+	// Preview is a result of preview query executed after write statements
+	Preview *QueryData  `json:"preview"`
+	Error   *QueryError `json:"error"`
+}
+
+// WriteResult is a result of write plan confirmation. Write is committed only if rows affected count of every database
+// is within tolerance of planned count, otherwise it is rolled back in every database.
+type WriteResult struct {
+	Token            string                `json:"token"`
+	TolerancePercent float64               `json:"tolerancePercent"`
+	Committed        bool                  `json:"committed"`
+	Databases        []WriteResultDatabase `json:"databases"`
+}
+
+type WriteResultDatabase struct {
+	GroupName           string      `json:"groupName"`
+	PlannedRowsAffected *int64      `json:"plannedRowsAffected"`
+	RowsAffected        *int64      `json:"rowsAffected"`
+	Committed           bool        `json:"committed"`
+	Error               *QueryError `json:"error"`
+}
+
+// writePlans keeps write plans until they are confirmed or expired.
+type writePlans struct {
+	m     sync.Mutex
+	ttl   time.Duration
+	plans map[string]WritePlan
+	now   func() time.Time
+}
+
+func newWritePlans(config WriteConfig) *writePlans {
+	ttl := time.Duration(config.PlanTTLInSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultWritePlanTTL
+	}
+	return &writePlans{ttl: ttl, plans: make(map[string]WritePlan), now: time.Now}
+}
+
+func (p *writePlans) add(plan WritePlan) (WritePlan, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return plan, errors.Wrap(err, "failed to generate plan token")
+	}
+	plan.Token = hex.EncodeToString(b)
+	plan.ExpiresAt = p.now().Add(p.ttl)
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	for token, existing := range p.plans {
+		if p.now().After(existing.ExpiresAt) {
+			delete(p.plans, token)
+		}
+	}
+	p.plans[plan.Token] = plan
+	return plan, nil
+}
+
+// take removes plan, so that it can be confirmed only once.
+func (p *writePlans) take(token string) (WritePlan, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	plan, ok := p.plans[token]
+	if !ok {
+		return plan, errors.Errorf("write plan not found: %s", token)
+	}
+	delete(p.plans, token)
+	if p.now().After(plan.ExpiresAt) {
+		return plan, errors.Errorf("write plan expired: %s", token)
+	}
+	return plan, nil
+}
+
+// validateWriteQuery allows only data modification statements, because DDL can not be rolled back by some
+// databases(i.e. mysql implicit commit).
+func validateWriteQuery(query string, sqlType string) error {
+	statements := splitStatements(query, sqlType)
+	if len(statements) == 0 {
+		return errors.New("write query is empty")
+	}
+	for i, s := range statements {
+		switch s.kind() {
+		case StatementKindInsert, StatementKindUpdate, StatementKindDelete, StatementKindMerge:
+		default:
+			return errors.Errorf("statement %d is not a data modification statement: %s", i+1, s.kind())
+		}
+	}
+	return nil
+}
+
+// validatePreviewQuery allows only SELECT statements without INTO, because preview is executed in the transaction of
+// planned write and DDL would commit it(i.e. mysql implicit commit).
+func validatePreviewQuery(query string, sqlType string) error {
+	for i, s := range splitStatements(query, sqlType) {
+		switch s.mainKeyword() {
+		case "SELECT", "VALUES", "TABLE":
+			if s.hasKeyword("INTO") {
+				return errors.Errorf("preview statement %d must not contain INTO", i+1)
+			}
+		default:
+			return errors.Errorf("preview statement %d is not a SELECT statement: %s", i+1, s.kind())
+		}
+	}
+	return nil
+}
+
+// PlanWrite executes write query and preview query in every database of group type(or given groups) in a
+// transaction and rolls it back. Plan token is returned if write succeeded in every database. Preview is masked for
+// caller role(see QueryOptions.Role).
+func (s *DatabaseStore) PlanWrite(ctx context.Context, groupType string, groupNames []string, query string,
+	previewQuery string, role string) (WritePlan, error) {
+	instances, err := s.groupDatabaseInstances(groupType, groupNames)
+	if err != nil {
+		return WritePlan{}, err
+	}
+	for _, instance := range instances {
+		err = validateWriteQuery(query, instance.Config.Type)
+		if err != nil {
+			return WritePlan{}, err
+		}
+		err = validatePreviewQuery(previewQuery, instance.Config.Type)
+		if err != nil {
+			return WritePlan{}, err
+		}
+	}
+
+	plan := WritePlan{
+		GroupType:    groupType,
+		Query:        query,
+		PreviewQuery: previewQuery,
+		Databases:    make([]WritePlanDatabase, len(instances)),
+	}
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance DatabaseInstance) {
+			defer wg.Done()
+			plan.Databases[i] = s.planDatabaseWrite(ctx, instance, query, previewQuery, role)
+		}(i, instance)
+	}
+	wg.Wait()
+
+	for _, database := range plan.Databases {
+		if database.Error != nil {
+			return plan, nil
+		}
+	}
+	return s.writePlans.add(plan)
+}
+
+func (s *DatabaseStore) planDatabaseWrite(ctx context.Context, instance DatabaseInstance, query string,
+	previewQuery string, role string) WritePlanDatabase {
+	result := WritePlanDatabase{GroupName: instance.Config.GroupName}
+	for _, q := range []string{query, previewQuery} {
+		// write plan is confirmed by ConfirmWrite, so destructive statements need no confirmation
+		violation := checkPolicies(s.policies, instance.Config.DatabaseGroup, instance.Config.Type, q, true)
+		if violation != nil {
+			result.Error = NewQueryError(violation)
+			return result
+		}
+	}
+	write, releaseSlot, err := s.beginTx(ctx, instance)
+	if err != nil {
+		result.Error = NewQueryError(err)
+		return result
+	}
+	defer releaseSlot()
+	defer write.rollback()
+
+	results, err := executeStatements(ctx, write.tx, instance.Config.Type, query)
+	if err != nil {
+		result.Error = NewQueryError(err)
+		return result
+	}
+	// confirmation compares rows affected counts, so write without counts can not be planned
+	for _, data := range results {
+		if data.RowsAffected == nil {
+			result.Error = NewQueryError(errors.Errorf("statement %d does not report rows affected count",
+				data.StatementIndex+1))
+			return result
+		}
+	}
+	result.RowsAffected = totalRowsAffected(results)
+
+	if previewQuery != "" {
+		preview, err := executeStatements(ctx, write.tx, instance.Config.Type, previewQuery)
+		if err != nil {
+			result.Error = NewQueryError(errors.Wrap(err, "failed to execute preview query"))
+			return result
+		}
+		s.masking.apply(instance.Config.DatabaseGroup, instance.Config.Type, previewQuery, QueryOptions{Role: role},
+			preview)
+		result.Preview = primaryResult(preview)
+	}
+	return result
+}
+
+// connTx is a transaction, which holds a dedicated connection until it is finished.
+type connTx struct {
+	conn *sqlx.Conn
+	tx   *sqlx.Tx
+}
+
+// commit commits transaction and releases connection.
+func (c *connTx) commit() error {
+	defer closer.Handle(c.conn, "database connection")
+	return c.tx.Commit()
+}
+
+// rollback rolls back transaction and releases connection.
+func (c *connTx) rollback() {
+	defer closer.Handle(c.conn, "database connection")
+	err := c.tx.Rollback()
+	if err != nil && err != sql.ErrTxDone {
+		log.Error().Err(err).Msg("failed to rollback transaction")
+	}
+}
+
+// ConfirmWrite executes planned write in every database and commits it, if rows affected count of every database is
+// within tolerance of planned count. Write is rolled back in every database otherwise. Plan can be confirmed only once.
+// Commits of databases are not atomic: if commit fails in some databases, write stays committed in others and result
+// is not Committed.
+func (s *DatabaseStore) ConfirmWrite(ctx context.Context, token string, tolerancePercent float64) (WriteResult, error) {
+	if tolerancePercent < 0 {
+		return WriteResult{}, errors.New("tolerance must not be negative")
+	}
+	plan, err := s.writePlans.take(token)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	instances := make([]DatabaseInstance, len(plan.Databases))
+	for i, database := range plan.Databases {
+		instance, ok := s.databases[DatabaseGroup{database.GroupName, plan.GroupType}]
+		if !ok {
+			return WriteResult{}, errors.Errorf("no database registered with groupName: %s, groupType: %s",
+				database.GroupName, plan.GroupType)
+		}
+		instances[i] = instance
+	}
+
+	result := WriteResult{
+		Token:            token,
+		TolerancePercent: tolerancePercent,
+		Databases:        make([]WriteResultDatabase, len(instances)),
+	}
+	pending := make([]*connTx, len(instances))
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance DatabaseInstance) {
+			defer wg.Done()
+			database := WriteResultDatabase{
+				GroupName:           instance.Config.GroupName,
+				PlannedRowsAffected: plan.Databases[i].RowsAffected,
+			}
+			var err error
+			pending[i], database.RowsAffected, err = s.executeWrite(ctx, instance, plan.Query)
+			if err == nil && !withinTolerance(database.PlannedRowsAffected, database.RowsAffected, tolerancePercent) {
+				err = errors.New("rows affected count differs from planned count more than tolerance")
+			}
+			database.Error = NewQueryError(err)
+			result.Databases[i] = database
+		}(i, instance)
+	}
+	wg.Wait()
+
+	commit := true
+	for _, database := range result.Databases {
+		if database.Error != nil {
+			commit = false
+		}
+	}
+	for i, write := range pending {
+		if write == nil {
+			continue
+		}
+		if !commit {
+			write.rollback()
+			continue
+		}
+		err := write.commit()
+		if err != nil {
+			result.Databases[i].Error = NewQueryError(errors.Wrap(err, "failed to commit"))
+		} else {
+			result.Databases[i].Committed = true
+		}
+	}
+	result.Committed = commit
+	for _, database := range result.Databases {
+		if !database.Committed {
+			result.Committed = false
+		}
+	}
+	return result, nil
+}
+
+// executeWrite executes write query in a transaction and returns it without committing. Query slot is released after
+// execution, so that waiting for other databases does not block queries.
+func (s *DatabaseStore) executeWrite(ctx context.Context, instance DatabaseInstance, query string) (*connTx,
+	*int64, error) {
+	write, releaseSlot, err := s.beginTx(ctx, instance)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer releaseSlot()
+	results, err := executeStatements(ctx, write.tx, instance.Config.Type, query)
+	if err != nil {
+		write.rollback()
+		return nil, nil, err
+	}
+	return write, totalRowsAffected(results), nil
+}
+
+// beginTx waits for a free query slot and begins a transaction. Returned transaction must be finished and slot
+// released by caller.
+func (s *DatabaseStore) beginTx(ctx context.Context, instance DatabaseInstance) (*connTx, func(), error) {
+	releaseSlot, _, err := s.limiter.acquire(ctx, hostKey(instance.Config.DatabaseConnConfig))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to wait for free query slot")
+	}
+
+	conn, err := instance.DB.Connx(ctx)
+	if err != nil {
+		releaseSlot()
+		return nil, nil, err
+	}
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		closer.Handle(conn, "database connection")
+		releaseSlot()
+		return nil, nil, err
+	}
+	return &connTx{conn: conn, tx: tx}, releaseSlot, nil
+}
+
+// withinTolerance returns true if actual count differs from planned count not more than tolerancePercent of planned
+// count. Counts must be equal if planned count is 0. Not reported count is never within tolerance.
+func withinTolerance(planned *int64, actual *int64, tolerancePercent float64) bool {
+	if planned == nil || actual == nil {
+		return false
+	}
+	difference := math.Abs(float64(*actual - *planned))
+	return difference <= float64(*planned)*tolerancePercent/100
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_withinTolerance(t *testing.T) {
+	ten, eleven, twelve, zero, one := int64(10), int64(11), int64(12), int64(0), int64(1)
+	tests := []struct {
+		name      string
+		planned   *int64
+		actual    *int64
+		tolerance float64
+		want      bool
+	}{
+		{name: "equal", planned: &ten, actual: &ten, tolerance: 0, want: true},
+		{name: "changed without tolerance", planned: &ten, actual: &eleven, tolerance: 0, want: false},
+		{name: "within tolerance", planned: &ten, actual: &eleven, tolerance: 10, want: true},
+		{name: "beyond tolerance", planned: &ten, actual: &twelve, tolerance: 10, want: false},
+		{name: "zero planned", planned: &zero, actual: &one, tolerance: 50, want: false},
+		{name: "not reported", planned: nil, actual: nil, tolerance: 0, want: false},
+		{name: "reported only once", planned: nil, actual: &one, tolerance: 0, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, withinTolerance(tt.planned, tt.actual, tt.tolerance))
+		})
+	}
+}
+
+func Test_validateWriteQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "update", query: "update t set a = 1"},
+		{name: "multiple statements", query: "delete from t where a = 1; insert into t values (2)"},
+		{name: "with", query: "with x as (select 1) update t set a = 1"},
+		{name: "select", query: "select * from t", wantErr: "statement 1 is not a data modification statement: select"},
+		{name: "ddl", query: "update t set a = 1; drop table t", wantErr: "statement 2 is not a data modification statement: ddl"},
+		{name: "empty", query: " ; ", wantErr: "write query is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWriteQuery(tt.query, "postgresql")
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validatePreviewQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "empty", query: ""},
+		{name: "select", query: "select * from t; with x as (select 1) select * from x"},
+		{name: "ddl", query: "select 1; create table x (a int)", wantErr: "preview statement 2 is not a SELECT statement: ddl"},
+		{name: "update", query: "update t set a = 1", wantErr: "preview statement 1 is not a SELECT statement: update"},
+		{name: "select into", query: "select * into x from t", wantErr: "preview statement 1 must not contain INTO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePreviewQuery(tt.query, "mysql")
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_writePlans(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	plans := newWritePlans(WriteConfig{PlanTTLInSeconds: 60})
+	plans.now = func() time.Time { return now }
+
+	plan, err := plans.add(WritePlan{GroupType: "t"})
+	require.NoError(t, err)
+	assert.Len(t, plan.Token, 32)
+	assert.Equal(t, now.Add(time.Minute), plan.ExpiresAt)
+
+	taken, err := plans.take(plan.Token)
+	require.NoError(t, err)
+	assert.Equal(t, plan, taken)
+	_, err = plans.take(plan.Token)
+	assert.EqualError(t, err, "write plan not found: "+plan.Token, "plan is confirmed only once")
+
+	plan, err = plans.add(WritePlan{GroupType: "t"})
+	require.NoError(t, err)
+	now = now.Add(61 * time.Second)
+	_, err = plans.take(plan.Token)
+	assert.EqualError(t, err, "write plan expired: "+plan.Token)
+}
+
+// newSqliteTestStore returns store with sqlite databases a and b of group type t. Both contain fruit table.
+func newSqliteTestStore(t *testing.T) *DatabaseStore {
+	s, err := NewDatabaseStore(Config{})
+	require.NoError(t, err)
+	for i, groupName := range []string{"a", "b"} {
+		db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), groupName+".db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() }) //nolint:errcheck
+		_, err = db.Exec(`create table fruit (id integer, name text);
+			insert into fruit values (1, 'lemon'), (2, 'pear'), (3, 'apple')`)
+		require.NoError(t, err)
+
+		config := DatabaseConfig{
+			DatabaseGroup:      DatabaseGroup{GroupName: groupName, GroupType: "t"},
+			DatabaseConnConfig: DatabaseConnConfig{Type: "sqlite"},
+		}
+		s.databases[config.DatabaseGroup] = DatabaseInstance{Config: config, DB: db, order: i}
+	}
+	return s
+}
+
+func countFruits(t *testing.T, s *DatabaseStore, groupName string, name string) int {
+	var count int
+	err := s.databases[DatabaseGroup{GroupName: groupName, GroupType: "t"}].DB.Get(&count,
+		"select count(*) from fruit where name = ?", name)
+	require.NoError(t, err)
+	return count
+}
+
+func TestDatabaseStore_PlanWrite(t *testing.T) {
+	ctx := context.Background()
+	s := newSqliteTestStore(t)
+
+	plan, err := s.PlanWrite(ctx, "t", nil, "update fruit set name = 'plum' where id < 3",
+		"select count(*) as cnt from fruit where name = 'plum'", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, plan.Token)
+	require.Len(t, plan.Databases, 2)
+	for _, database := range plan.Databases {
+		assert.Nil(t, database.Error)
+		assert.Equal(t, int64(2), *database.RowsAffected)
+		assert.Equal(t, []map[string]any{{"cnt": int64(2)}}, database.Preview.Rows)
+	}
+	assert.Equal(t, 0, countFruits(t, s, "a", "plum"), "dry run is rolled back")
+
+	result, err := s.ConfirmWrite(ctx, plan.Token, 0)
+	require.NoError(t, err)
+	assert.True(t, result.Committed)
+	for _, database := range result.Databases {
+		assert.Nil(t, database.Error)
+		assert.True(t, database.Committed)
+		assert.Equal(t, int64(2), *database.RowsAffected)
+	}
+	assert.Equal(t, 2, countFruits(t, s, "a", "plum"))
+	assert.Equal(t, 2, countFruits(t, s, "b", "plum"))
+
+	_, err = s.ConfirmWrite(ctx, plan.Token, 0)
+	assert.Error(t, err, "plan is confirmed only once")
+}
+
+func TestDatabaseStore_ConfirmWrite_CountChanged(t *testing.T) {
+	ctx := context.Background()
+	s := newSqliteTestStore(t)
+
+	plan, err := s.PlanWrite(ctx, "t", nil, "update fruit set name = 'plum' where name = 'lemon'", "", "")
+	require.NoError(t, err)
+	_, err = s.databases[DatabaseGroup{GroupName: "b", GroupType: "t"}].DB.Exec(
+		"update fruit set name = 'lemon' where id = 2")
+	require.NoError(t, err)
+
+	result, err := s.ConfirmWrite(ctx, plan.Token, 50)
+	require.NoError(t, err)
+	assert.False(t, result.Committed)
+	assert.Nil(t, result.Databases[0].Error)
+	assert.False(t, result.Databases[0].Committed)
+	assert.NotNil(t, result.Databases[1].Error)
+	assert.Equal(t, int64(2), *result.Databases[1].RowsAffected)
+	assert.Equal(t, 0, countFruits(t, s, "a", "plum"), "write is rolled back in every database")
+	assert.Equal(t, 0, countFruits(t, s, "b", "plum"))
+}
+
+func TestDatabaseStore_ConfirmWrite_ReturningCountChanged(t *testing.T) {
+	ctx := context.Background()
+	s := newSqliteTestStore(t)
+
+	plan, err := s.PlanWrite(ctx, "t", []string{"a"}, "delete from fruit where name = 'lemon' returning id", "", "")
+	require.NoError(t, err)
+	require.NotEmpty(t, plan.Token)
+	require.NotNil(t, plan.Databases[0].RowsAffected)
+	assert.Equal(t, int64(1), *plan.Databases[0].RowsAffected)
+	_, err = s.databases[DatabaseGroup{GroupName: "a", GroupType: "t"}].DB.Exec(
+		"update fruit set name = 'lemon' where id = 2")
+	require.NoError(t, err)
+
+	result, err := s.ConfirmWrite(ctx, plan.Token, 0)
+	require.NoError(t, err)
+	assert.False(t, result.Committed, "count of RETURNING statement is checked")
+	assert.NotNil(t, result.Databases[0].Error)
+	assert.Equal(t, int64(2), *result.Databases[0].RowsAffected)
+}
+
+func TestDatabaseStore_PlanWrite_Failed(t *testing.T) {
+	ctx := context.Background()
+	s := newSqliteTestStore(t)
+
+	plan, err := s.PlanWrite(ctx, "t", []string{"b"}, "update missing set name = 'plum'", "", "")
+	require.NoError(t, err)
+	assert.Empty(t, plan.Token, "failed plan can not be confirmed")
+	require.Len(t, plan.Databases, 1)
+	assert.Equal(t, "b", plan.Databases[0].GroupName)
+	assert.NotNil(t, plan.Databases[0].Error)
+
+	_, err = s.PlanWrite(ctx, "t", nil, "drop table fruit", "", "")
+	assert.Error(t, err)
+	_, err = s.PlanWrite(ctx, "t", []string{"c"}, "delete from fruit", "", "")
+	assert.Error(t, err)
+}
@@ -1,18 +1,46 @@
 package web
 
 import (
+	"github.com/minlau/mdb-tool/export"
+	"github.com/minlau/mdb-tool/internal/utils/list"
 	"github.com/minlau/mdb-tool/render"
+	"github.com/minlau/mdb-tool/scheduler"
 	"github.com/minlau/mdb-tool/store"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	// sheetsMerged exports all groups results as a single sheet
+	sheetsMerged = "merged"
+	// sheetsGroup exports every group result as a separate sheet
+	sheetsGroup = "group"
+)
+
+// roleHeader is a caller role used by masking rules. It must be set by an authenticating proxy, mdb-tool does not
+// authenticate callers.
+const roleHeader = "X-Mdb-Role"
+
 type queryRequest struct {
 	GroupName *string
 	GroupType string
 	Query     string
+	Order     store.ResultOrder
+	Merge     bool
+	Confirm   bool
+	Aggregate string
+	Schema    string
+	Format    string
+	Sheets    string
+	Encoding  store.EncodingOptions
 }
 
-func query(store store.DatabaseStoreI) http.HandlerFunc {
+func query(databaseStore store.DatabaseStoreI) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req queryRequest
 		groupNameString := r.URL.Query().Get("groupName")
@@ -32,22 +60,425 @@ func query(store store.DatabaseStoreI) http.HandlerFunc {
 			return
 		}
 
-		var res any
+		req.Order.By = r.URL.Query().Get("orderBy")
+		if !store.IsValidOrderBy(req.Order.By) {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "orderBy must be one of: groupName, config, label"})
+			return
+		}
+		req.Order.GroupNames = list.Split(r.URL.Query().Get("groupOrder"))
+
+		if mergeString := r.URL.Query().Get("merge"); mergeString != "" {
+			merge, err := strconv.ParseBool(mergeString)
+			if err != nil {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "merge must be a boolean"})
+				return
+			}
+			req.Merge = merge
+		}
+		if confirmString := r.URL.Query().Get("confirm"); confirmString != "" {
+			confirm, err := strconv.ParseBool(confirmString)
+			if err != nil {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "confirm must be a boolean"})
+				return
+			}
+			req.Confirm = confirm
+		}
+		req.Aggregate = r.URL.Query().Get("aggregate")
+		req.Schema = r.URL.Query().Get("schema")
+
+		req.Format = r.URL.Query().Get("format")
+		if req.Format != "" && req.Format != "json" && !export.IsValidFormat(req.Format) {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "format must be one of: json, csv, tsv, xlsx, parquet, arrow"})
+			return
+		}
+		if req.Format == "json" {
+			req.Format = ""
+		}
+		req.Sheets = r.URL.Query().Get("sheets")
+		if req.Sheets == "" {
+			req.Sheets = sheetsMerged
+		}
+		if req.Sheets != sheetsMerged && req.Sheets != sheetsGroup {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "sheets must be one of: merged, group"})
+			return
+		}
+		if req.Sheets == sheetsGroup && !export.SupportsSheets(req.Format) {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "sheets=group is supported only by xlsx format"})
+			return
+		}
+
+		req.Encoding.Encoding = r.URL.Query().Get("encoding")
+		if !store.IsValidEncoding(req.Encoding.Encoding) {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "encoding must be one of: default, lossless"})
+			return
+		}
+		req.Encoding.BinaryEncoding = r.URL.Query().Get("binaryEncoding")
+		if !store.IsValidBinaryEncoding(req.Encoding.BinaryEncoding) {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "binaryEncoding must be one of: base64, hex"})
+			return
+		}
+
+		options := store.QueryOptions{Order: req.Order, Schema: req.Schema, Role: r.Header.Get(roleHeader),
+			Confirm: req.Confirm}
+
+		var results []store.GroupQueryResult
 		if req.GroupName == nil {
-			res = store.QueryMultipleDatabases(r.Context(), req.GroupType, req.Query)
+			results = databaseStore.QueryMultipleDatabases(r.Context(), req.GroupType, req.Query, options)
+		} else {
+			result := databaseStore.QueryDatabase(r.Context(), *req.GroupName, req.GroupType, req.Query, options)
+			if !req.Merge && req.Aggregate == "" && req.Format == "" {
+				encodeGroupQueryResult(result, req.Encoding)
+				render.JSON(w, http.StatusOK, result)
+				return
+			}
+			results = []store.GroupQueryResult{result}
+		}
+
+		if req.Aggregate != "" {
+			res, err := store.AggregateGroupQueryResults(r.Context(), results, req.Aggregate)
+			if err != nil {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+				return
+			}
+			if req.Format != "" {
+				renderExport(w, req.Format, fileName(req), []export.Sheet{{Name: "results", Data: res.Data}}, res.Errors)
+				return
+			}
+			store.EncodeQueryData(res.Data, req.Encoding)
+			render.JSON(w, http.StatusOK, res)
+			return
+		}
+		if req.Format != "" {
+			merged := store.MergeGroupQueryResults(results)
+			sheets := []export.Sheet{{Name: "results", Data: merged.Data}}
+			if req.Sheets == sheetsGroup {
+				sheets = sheets[:0]
+				for _, result := range results {
+					if result.Data != nil {
+						data := store.MergeGroupQueryResults([]store.GroupQueryResult{result}).Data
+						sheets = append(sheets, export.Sheet{Name: result.GroupName, Data: data})
+					}
+				}
+			}
+			renderExport(w, req.Format, fileName(req), sheets, merged.Errors)
+			return
+		}
+		if req.Merge {
+			merged := store.MergeGroupQueryResults(results)
+			store.EncodeQueryData(merged.Data, req.Encoding)
+			render.JSON(w, http.StatusOK, merged)
+			return
+		}
+		for _, result := range results {
+			encodeGroupQueryResult(result, req.Encoding)
+		}
+		render.JSON(w, http.StatusOK, results)
+	}
+}
+
+// encodeGroupQueryResult encodes every statement result of group once. Data is usually one of Results and lossless
+// encoding can not be applied repeatedly.
+func encodeGroupQueryResult(result store.GroupQueryResult, options store.EncodingOptions) {
+	for _, data := range result.Results {
+		store.EncodeQueryData(data, options)
+	}
+	for _, data := range result.Results {
+		if data == result.Data {
+			return
+		}
+	}
+	store.EncodeQueryData(result.Data, options)
+}
+
+func fileName(req queryRequest) string {
+	if req.GroupName != nil {
+		return *req.GroupName + "_" + req.GroupType + "." + req.Format
+	}
+	return req.GroupType + "." + req.Format
+}
+
+// renderExport streams results as a downloadable file. Failed groups are listed in a separate sheet, if format
+// supports sheets, otherwise in X-Failed-Groups header.
+func renderExport(w http.ResponseWriter, format string, fileName string, sheets []export.Sheet, groupErrors []store.GroupError) {
+	if len(groupErrors) > 0 {
+		if export.SupportsSheets(format) {
+			sheets = append(sheets, export.GroupErrorsSheet(groupErrors))
 		} else {
-			res = store.QueryDatabase(r.Context(), *req.GroupName, req.GroupType, req.Query)
+			groupNames := make([]string, 0, len(groupErrors))
+			for _, groupError := range groupErrors {
+				groupNames = append(groupNames, groupError.GroupName)
+			}
+			w.Header().Set("X-Failed-Groups", strings.Join(groupNames, ","))
+		}
+	}
+	if len(sheets) == 0 {
+		sheets = append(sheets, export.Sheet{Name: "results", Data: &store.QueryData{}})
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.WriteHeader(http.StatusOK)
+	err := export.Write(w, format, sheets)
+	if err != nil {
+		log.Error().Err(err).Str("format", format).Msg("failed to write export")
+	}
+}
+
+type diffRequest struct {
+	GroupType  string
+	GroupNames []string
+	Query      string
+	KeyColumns []string
+}
+
+func diff(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req diffRequest
+		req.Query = r.URL.Query().Get("query")
+		if req.Query == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "query is required"})
+			return
+		}
+
+		req.GroupType = r.URL.Query().Get("groupType")
+		if req.GroupType == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "groupType is required"})
+			return
+		}
+
+		req.GroupNames = list.Split(r.URL.Query().Get("groupNames"))
+		if len(req.GroupNames) < 2 {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "at least two groupNames are required"})
+			return
+		}
+
+		req.KeyColumns = list.Split(r.URL.Query().Get("keyColumns"))
+		if len(req.KeyColumns) == 0 {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "keyColumns is required"})
+			return
+		}
+
+		render.JSON(w, http.StatusOK, databaseStore.DiffDatabases(r.Context(), req.GroupType, req.GroupNames, req.Query,
+			req.KeyColumns, r.Header.Get(roleHeader)))
+	}
+}
+
+type planWriteRequest struct {
+	GroupType    string
+	GroupNames   []string
+	Query        string
+	PreviewQuery string
+}
+
+// planWrite executes write in every database and rolls it back. Returned plan token is used to confirm write.
+func planWrite(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req planWriteRequest
+		req.Query = r.URL.Query().Get("query")
+		if req.Query == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "query is required"})
+			return
+		}
+
+		req.GroupType = r.URL.Query().Get("groupType")
+		if req.GroupType == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "groupType is required"})
+			return
+		}
+
+		req.GroupNames = list.Split(r.URL.Query().Get("groupNames"))
+		req.PreviewQuery = r.URL.Query().Get("preview")
+
+		plan, err := databaseStore.PlanWrite(r.Context(), req.GroupType, req.GroupNames, req.Query, req.PreviewQuery,
+			r.Header.Get(roleHeader))
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		for _, database := range plan.Databases {
+			store.EncodeQueryData(database.Preview, store.EncodingOptions{})
+		}
+		render.JSON(w, http.StatusOK, plan)
+	}
+}
+
+type confirmWriteRequest struct {
+	Token            string
+	TolerancePercent float64
+}
+
+func confirmWrite(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req confirmWriteRequest
+		req.Token = r.URL.Query().Get("token")
+		if req.Token == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "token is required"})
+			return
+		}
+
+		if toleranceString := r.URL.Query().Get("tolerancePercent"); toleranceString != "" {
+			tolerance, err := strconv.ParseFloat(toleranceString, 64)
+			if err != nil || tolerance < 0 {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "tolerancePercent must be a non-negative number"})
+				return
+			}
+			req.TolerancePercent = tolerance
+		}
+
+		result, err := databaseStore.ConfirmWrite(r.Context(), req.Token, req.TolerancePercent)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, result)
+	}
+}
+
+type explainRequest struct {
+	GroupType  string
+	GroupNames []string
+	Query      string
+}
+
+// explain returns query plans of multiple databases side by side.
+func explain(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req explainRequest
+		req.Query = r.URL.Query().Get("query")
+		if req.Query == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "query is required"})
+			return
+		}
+
+		req.GroupType = r.URL.Query().Get("groupType")
+		if req.GroupType == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "groupType is required"})
+			return
+		}
+
+		req.GroupNames = list.Split(r.URL.Query().Get("groupNames"))
+
+		results, err := databaseStore.ExplainQuery(r.Context(), req.GroupType, req.GroupNames, req.Query)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, results)
+	}
+}
+
+func getMigrationStatus(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupType := r.URL.Query().Get("groupType")
+		if groupType == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "groupType is required"})
+			return
+		}
+
+		status, err := databaseStore.GetMigrationStatus(r.Context(), groupType,
+			list.Split(r.URL.Query().Get("groupNames")))
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, status)
+	}
+}
+
+type migrateRequest struct {
+	GroupType string
+	Options   store.MigrationOptions
+}
+
+func migrateUp(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return migrate(databaseStore, store.MigrationDirectionUp)
+}
+
+func migrateDown(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return migrate(databaseStore, store.MigrationDirectionDown)
+}
+
+// migrate applies(up) or reverts(down) migrations of group type databases.
+func migrate(databaseStore store.DatabaseStoreI, direction string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req migrateRequest
+		req.GroupType = r.URL.Query().Get("groupType")
+		if req.GroupType == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "groupType is required"})
+			return
+		}
+
+		req.Options.GroupNames = list.Split(r.URL.Query().Get("groupNames"))
+		if stepsString := r.URL.Query().Get("steps"); stepsString != "" {
+			steps, err := strconv.Atoi(stepsString)
+			if err != nil || steps < 0 {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "steps must be a non-negative integer"})
+				return
+			}
+			req.Options.Steps = steps
+		}
+
+		result, err := databaseStore.Migrate(r.Context(), req.GroupType, direction, req.Options)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, result)
+	}
+}
+
+type schemaDriftRequest struct {
+	GroupType     string
+	BaseGroupName string
+	Format        string
+}
+
+func getSchemaDrift(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req schemaDriftRequest
+		req.GroupType = r.URL.Query().Get("groupType")
+		if req.GroupType == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "groupType is required"})
+			return
+		}
+		req.BaseGroupName = r.URL.Query().Get("baseGroupName")
+
+		req.Format = r.URL.Query().Get("format")
+		if req.Format != "" && req.Format != "json" && req.Format != "text" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "format must be one of: json, text"})
+			return
+		}
+
+		report, err := databaseStore.GetSchemaDrift(r.Context(), req.GroupType, req.BaseGroupName)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
 		}
-		render.JSON(w, http.StatusOK, res)
+
+		if req.Format == "text" {
+			render.Text(w, http.StatusOK, report.Text())
+			return
+		}
+		render.JSON(w, http.StatusOK, report)
 	}
 }
 
 type tablesMetadataRequest struct {
 	GroupName string
 	GroupType string
+	Schemas   []string
+}
+
+func getTablesMetadata(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return tablesMetadata(databaseStore, false)
 }
 
-func getTablesMetadata(store store.DatabaseStoreI) http.HandlerFunc {
+// refreshTablesMetadata invalidates cached tables metadata and returns freshly fetched one.
+func refreshTablesMetadata(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return tablesMetadata(databaseStore, true)
+}
+
+func tablesMetadata(databaseStore store.DatabaseStoreI, refresh bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req tablesMetadataRequest
 		req.GroupName = r.URL.Query().Get("groupName")
@@ -62,7 +493,17 @@ func getTablesMetadata(store store.DatabaseStoreI) http.HandlerFunc {
 			return
 		}
 
-		data, err := store.GetTablesMetadata(req.GroupName, req.GroupType)
+		req.Schemas = list.Split(r.URL.Query().Get("schemas"))
+
+		if refresh {
+			err := databaseStore.InvalidateTablesMetadata(req.GroupName, req.GroupType)
+			if err != nil {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+				return
+			}
+		}
+
+		data, err := databaseStore.GetTablesMetadata(r.Context(), req.GroupName, req.GroupType, req.Schemas)
 		if err != nil {
 			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
 			return
@@ -72,8 +513,213 @@ func getTablesMetadata(store store.DatabaseStoreI) http.HandlerFunc {
 	}
 }
 
-func getDatabases(store store.DatabaseStoreI) http.HandlerFunc {
+func getDatabases(databaseStore store.DatabaseStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, http.StatusOK, databaseStore.GetDatabaseItems())
+	}
+}
+
+const (
+	defaultRunsLimit = 50
+	maxRunsLimit     = 1000
+)
+
+func getScheduledQueries(queryScheduler scheduler.SchedulerI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if queryScheduler == nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "scheduler is not configured"})
+			return
+		}
+		render.JSON(w, http.StatusOK, queryScheduler.Queries())
+	}
+}
+
+func runScheduledQuery(queryScheduler scheduler.SchedulerI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if queryScheduler == nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "scheduler is not configured"})
+			return
+		}
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "name is required"})
+			return
+		}
+
+		run, err := queryScheduler.Run(r.Context(), name)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, run)
+	}
+}
+
+func getScheduledQueryRuns(queryScheduler scheduler.SchedulerI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if queryScheduler == nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "scheduler is not configured"})
+			return
+		}
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "name is required"})
+			return
+		}
+
+		before := time.Now()
+		if beforeString := r.URL.Query().Get("before"); beforeString != "" {
+			var err error
+			before, err = time.Parse(time.RFC3339, beforeString)
+			if err != nil {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "before must be a RFC3339 time"})
+				return
+			}
+		}
+
+		limit := defaultRunsLimit
+		if limitString := r.URL.Query().Get("limit"); limitString != "" {
+			var err error
+			limit, err = strconv.Atoi(limitString)
+			if err != nil || limit <= 0 || limit > maxRunsLimit {
+				render.JSON(w, http.StatusBadRequest, render.M{
+					"error": "limit must be a positive integer not greater than " + strconv.Itoa(maxRunsLimit),
+				})
+				return
+			}
+		}
+
+		runs, err := queryScheduler.Runs(r.Context(), name, before, limit)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, runs)
+	}
+}
+
+// getScheduledQuerySnapshot returns run results by id or the latest run of name at given time.
+func getScheduledQuerySnapshot(queryScheduler scheduler.SchedulerI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if queryScheduler == nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "scheduler is not configured"})
+			return
+		}
+
+		var snapshot scheduler.Snapshot
+		var err error
+		if idString := r.URL.Query().Get("id"); idString != "" {
+			id, parseErr := strconv.ParseInt(idString, 10, 64)
+			if parseErr != nil {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "id must be an integer"})
+				return
+			}
+			snapshot, err = queryScheduler.Snapshot(r.Context(), id)
+		} else {
+			name := r.URL.Query().Get("name")
+			if name == "" {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "id or name is required"})
+				return
+			}
+			at := time.Now()
+			if atString := r.URL.Query().Get("at"); atString != "" {
+				at, err = time.Parse(time.RFC3339, atString)
+				if err != nil {
+					render.JSON(w, http.StatusBadRequest, render.M{"error": "at must be a RFC3339 time"})
+					return
+				}
+			}
+			snapshot, err = queryScheduler.SnapshotAt(r.Context(), name, at)
+		}
+		if errors.Is(err, scheduler.ErrRunNotFound) {
+			render.JSON(w, http.StatusNotFound, render.M{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, snapshot)
+	}
+}
+
+func getAlerts(queryScheduler scheduler.SchedulerI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if queryScheduler == nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "scheduler is not configured"})
+			return
+		}
+		alerts, err := queryScheduler.Alerts(r.Context())
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, alerts)
+	}
+}
+
+func getSilences(queryScheduler scheduler.SchedulerI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if queryScheduler == nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "scheduler is not configured"})
+			return
+		}
+		silences, err := queryScheduler.Silences(r.Context())
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, silences)
+	}
+}
+
+func addSilence(queryScheduler scheduler.SchedulerI) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		render.JSON(w, http.StatusOK, store.GetDatabaseItems())
+		if queryScheduler == nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "scheduler is not configured"})
+			return
+		}
+		silence := scheduler.Silence{
+			Rule:      r.URL.Query().Get("rule"),
+			GroupName: r.URL.Query().Get("groupName"),
+			Comment:   r.URL.Query().Get("comment"),
+		}
+		if startsAtString := r.URL.Query().Get("startsAt"); startsAtString != "" {
+			var err error
+			silence.StartsAt, err = time.Parse(time.RFC3339, startsAtString)
+			if err != nil {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "startsAt must be a RFC3339 time"})
+				return
+			}
+		}
+		if endsAtString := r.URL.Query().Get("endsAt"); endsAtString != "" {
+			var err error
+			silence.EndsAt, err = time.Parse(time.RFC3339, endsAtString)
+			if err != nil {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "endsAt must be a RFC3339 time"})
+				return
+			}
+		} else if durationString := r.URL.Query().Get("durationInMinutes"); durationString != "" {
+			duration, err := strconv.Atoi(durationString)
+			if err != nil || duration <= 0 {
+				render.JSON(w, http.StatusBadRequest, render.M{"error": "durationInMinutes must be a positive integer"})
+				return
+			}
+			startsAt := silence.StartsAt
+			if startsAt.IsZero() {
+				startsAt = time.Now()
+			}
+			silence.EndsAt = startsAt.Add(time.Duration(duration) * time.Minute)
+		} else {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": "endsAt or durationInMinutes is required"})
+			return
+		}
+
+		silence, err := queryScheduler.AddSilence(r.Context(), silence)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, render.M{"error": err.Error()})
+			return
+		}
+		render.JSON(w, http.StatusOK, silence)
 	}
 }
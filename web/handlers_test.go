@@ -2,7 +2,10 @@ package web
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/minlau/mdb-tool/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -20,7 +23,7 @@ func BenchmarkRequest(b *testing.B) {
 	}
 
 	databaseStore := &store.DatabaseStoreMock{
-		QueryMultipleDatabasesFunc: func(ctx context.Context, groupType string, query string) []store.GroupQueryResult {
+		QueryMultipleDatabasesFunc: func(ctx context.Context, groupType string, query string, options store.QueryOptions) []store.GroupQueryResult {
 			return []store.GroupQueryResult{
 				{
 					GroupName: "bench1",
@@ -61,3 +64,67 @@ func BenchmarkRequest(b *testing.B) {
 		}
 	}
 }
+
+func TestQuery_encodesEveryStatementResult(t *testing.T) {
+	newResult := func() store.GroupQueryResult {
+		first := &store.QueryData{
+			Columns: []store.Column{{Name: "a", FieldName: "a", Type: &store.ColumnType{LogicalType: store.LogicalTypeBinary}}},
+			Rows:    []map[string]any{{"a": []byte{1, 2}}},
+		}
+		second := &store.QueryData{
+			Columns:        []store.Column{{Name: "b", FieldName: "b", Type: &store.ColumnType{LogicalType: store.LogicalTypeBinary}}},
+			Rows:           []map[string]any{{"b": []byte{3, 4}}},
+			StatementIndex: 1,
+		}
+		return store.GroupQueryResult{GroupName: "a", Data: second, Results: []*store.QueryData{first, second}}
+	}
+	databaseStore := &store.DatabaseStoreMock{
+		QueryDatabaseFunc: func(ctx context.Context, groupName string, groupType string, query string, options store.QueryOptions) store.GroupQueryResult {
+			return newResult()
+		},
+		QueryMultipleDatabasesFunc: func(ctx context.Context, groupType string, query string, options store.QueryOptions) []store.GroupQueryResult {
+			return []store.GroupQueryResult{newResult()}
+		},
+	}
+	want := []map[string]any{
+		{"a": map[string]any{"encoding": "hex", "data": "0102"}},
+		{"b": map[string]any{"encoding": "hex", "data": "0304"}},
+	}
+
+	for _, groupName := range []string{"", "a"} {
+		t.Run("groupName="+groupName, func(t *testing.T) {
+			q := url.Values{"groupType": {"t"}, "query": {"select a; select b"}, "encoding": {"lossless"},
+				"binaryEncoding": {"hex"}}
+			if groupName != "" {
+				q.Set("groupName", groupName)
+			}
+			rr := httptest.NewRecorder()
+			query(databaseStore).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/query?"+q.Encode(), nil))
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+			type groupResult struct {
+				Data    map[string]any   `json:"data"`
+				Results []map[string]any `json:"results"`
+			}
+			var results []groupResult
+			if groupName == "" {
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &results))
+			} else {
+				var result groupResult
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+				results = append(results, result)
+			}
+			require.Len(t, results, 1)
+			require.Len(t, results[0].Results, 2)
+			for i, data := range results[0].Results {
+				assert.Equal(t, []any{want[i]}, toAnySlice(data["rows"]))
+			}
+			assert.Equal(t, []any{want[1]}, toAnySlice(results[0].Data["rows"]))
+		})
+	}
+}
+
+func toAnySlice(value any) []any {
+	slice, _ := value.([]any)
+	return slice
+}
@@ -213,7 +213,7 @@ export default class QueryPanel extends Component {
             .then(response => {
                 if (response.status === 200) {
                     if (response.data !== null) {
-                        return response.data;
+                        return QueryPanel.toAutocompleteTables(response.data);
                     }
                 } else {
                     console.error("request error", response);
@@ -222,6 +222,24 @@ export default class QueryPanel extends Component {
             });
     }
 
+    //converts tables metadata to table name -> column names map used by autocomplete.
+    //tables are added by name and by schema qualified name
+    static toAutocompleteTables(metadata) {
+        let tables = {};
+        metadata.schemas.forEach(schema => {
+            schema.tables.forEach(table => {
+                let columns = table.columns.map(column => column.name);
+                if (tables[table.name] === undefined) {
+                    tables[table.name] = columns;
+                }
+                if (schema.name !== "") {
+                    tables[`${schema.name}.${table.name}`] = columns;
+                }
+            });
+        });
+        return tables;
+    }
+
     initDatabases() {
         axios.get('/databases')
             .then(response => {
@@ -285,7 +303,7 @@ export default class QueryPanel extends Component {
         }
     }
 
-    handleExecuteClick() {
+    handleExecuteClick(confirm = false) {
         const cancelTokenSource = axios.CancelToken.source();
         this.setState({executingQuery: true, requestCancel: cancelTokenSource, errors: []});
 
@@ -294,11 +312,14 @@ export default class QueryPanel extends Component {
         let reqParams = {
             groupName: (singleMode ? this.state.database.groupName : null),
             groupType: this.state.groupType,
-            query: (selection.length === 0 ? this.state.query : selection)
+            query: (selection.length === 0 ? this.state.query : selection),
+            confirm: (confirm ? true : null)
         };
 
-        this.queryHistory.addQuery(new Date(), this.state.groupMode, this.state.groupType, this.state.database,
-            reqParams.query);
+        if (!confirm) {
+            this.queryHistory.addQuery(new Date(), this.state.groupMode, this.state.groupType, this.state.database,
+                reqParams.query);
+        }
 
         axios.get('/query', {params: reqParams, cancelToken: cancelTokenSource.token})
             .then(response => {
@@ -380,6 +401,14 @@ export default class QueryPanel extends Component {
                     }
 
                     this.setState({data: data, errors: errors, executingQuery: false, requestCancel: null});
+
+                    //destructive statements of policies, which require confirmation, are executed again with confirm
+                    let notConfirmed = errors.find(e => e.code === "policyViolation"
+                        && e.err.reason === "destructiveNotConfirmed");
+                    if (!confirm && notConfirmed !== undefined
+                        && window.confirm(notConfirmed.message + ". Execute anyway?")) {
+                        this.handleExecuteClick(true);
+                    }
                 },
                 error => {
                     this.setState({
@@ -489,7 +518,7 @@ export default class QueryPanel extends Component {
                         {!executingQuery && <Button
                             className="query-control-elements-right"
                             disabled={queryExecutionDisabled}
-                            onClick={this.handleExecuteClick}
+                            onClick={() => this.handleExecuteClick()}
                             icon="play"
                             text="Execute"
                         />}
@@ -3,27 +3,45 @@ package web
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/minlau/mdb-tool/scheduler"
 	"github.com/minlau/mdb-tool/store"
 	"github.com/minlau/mdb-tool/web/ui"
 	"net/http"
 	"strings"
 )
 
-func New(databaseStore store.DatabaseStoreI) *chi.Mux {
+// New creates router of handlers. queryScheduler is nil if no queries are scheduled.
+func New(databaseStore store.DatabaseStoreI, queryScheduler scheduler.SchedulerI) *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.Compress(1))
 	r.Use(ZeroLogLogger)
 	r.Use(middleware.Recoverer)
 
-	initHandlers(r, databaseStore)
+	initHandlers(r, databaseStore, queryScheduler)
 	return r
 }
 
-func initHandlers(r *chi.Mux, store store.DatabaseStoreI) {
+func initHandlers(r *chi.Mux, store store.DatabaseStoreI, queryScheduler scheduler.SchedulerI) {
 	ServeFiles(r, "/", ui.GetStaticDir())
 	r.Get("/databases", getDatabases(store))
 	r.Get("/tables-metadata", getTablesMetadata(store))
+	r.Post("/tables-metadata/refresh", refreshTablesMetadata(store))
+	r.Get("/schema-drift", getSchemaDrift(store))
 	r.Get("/query", query(store))
+	r.Get("/diff", diff(store))
+	r.Get("/explain", explain(store))
+	r.Post("/write/plan", planWrite(store))
+	r.Post("/write/confirm", confirmWrite(store))
+	r.Get("/migrations/status", getMigrationStatus(store))
+	r.Post("/migrations/up", migrateUp(store))
+	r.Post("/migrations/down", migrateDown(store))
+	r.Get("/scheduled-queries", getScheduledQueries(queryScheduler))
+	r.Post("/scheduled-queries/run", runScheduledQuery(queryScheduler))
+	r.Get("/scheduled-queries/runs", getScheduledQueryRuns(queryScheduler))
+	r.Get("/scheduled-queries/snapshot", getScheduledQuerySnapshot(queryScheduler))
+	r.Get("/alerts", getAlerts(queryScheduler))
+	r.Get("/alerts/silences", getSilences(queryScheduler))
+	r.Post("/alerts/silences", addSilence(queryScheduler))
 	r.Mount("/debug", middleware.Profiler())
 }
 